@@ -0,0 +1,183 @@
+package lotgo
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+func init() {
+	AddCommand("analyze", func(args []string) int {
+		if err := analyze(args, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	})
+}
+
+// analyzeOptions are the arguments of lotgo analyze
+type analyzeOptions struct {
+	period        time.Duration
+	periodFormat  string
+	summaryFormat string
+	from          time.Duration
+	to            time.Duration
+	test          string
+	step          string
+	percentiles   Percentiles
+	precision     int
+	topErrors     int
+}
+
+// analyze recomputes the period results and the summary from a samples file:
+//
+//	lotgo analyze [flags] samples.csv.gz
+func analyze(args []string, out io.Writer) error {
+	o := &analyzeOptions{percentiles: DefaultPercentiles}
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fs.DurationVar(&o.period, "period", 0, "Period of the results, default the period of the recorded run")
+	fs.StringVar(&o.periodFormat, "period-format", "md", "Format of the period results: md, csv or jsonl, none for no period results")
+	fs.StringVar(&o.summaryFormat, "summary-format", "csv", "Format of the summary: text, md, csv, jsonl or json")
+	fs.DurationVar(&o.from, "from", 0, "Leave out samples started before this time from the start of the run")
+	fs.DurationVar(&o.to, "to", 0, "Leave out samples started after this time from the start of the run, default the end of the run")
+	fs.StringVar(&o.test, "test", "", "Only analyze samples of this test")
+	fs.StringVar(&o.step, "step", "", "Analyze this step instead of the complete iterations")
+	fs.Var(&o.percentiles, "percentiles", "Comma separated list of latency percentiles to report")
+	fs.IntVar(&o.precision, "precision", DefaultPrecision, "Latency histogram precision in significant digits, 1-5")
+	fs.IntVar(&o.topErrors, "topErrors", 10, "Number of most common error classes in the summary, 0 for all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: lotgo analyze [flags] samples-file")
+	}
+	if o.precision < 1 || o.precision > 5 {
+		return errors.New("-precision must be between 1 and 5")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return o.run(f, out)
+}
+
+// analyzePeriod collects the samples started within one period
+type analyzePeriod struct {
+	stats   *stats
+	clients map[int]bool
+}
+
+func (o *analyzeOptions) run(in io.Reader, out io.Writer) error {
+	sr, err := newSamplesReader(in)
+	if err != nil {
+		return err
+	}
+	meta := sr.meta
+	if o.period <= 0 {
+		o.period = meta.Period
+	}
+	if o.period <= 0 {
+		o.period = 10 * time.Second
+	}
+	var pformat Format
+	if o.periodFormat != "none" {
+		if pformat, err = NewFormat(o.periodFormat, o.percentiles); err != nil {
+			return err
+		}
+	}
+	sformat, err := NewFormat(o.summaryFormat, o.percentiles)
+	if err != nil {
+		return err
+	}
+
+	windowStart := meta.Start.Add(o.from)
+	windowEnd := windowStart
+	if o.to > 0 {
+		windowEnd = meta.Start.Add(o.to)
+	}
+	runner := &Runner{name: meta.Test, clients: meta.Clients, period: o.period, startTime: windowStart}
+	slogger := NewSummaryLogger(0, out, sformat, o.percentiles, o.precision, o.topErrors)
+	slogger.Started(runner)
+	slogger.start = windowStart
+	slogger.active = 1
+	periods := map[int]*analyzePeriod{}
+	clients := map[int]bool{}
+	last := -1
+	for {
+		it, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if it.Start.Before(windowStart) || o.to > 0 && !it.Start.Before(windowEnd) {
+			continue
+		}
+		if o.test != "" && it.Test != o.test {
+			continue
+		}
+		if o.step != "" {
+			if it.Step != o.step {
+				continue
+			}
+			it.Step = ""
+		}
+		if o.to <= 0 && it.Start.Add(it.Duration).After(windowEnd) {
+			windowEnd = it.Start.Add(it.Duration)
+		}
+		sample := &Sample{Iteration: *it}
+		if it.Class != "" {
+			sample.Err = errors.New(it.Class)
+		}
+		slogger.Sample(sample)
+		if it.Step != "" {
+			continue
+		}
+		n := int(it.Start.Sub(windowStart) / o.period)
+		p, ok := periods[n]
+		if !ok {
+			p = &analyzePeriod{stats: newStats(o.precision), clients: map[int]bool{}}
+			periods[n] = p
+		}
+		if sample.Err != nil {
+			p.stats.error(sample.Err, it)
+		} else {
+			p.stats.success(it)
+		}
+		if it.Client >= 0 {
+			p.clients[it.Client] = true
+			clients[it.Client] = true
+		}
+		if n > last {
+			last = n
+		}
+	}
+
+	if pformat != nil && last >= 0 {
+		for _, s := range pformat.FormatHeader() {
+			io.WriteString(out, s)
+		}
+		for n := 0; n <= last; n++ {
+			p, ok := periods[n]
+			if !ok {
+				p = &analyzePeriod{stats: newStats(o.precision)}
+			}
+			periodStart := windowStart.Add(time.Duration(n) * o.period)
+			periodEnd := periodStart.Add(o.period)
+			if periodEnd.After(windowEnd) && windowEnd.After(periodStart) {
+				periodEnd = windowEnd
+			}
+			res := newResultAt(periodEnd, windowStart, periodStart, p.stats, int32(len(p.clients)), o.percentiles)
+			io.WriteString(out, pformat.Format(res))
+		}
+	}
+	runner.activeClients = int32(len(clients))
+	slogger.write(windowEnd)
+	return nil
+}
@@ -0,0 +1,87 @@
+package lotgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSamples records 10 iterations per second for 4 seconds, every tenth one failing
+func writeSamples(t *testing.T) (*bytes.Buffer, time.Time) {
+	buf := &bytes.Buffer{}
+	w := NewSamplesWriter(buf)
+	start := time.Now()
+	w.Started(&Runner{name: "shop", clients: 2, period: time.Second, startTime: start})
+	for i := 0; i < 40; i++ {
+		it := &Iteration{Start: start.Add(time.Duration(i) * 100 * time.Millisecond), Duration: time.Duration(i+1) * time.Millisecond,
+			Client: i % 2, Number: i/2 + 1, Test: "shop"}
+		if i%10 == 9 {
+			it.Class = "HTTP 503"
+			w.Sample(&Sample{Iteration: *it, Err: errors.New("503")})
+		} else {
+			w.Sample(&Sample{Iteration: *it})
+		}
+		w.Sample(&Sample{Iteration: Iteration{Start: it.Start, Duration: time.Millisecond, Client: -1, Test: "shop", Step: "login"}})
+	}
+	w.Finished()
+	return buf, start
+}
+
+func TestSamplesReader(t *testing.T) {
+	buf, start := writeSamples(t)
+	r, err := newSamplesReader(buf)
+	require.Nil(t, err)
+	assert.Equal(t, "shop", r.meta.Test)
+	assert.Equal(t, 2, r.meta.Clients)
+	assert.Equal(t, time.Second, r.meta.Period)
+	it, err := r.Next()
+	require.Nil(t, err)
+	assert.Equal(t, toMicros(start), toMicros(it.Start))
+	assert.Equal(t, &Iteration{Start: it.Start, Duration: time.Millisecond, Client: 0, Number: 1, Test: "shop"}, it)
+	count := 1
+	for {
+		if _, err = r.Next(); err != nil {
+			break
+		}
+		count++
+	}
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 80, count)
+
+	_, err = newSamplesReader(strings.NewReader("start_us,client\n1,2\n"))
+	assert.NotNil(t, err)
+}
+
+func TestAnalyze(t *testing.T) {
+	buf, _ := writeSamples(t)
+	out := &bytes.Buffer{}
+	o := &analyzeOptions{periodFormat: "csv", summaryFormat: "json", percentiles: DefaultPercentiles, precision: 3, from: 2 * time.Second}
+	require.Nil(t, o.run(buf, out))
+
+	lines := strings.Split(out.String(), "\n")
+	assert.True(t, strings.HasPrefix(lines[0], "time,count,"))
+	assert.True(t, strings.HasPrefix(lines[1], "1,9,21.0,"), lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "1,9,31.0,"), lines[2])
+
+	var s jsonSummary
+	require.Nil(t, json.Unmarshal([]byte(strings.Join(lines[3:], "\n")), &s))
+	assert.Equal(t, int64(18), s.Total.Count)
+	assert.Equal(t, int64(2), s.Total.Errors)
+	assert.Equal(t, 21.0, s.Total.Min)
+	assert.Equal(t, 1, len(s.Steps))
+	assert.Equal(t, int64(20), s.Steps[0].Count)
+
+	buf, _ = writeSamples(t)
+	out.Reset()
+	o = &analyzeOptions{periodFormat: "none", summaryFormat: "json", percentiles: DefaultPercentiles, precision: 3, step: "login", to: time.Second}
+	require.Nil(t, o.run(buf, out))
+	require.Nil(t, json.Unmarshal(out.Bytes(), &s))
+	assert.Equal(t, int64(10), s.Total.Count)
+	assert.Equal(t, 1.0, s.Total.Max)
+}
@@ -0,0 +1,57 @@
+package lotgo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CLASS_CHECK_PREFIX is the prefix of the error class of a failed check, e.g. "check login ok"
+const CLASS_CHECK_PREFIX = "check "
+
+// CheckNamer is implemented by errors of failed checks which carry the check name
+type CheckNamer interface {
+	CheckName() string
+}
+
+// CheckClassifier classifies the errors of failed checks by the check name
+var CheckClassifier ErrorClassifier = ErrorClassifierFunc(func(err error) string {
+	var cn CheckNamer
+	if errors.As(err, &cn) {
+		return CLASS_CHECK_PREFIX + cn.CheckName()
+	}
+	return ""
+})
+
+// checkError is a failed check returned by Runner.Check
+type checkError struct {
+	name string
+	err  error
+}
+
+func (e *checkError) Error() string {
+	return fmt.Sprintf("check %s failed: %v", e.name, e.err)
+}
+
+func (e *checkError) Unwrap() error {
+	return e.err
+}
+
+func (e *checkError) CheckName() string {
+	return e.name
+}
+
+// Check records the result of a named check, err is nil if the check passed. The pass rate of every
+// check is counted in the summary whether or not the test continues after a failure. Returns nil, err
+// if it already carries a check name, or err wrapped with the name so that it is classified by the check.
+func (runner *Runner) Check(name string, err error) error {
+	if err == nil {
+		runner.metric(name, METRIC_CHECK, 1)
+		return nil
+	}
+	runner.metric(name, METRIC_CHECK, 0)
+	var cn CheckNamer
+	if errors.As(err, &cn) {
+		return err
+	}
+	return &checkError{name: name, err: err}
+}
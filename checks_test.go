@@ -0,0 +1,71 @@
+package lotgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+type checkTest struct {
+	myTest
+	n int
+}
+
+// Test fails the "cart" check on every other pass but continues, the "login" check always passes
+func (e *checkTest) Test(lt *Runner) error {
+	e.n++
+	lt.Check("login", nil)
+	var err error
+	if e.n%2 == 0 {
+		err = errors.New("empty cart")
+	}
+	lt.Check("cart", err)
+	return nil
+}
+
+func TestRunner_Check(t *testing.T) {
+	buf := &bytes.Buffer{}
+	runner := New(2, 10, 0, 0, time.Second, &checkTest{}, nil, nil, 0, false)
+	sl := NewSummaryLogger(0, buf, &JsonFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	runner.allListeners = Listeners{sl}
+	runner.Run()
+
+	var s jsonSummary
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &s))
+	assert.Equal(t, int64(20), s.Total.Count)
+	require.Equal(t, 2, len(s.Total.Metrics))
+	cart := s.Total.Metrics[0]
+	assert.Equal(t, "cart", cart.Name)
+	assert.Equal(t, METRIC_CHECK, cart.Type)
+	assert.Equal(t, int64(20), cart.Count)
+	assert.Equal(t, 50.0, cart.Value)
+	assert.Equal(t, 100.0, s.Total.Metrics[1].Value)
+
+	th, err := ParseThreshold("metric.cart.value>=90")
+	require.NoError(t, err)
+	r := Thresholds{th}.evaluate(sl.summaryAt(time.Now()), DefaultPercentiles)
+	assert.False(t, r[0].Passed)
+	assert.Equal(t, 50.0, r[0].Actual)
+
+	text := (&TextFormat{}).FormatSummary(sl.summaryAt(time.Now()))
+	assert.Contains(t, text, "Checks\n  cart                             50.00% passed  10 of 20\n  login")
+	assert.NotContains(t, text, "Custom metrics")
+}
+
+func TestRunner_CheckError(t *testing.T) {
+	runner := &Runner{}
+	assert.Nil(t, runner.Check("ok", nil))
+	err := runner.Check("cart", errors.New("empty cart"))
+	assert.Equal(t, "check cart failed: empty cart", err.Error())
+	assert.IsType(t, &checkError{}, err)
+	assert.Equal(t, "check cart", DefaultErrorClassifier.Classify(err))
+	assert.Equal(t, err, runner.Check("other", err))
+	assert.Equal(t, "check cart", DefaultErrorClassifier.Classify(&stepError{step: "s", err: err}))
+	assert.True(t, strings.HasPrefix(formatMetricsLine([]*metricResult{{Name: "cart", Kind: METRIC_CHECK, Count: 4, Value: 75, Mean: 0.75}}, nil),
+		"metrics: cart 75.00% passed (3 of 4)"))
+}
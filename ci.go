@@ -0,0 +1,224 @@
+package lotgo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var _ SampleListener = &thresholdChecker{}
+
+/* Listener which checks the thresholds against the summary at the end of the run */
+type thresholdChecker struct {
+	slogger *summaryLogger
+	results []*thresholdResult
+}
+
+// newThresholdChecker creates a listener reporting the thresholds of slogger evaluated on its summary
+func newThresholdChecker(slogger *summaryLogger) *thresholdChecker {
+	return &thresholdChecker{slogger: slogger}
+}
+
+func (c *thresholdChecker) Started(runner *Runner) {
+}
+
+func (c *thresholdChecker) Sample(s *Sample) {
+}
+
+func (c *thresholdChecker) Finished() {
+	c.results = c.slogger.summary().Thresholds
+	for _, r := range c.results {
+		if r.Passed {
+			LOG().Infof("Threshold %s", r)
+		} else {
+			LOG().Errorf("Threshold %s", r)
+		}
+	}
+}
+
+// Failures returns the number of failed thresholds
+func (c *thresholdChecker) Failures() int {
+	return failures(c.results)
+}
+
+// formatMdResults formats the named results as a table in the MdFormat style with the name in the first column
+func formatMdResults(f *MdFormat, results []*result) []string {
+	header := f.FormatHeader()
+	lines := []string{"| name                 " + header[0], "| -------------------- " + header[1]}
+	for _, res := range results {
+		name := res.Test
+		if res.Step != "" {
+			name += "/" + res.Step
+		}
+		if name == "" {
+			name = "total"
+		}
+		lines = append(lines, fmt.Sprintf("| %-20s ", truncate(name, 20))+f.Format(res))
+	}
+	return lines
+}
+
+// formatMdThresholds formats the threshold verdicts as a markdown table
+func formatMdThresholds(results []*thresholdResult) []string {
+	lines := []string{"| threshold                      | actual       | result |\n", "| ------------------------------ | ------------ | ------ |\n"}
+	for _, r := range results {
+		actual := "-"
+		if r.Found {
+			actual = fmt.Sprintf("%.2f", r.Actual)
+		}
+		verdict := "PASS"
+		if !r.Passed {
+			verdict = "FAIL"
+		}
+		lines = append(lines, fmt.Sprintf("| %-30s | %12s | %-6s |\n", r.Threshold.Expr, actual, verdict))
+	}
+	return lines
+}
+
+// mdSummaryResults returns the total, test and step results of the summary, the total without the metrics line
+func mdSummaryResults(s *summary) []*result {
+	total := *s.Total
+	total.Metrics = nil
+	results := []*result{&total}
+	results = append(results, s.Tests...)
+	return append(results, s.Steps...)
+}
+
+// formatJobSummary formats the summary and the threshold verdicts as a compact markdown document
+func formatJobSummary(s *summary, ps Percentiles, results []*thresholdResult) string {
+	c := s.Config
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "## lotgo: %s\n\n", c.Test)
+	fmt.Fprintf(b, "%d clients, %s, %d iterations, %.2f r/s, %d errors\n\n", c.Clients, c.End.Sub(c.Start).Round(time.Millisecond), s.Total.Count, s.Total.Rate, s.Total.ErrCount)
+	for _, line := range formatMdResults(&MdFormat{Percentiles: ps}, mdSummaryResults(s)) {
+		b.WriteString(line)
+	}
+	if len(results) > 0 {
+		fmt.Fprintf(b, "\n### Thresholds: %d of %d passed\n\n", len(results)-failures(results), len(results))
+		for _, line := range formatMdThresholds(results) {
+			b.WriteString(line)
+		}
+	}
+	for _, w := range s.Warnings {
+		fmt.Fprintf(b, "\n> **Warning:** %s\n", w)
+	}
+	return b.String()
+}
+
+var _ SampleListener = &jobSummaryWriter{}
+
+/* Listener which writes a markdown job summary for CI systems at the end of the run */
+type jobSummaryWriter struct {
+	writer  io.Writer
+	slogger *summaryLogger
+}
+
+// newJobSummaryWriter creates a listener writing the summary of slogger and its threshold verdicts to w as markdown
+func newJobSummaryWriter(w io.Writer, slogger *summaryLogger) *jobSummaryWriter {
+	return &jobSummaryWriter{writer: w, slogger: slogger}
+}
+
+func (l *jobSummaryWriter) Started(runner *Runner) {
+}
+
+func (l *jobSummaryWriter) Sample(s *Sample) {
+}
+
+func (l *jobSummaryWriter) Finished() {
+	s := l.slogger.summary()
+	io.WriteString(l.writer, formatJobSummary(s, l.slogger.percentiles, s.Thresholds))
+	if c, ok := l.writer.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr"`
+	Cases     []*junitTestCase `xml:"testcase"`
+	SystemOut string           `xml:"system-out"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// formatJUnit formats the threshold verdicts as JUnit XML with a test case per threshold.
+// Without thresholds the run is a single passing test case.
+func formatJUnit(s *summary, ps Percentiles, results []*thresholdResult) string {
+	c := s.Config
+	suite := &junitTestSuite{
+		Name:      "lotgo." + c.Test,
+		Time:      fmt.Sprintf("%.3f", c.End.Sub(c.Start).Seconds()),
+		Timestamp: c.Start.Format("2006-01-02T15:04:05"),
+	}
+	out := formatMdResults(&MdFormat{Percentiles: ps}, mdSummaryResults(s))
+	for _, w := range s.Warnings {
+		out = append(out, "\nWARNING: "+w+"\n")
+	}
+	suite.SystemOut = strings.Join(out, "")
+	for _, r := range results {
+		tc := &junitTestCase{Name: r.Threshold.Expr, Classname: suite.Name + ".thresholds", Time: "0"}
+		if !r.Passed {
+			tc.Failure = &junitFailure{Message: r.String(), Type: "threshold", Text: r.String()}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	if len(results) == 0 {
+		suite.Cases = append(suite.Cases, &junitTestCase{Name: "run", Classname: suite.Name, Time: suite.Time})
+	}
+	suite.Tests = len(suite.Cases)
+	b, err := xml.MarshalIndent(&junitTestSuites{Suites: []*junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		LOG().Errorf("Failed to format JUnit XML: %v", err)
+		return ""
+	}
+	return xml.Header + string(b) + "\n"
+}
+
+var _ SampleListener = &junitWriter{}
+
+/* Listener which writes the threshold verdicts as JUnit XML at the end of the run */
+type junitWriter struct {
+	writer  io.Writer
+	slogger *summaryLogger
+}
+
+// newJUnitWriter creates a listener writing the threshold verdicts of the summary of slogger to w as JUnit XML
+func newJUnitWriter(w io.Writer, slogger *summaryLogger) *junitWriter {
+	return &junitWriter{writer: w, slogger: slogger}
+}
+
+func (l *junitWriter) Started(runner *Runner) {
+}
+
+func (l *junitWriter) Sample(s *Sample) {
+}
+
+func (l *junitWriter) Finished() {
+	s := l.slogger.summary()
+	io.WriteString(l.writer, formatJUnit(s, l.slogger.percentiles, s.Thresholds))
+	if c, ok := l.writer.(io.Closer); ok {
+		c.Close()
+	}
+}
@@ -0,0 +1,93 @@
+package lotgo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestFormatJUnit(t *testing.T) {
+	ts := Thresholds{}
+	require.Nil(t, ts.Set("p99<50"))
+	require.Nil(t, ts.Set("shop/login:mean<=200"))
+	s := thresholdSummary()
+	out := formatJUnit(s, DefaultPercentiles, ts.evaluate(s, DefaultPercentiles))
+	assert.True(t, strings.HasPrefix(out, xml.Header))
+
+	var suites junitTestSuites
+	require.Nil(t, xml.Unmarshal([]byte(out), &suites))
+	require.Equal(t, 1, len(suites.Suites))
+	suite := suites.Suites[0]
+	assert.Equal(t, "lotgo.shop", suite.Name)
+	assert.Equal(t, 2, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, "10.000", suite.Time)
+	require.Equal(t, 2, len(suite.Cases))
+	assert.Equal(t, "p99<50", suite.Cases[0].Name)
+	assert.Nil(t, suite.Cases[0].Failure)
+	assert.Equal(t, "shop/login:mean<=200", suite.Cases[1].Name)
+	require.NotNil(t, suite.Cases[1].Failure)
+	assert.Equal(t, "shop/login:mean<=200: FAIL, actual 250", suite.Cases[1].Failure.Message)
+	assert.Contains(t, suite.SystemOut, "| shop/login           |")
+
+	var run junitTestSuites
+	require.Nil(t, xml.Unmarshal([]byte(formatJUnit(s, DefaultPercentiles, nil)), &run))
+	assert.Equal(t, 1, run.Suites[0].Tests)
+	assert.Equal(t, "run", run.Suites[0].Cases[0].Name)
+}
+
+func TestFormatJobSummary(t *testing.T) {
+	ts := Thresholds{}
+	require.Nil(t, ts.Set("p99<50"))
+	require.Nil(t, ts.Set("p75<10"))
+	s := thresholdSummary()
+	s.Warnings = []string{"Load generator was likely saturated"}
+	out := formatJobSummary(s, DefaultPercentiles, ts.evaluate(s, DefaultPercentiles))
+	lines := strings.Split(out, "\n")
+	assert.Equal(t, "## lotgo: shop", lines[0])
+	assert.Equal(t, "2 clients, 10s, 99 iterations, 9.90 r/s, 1 errors", lines[2])
+	assert.True(t, strings.HasPrefix(lines[4], "| name                 | time     | count    |"))
+	assert.True(t, strings.HasPrefix(lines[6], "| total                |"))
+	assert.True(t, strings.HasPrefix(lines[7], "| shop                 |"))
+	assert.True(t, strings.HasPrefix(lines[8], "| shop/login           |"))
+	assert.Contains(t, out, "### Thresholds: 1 of 2 passed")
+	assert.Contains(t, out, "| p99<50                         |        45.00 | PASS   |")
+	assert.Contains(t, out, "| p75<10                         |            - | FAIL   |")
+	assert.Contains(t, out, "> **Warning:** Load generator was likely saturated")
+}
+
+func TestJobSummaryWriter(t *testing.T) {
+	slogger := NewSummaryLogger(0, &bytes.Buffer{}, &CsvFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	runner := &Runner{clients: 1, name: "shop"}
+	slogger.Started(runner)
+	slogger.Sample(&Sample{Iteration: Iteration{Test: "shop", Duration: 10e6}})
+	out := &bytes.Buffer{}
+	w := newJobSummaryWriter(out, slogger)
+	w.Started(runner)
+	w.Finished()
+	assert.Contains(t, out.String(), "## lotgo: shop")
+	assert.NotContains(t, out.String(), "Thresholds")
+}
+
+func TestThresholdListeners_SharedSummary(t *testing.T) {
+	slogger := NewSummaryLogger(0, &bytes.Buffer{}, &JsonFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	require.Nil(t, slogger.thresholds.Set("p99<50"))
+	checker := newThresholdChecker(slogger)
+	junit, md := &bytes.Buffer{}, &bytes.Buffer{}
+	runner := &Runner{clients: 1, name: "shop"}
+	listeners := Listeners{slogger, checker, newJUnitWriter(junit, slogger), newJobSummaryWriter(md, slogger)}
+	listeners.Started(runner)
+	slogger.Sample(&Sample{Iteration: Iteration{Test: "shop", Duration: 10e6}})
+	listeners.Finished()
+
+	s := slogger.summary()
+	assert.True(t, s == slogger.summary())
+	require.Equal(t, 1, len(s.Thresholds))
+	assert.True(t, s.Thresholds[0] == checker.results[0])
+	assert.Equal(t, 0, checker.Failures())
+	assert.Contains(t, junit.String(), `name="p99&lt;50"`)
+	assert.Contains(t, md.String(), "### Thresholds: 1 of 1 passed")
+}
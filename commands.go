@@ -0,0 +1,45 @@
+package lotgo
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Command is a lotgo sub command run instead of a load test, e.g. "lotgo analyze samples.csv.gz"
+type Command func(args []string) int
+
+var regCommands = map[string]Command{}
+
+// AddCommand registers a sub command by name
+func AddCommand(name string, cmd Command) {
+	regCommands[name] = cmd
+}
+
+// AllCommands returns the names of the registered sub commands
+func AllCommands() string {
+	var keys []string
+	for key := range regCommands {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, " ")
+}
+
+// runCommand runs the sub command named by the first argument, returns false if there is none
+func runCommand(args []string) bool {
+	if len(args) < 1 {
+		return false
+	}
+	cmd, ok := regCommands[args[0]]
+	if !ok {
+		return false
+	}
+	code := cmd(args[1:])
+	if code != 0 {
+		fmt.Fprintf(os.Stderr, "%s failed\n", args[0])
+	}
+	os.Exit(code)
+	return true
+}
@@ -0,0 +1,303 @@
+package lotgo
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	AddCommand("compare", func(args []string) int {
+		regressions, err := compareCommand(args, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if regressions > 0 {
+			return 1
+		}
+		return 0
+	})
+}
+
+// Tolerances are the allowed changes before a metric is flagged as a regression, e.g. latency=10,p99=20,rate=5,error_rate=1.
+//
+// Latency tolerances (latency for all, mean or a percentile like p99) and the rate tolerance are percents of the
+// baseline value, the error_rate tolerance is in percentage points.
+type Tolerances map[string]float64
+
+var _ flag.Value = &Tolerances{}
+
+// DefaultTolerances allow 10% slower latencies, 10% lower throughput and one percentage point more errors
+var DefaultTolerances = Tolerances{"latency": 10, "rate": 10, "error_rate": 1}
+
+func (t *Tolerances) String() string {
+	var keys []string
+	for k := range *t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, k+"="+strconv.FormatFloat((*t)[k], 'g', -1, 64))
+	}
+	return strings.Join(parts, ",")
+}
+
+// toleranceMetric matches the metrics a tolerance can be given for
+var toleranceMetric = regexp.MustCompile(`^(latency|mean|rate|error_rate|p[0-9]+(\.[0-9]+)?)$`)
+
+// Set parses comma separated metric=percent pairs on top of the defaults
+func (t *Tolerances) Set(s string) error {
+	values := Tolerances{}
+	for k, v := range DefaultTolerances {
+		values[k] = v
+	}
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid tolerance '%s', expected metric=percent", part)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid tolerance '%s', expected metric=percent", part)
+		}
+		k := strings.TrimSpace(kv[0])
+		if !toleranceMetric.MatchString(k) {
+			return fmt.Errorf("unknown tolerance metric '%s', expected latency, mean, a percentile like p99, rate or error_rate", k)
+		}
+		values[k] = v
+	}
+	*t = values
+	return nil
+}
+
+// latency returns the tolerance for the latency metric, e.g. p99 or mean
+func (t Tolerances) latency(metric string) float64 {
+	if v, ok := t[metric]; ok {
+		return v
+	}
+	if v, ok := t["latency"]; ok {
+		return v
+	}
+	return DefaultTolerances["latency"]
+}
+
+func (t Tolerances) get(metric string) float64 {
+	if v, ok := t[metric]; ok {
+		return v
+	}
+	return DefaultTolerances[metric]
+}
+
+// comparison is the change of a single metric of a test or a step
+type comparison struct {
+	Test       string
+	Step       string
+	Metric     string
+	Baseline   float64
+	Current    float64
+	Change     float64
+	Regression bool
+}
+
+// comparisonKey identifies a result of the summary, the total has the empty key
+func comparisonKey(res *jsonResult) stepKey {
+	return stepKey{test: res.Test, step: res.Step}
+}
+
+// compareSummaries compares the throughput, the mean, every common percentile and the error rate of the
+// total, tests and steps found in both summaries
+func compareSummaries(baseline *jsonSummary, current *jsonSummary, tol Tolerances) []*comparison {
+	if tol == nil {
+		tol = DefaultTolerances
+	}
+	base := map[stepKey]*jsonResult{}
+	for _, res := range summaryResults(baseline) {
+		base[comparisonKey(res)] = res
+	}
+	var list []*comparison
+	for _, cur := range summaryResults(current) {
+		b, ok := base[comparisonKey(cur)]
+		if !ok {
+			continue
+		}
+		add := func(metric string, bv float64, cv float64, change float64, regression bool) {
+			list = append(list, &comparison{Test: cur.Test, Step: cur.Step, Metric: metric, Baseline: bv, Current: cv, Change: change, Regression: regression})
+		}
+		rateChange := percentChange(b.Rate, cur.Rate)
+		add("rate", b.Rate, cur.Rate, rateChange, rateChange < -tol.get("rate"))
+		meanChange := percentChange(b.Mean, cur.Mean)
+		add("mean", b.Mean, cur.Mean, meanChange, meanChange > tol.latency("mean"))
+		var names []string
+		for name := range cur.Percentiles {
+			if _, ok := b.Percentiles[name]; ok {
+				names = append(names, name)
+			}
+		}
+		sort.Slice(names, func(i, j int) bool {
+			pi, _ := strconv.ParseFloat(strings.TrimPrefix(names[i], "p"), 64)
+			pj, _ := strconv.ParseFloat(strings.TrimPrefix(names[j], "p"), 64)
+			return pi < pj
+		})
+		for _, name := range names {
+			change := percentChange(b.Percentiles[name], cur.Percentiles[name])
+			add(name, b.Percentiles[name], cur.Percentiles[name], change, change > tol.latency(name))
+		}
+		errChange := (cur.ErrorRate - b.ErrorRate) * 100
+		add("error_rate", b.ErrorRate*100, cur.ErrorRate*100, errChange, errChange > tol.get("error_rate"))
+	}
+	return list
+}
+
+func summaryResults(s *jsonSummary) []*jsonResult {
+	var list []*jsonResult
+	if s.Total != nil {
+		total := *s.Total
+		total.Test = ""
+		total.Step = ""
+		list = append(list, &total)
+	}
+	list = append(list, s.Tests...)
+	return append(list, s.Steps...)
+}
+
+// percentChange returns the change from a to b in percents of a
+func percentChange(a float64, b float64) float64 {
+	if a == 0 {
+		if b == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return (b - a) / a * 100
+}
+
+// regressions returns the number of regressed metrics
+func regressions(list []*comparison) int {
+	n := 0
+	for _, c := range list {
+		if c.Regression {
+			n++
+		}
+	}
+	return n
+}
+
+// formatComparison formats the comparisons as a markdown table
+func formatComparison(list []*comparison) string {
+	s := "| test | step | metric | baseline | current | change | |\n"
+	s += "|------|------|--------|---------:|--------:|-------:|-|\n"
+	for _, c := range list {
+		test := c.Test
+		if test == "" && c.Step == "" {
+			test = "total"
+		}
+		mark := ""
+		if c.Regression {
+			mark = "REGRESSION"
+		}
+		change := fmt.Sprintf("%+.1f%%", c.Change)
+		if c.Metric == "error_rate" {
+			change = fmt.Sprintf("%+.2fpp", c.Change)
+		} else if math.IsInf(c.Change, 1) {
+			change = "new"
+		}
+		s += fmt.Sprintf("| %s | %s | %s | %.2f | %.2f | %s | %s |\n", test, c.Step, c.Metric, c.Baseline, c.Current, change, mark)
+	}
+	n := regressions(list)
+	if n > 0 {
+		s += fmt.Sprintf("\n%d regressions\n", n)
+	} else {
+		s += "\nNo regressions\n"
+	}
+	return s
+}
+
+// readSummary reads a JSON summary written with -summary-format json
+func readSummary(filename string) (*jsonSummary, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s := &jsonSummary{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("invalid summary '%s': %v", filename, err)
+	}
+	if s.Total == nil {
+		return nil, fmt.Errorf("invalid summary '%s': no total results", filename)
+	}
+	return s, nil
+}
+
+// compareCommand compares two JSON summaries and returns the number of regressions:
+//
+//	lotgo compare [-tolerances latency=10,rate=10,error_rate=1] baseline.json current.json
+func compareCommand(args []string, out io.Writer) (int, error) {
+	tol := Tolerances{}
+	for k, v := range DefaultTolerances {
+		tol[k] = v
+	}
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.Var(&tol, "tolerances", "Allowed changes as metric=percent: latency, mean, p99 etc., rate and error_rate in percentage points")
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	if fs.NArg() != 2 {
+		return 0, errors.New("usage: lotgo compare [flags] baseline.json current.json")
+	}
+	baseline, err := readSummary(fs.Arg(0))
+	if err != nil {
+		return 0, err
+	}
+	current, err := readSummary(fs.Arg(1))
+	if err != nil {
+		return 0, err
+	}
+	list := compareSummaries(baseline, current, tol)
+	io.WriteString(out, formatComparison(list))
+	return regressions(list), nil
+}
+
+var _ SampleListener = &baselineComparer{}
+
+/* Listener which compares the summary of the run against a baseline summary at the end */
+type baselineComparer struct {
+	baseline    *jsonSummary
+	tolerances  Tolerances
+	slogger     *summaryLogger
+	writer      io.Writer
+	regressions int
+}
+
+// newBaselineComparer creates a listener comparing the results of slogger against the baseline
+func newBaselineComparer(baseline *jsonSummary, tol Tolerances, w io.Writer, slogger *summaryLogger) *baselineComparer {
+	return &baselineComparer{baseline: baseline, tolerances: tol, writer: w, slogger: slogger}
+}
+
+func (l *baselineComparer) Started(runner *Runner) {
+}
+
+func (l *baselineComparer) Sample(s *Sample) {
+}
+
+func (l *baselineComparer) Finished() {
+	current := newJsonSummary(l.slogger.summary(), l.slogger.percentiles)
+	list := compareSummaries(l.baseline, current, l.tolerances)
+	l.regressions = regressions(list)
+	io.WriteString(l.writer, "\n"+formatComparison(list))
+}
+
+// Regressions returns the number of regressed metrics found at the end of the run
+func (l *baselineComparer) Regressions() int {
+	return l.regressions
+}
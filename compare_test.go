@@ -0,0 +1,73 @@
+package lotgo
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testSummary(rate float64, p99 float64, errorRate float64) *jsonSummary {
+	total := &jsonResult{Rate: rate, Mean: 10, Percentiles: map[string]float64{"p50": 8, "p99": p99}, ErrorRate: errorRate}
+	login := &jsonResult{Test: "shop", Step: "login", Rate: rate, Mean: 5, Percentiles: map[string]float64{"p50": 4, "p99": 9}}
+	return &jsonSummary{Total: total, Tests: []*jsonResult{}, Steps: []*jsonResult{login}}
+}
+
+func TestCompareSummaries(t *testing.T) {
+	list := compareSummaries(testSummary(100, 20, 0.01), testSummary(95, 21, 0.015), DefaultTolerances)
+	assert.Equal(t, 10, len(list))
+	assert.Equal(t, 0, regressions(list))
+	assert.Equal(t, "rate", list[0].Metric)
+	assert.Equal(t, -5.0, list[0].Change)
+	assert.Equal(t, "p50", list[2].Metric)
+	assert.Equal(t, "p99", list[3].Metric)
+	assert.Equal(t, "error_rate", list[4].Metric)
+	assert.InDelta(t, 0.5, list[4].Change, 0.0001)
+	assert.Equal(t, "login", list[5].Step)
+
+	list = compareSummaries(testSummary(100, 20, 0.01), testSummary(80, 30, 0.05), DefaultTolerances)
+	assert.Equal(t, 4, regressions(list))
+	table := formatComparison(list)
+	assert.Contains(t, table, "| total |  | p99 | 20.00 | 30.00 | +50.0% | REGRESSION |")
+	assert.Contains(t, table, "| total |  | error_rate | 1.00 | 5.00 | +4.00pp | REGRESSION |")
+	assert.Contains(t, table, "4 regressions")
+
+	tol := Tolerances{}
+	require.Nil(t, tol.Set("p99=60,rate=25,error_rate=5"))
+	assert.Equal(t, "error_rate=5,latency=10,p99=60,rate=25", tol.String())
+	assert.Equal(t, 0, regressions(compareSummaries(testSummary(100, 20, 0.01), testSummary(80, 30, 0.05), tol)))
+	assert.NotNil(t, tol.Set("p99"))
+	assert.NotNil(t, tol.Set("p99=10,errors=5"))
+	assert.NotNil(t, tol.Set("latencies=10"))
+	require.Nil(t, tol.Set("mean=5,p99.9=20,latency=15"))
+	assert.Equal(t, "error_rate=1,latency=15,mean=5,p99.9=20,rate=10", tol.String())
+}
+
+func TestCompareCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lotgo")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a.json")
+	b := filepath.Join(dir, "b.json")
+	require.Nil(t, ioutil.WriteFile(a, []byte(marshalJson(testSummary(100, 20, 0), true)), 0644))
+	require.Nil(t, ioutil.WriteFile(b, []byte(marshalJson(testSummary(100, 40, 0), true)), 0644))
+
+	out := &bytes.Buffer{}
+	n, err := compareCommand([]string{a, b}, out)
+	require.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, strings.HasPrefix(out.String(), "| test | step | metric |"))
+
+	out.Reset()
+	n, err = compareCommand([]string{"-tolerances", "p99=100", a, b}, out)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Contains(t, out.String(), "No regressions")
+
+	_, err = compareCommand([]string{a}, out)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,109 @@
+package lotgo
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// errorLogEntry is a single line in the error log
+type errorLogEntry struct {
+	Time      string  `json:"time"`
+	Client    int     `json:"client"`
+	Iteration int     `json:"iteration"`
+	Duration  float64 `json:"duration_ms"`
+	Test      string  `json:"test"`
+	Step      string  `json:"step,omitempty"`
+	Class     string  `json:"class"`
+	Message   string  `json:"message"`
+	Dropped   int64   `json:"dropped,omitempty"`
+}
+
+var _ SampleListener = &errorLogger{}
+
+/* Logger which writes every failed iteration as a JSON line */
+type errorLogger struct {
+	sync.Mutex
+	writer  io.Writer
+	sample  float64
+	maxRate int
+	window  time.Time
+	written int
+	dropped int64
+	rnd     *rand.Rand
+}
+
+// NewErrorLogger creates an error logger writing the given fraction of errors, at most maxRate lines per second.
+// Sample 1 writes every error and maxRate 0 disables the rate limit. The number of errors left out is
+// written with the next logged error.
+func NewErrorLogger(w io.Writer, sample float64, maxRate int) *errorLogger {
+	return &errorLogger{writer: w, sample: sample, maxRate: maxRate, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (l *errorLogger) Started(runner *Runner) {
+}
+
+func (l *errorLogger) Sample(s *Sample) {
+	if s.Err == nil || s.Step != "" {
+		return
+	}
+	err, it := s.Err, &s.Iteration
+	l.Lock()
+	defer l.Unlock()
+	if !l.accept(it.Start) {
+		l.dropped++
+		return
+	}
+	entry := &errorLogEntry{
+		Time:      it.Start.Format(time.RFC3339Nano),
+		Client:    it.Client,
+		Iteration: it.Number,
+		Duration:  float64(it.Duration) / float64(time.Millisecond),
+		Test:      it.Test,
+		Step:      stepName(err),
+		Class:     it.Class,
+		Message:   err.Error(),
+		Dropped:   l.dropped,
+	}
+	l.dropped = 0
+	l.write(entry)
+}
+
+func (l *errorLogger) Finished() {
+	l.Lock()
+	defer l.Unlock()
+	if l.dropped > 0 {
+		l.write(map[string]interface{}{"time": time.Now().Format(time.RFC3339Nano), "dropped": l.dropped})
+		l.dropped = 0
+	}
+}
+
+// accept checks the sampling and the rate limit for an error occurring at t
+func (l *errorLogger) accept(t time.Time) bool {
+	if l.sample < 1 && l.rnd.Float64() >= l.sample {
+		return false
+	}
+	if l.maxRate <= 0 {
+		return true
+	}
+	if t.Sub(l.window) >= time.Second {
+		l.window = t
+		l.written = 0
+	}
+	if l.written >= l.maxRate {
+		return false
+	}
+	l.written++
+	return true
+}
+
+func (l *errorLogger) write(v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		LOG().Errorf("Failed to write error log: %v", err)
+		return
+	}
+	l.writer.Write(append(b, '\n'))
+}
@@ -0,0 +1,49 @@
+package lotgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorLogger_WritesJSONLines(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	l := NewErrorLogger(buf, 1, 0)
+	ts := time.Date(2017, 11, 13, 9, 30, 0, 0, time.UTC)
+	l.Sample(&Sample{Iteration: Iteration{Start: ts, Duration: 1500 * time.Microsecond, Client: 3, Number: 17, Test: "example/http", Class: "HTTP 503"}, Err: &stepError{step: "login", err: errors.New("unavailable")}})
+	l.Sample(&Sample{Iteration: Iteration{Start: ts, Client: -1, Test: "example/http", Step: "login", Class: "HTTP 503"}, Err: errors.New("unavailable")})
+	l.Finished()
+	assert.Equal(t, `{"time":"2017-11-13T09:30:00Z","client":3,"iteration":17,"duration_ms":1.5,"test":"example/http","step":"login","class":"HTTP 503","message":"unavailable"}`+"\n", buf.String())
+}
+
+func TestErrorLogger_RateLimit(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	l := NewErrorLogger(buf, 1, 2)
+	ts := time.Now()
+	for i := 0; i < 5; i++ {
+		l.Sample(&Sample{Iteration: Iteration{Start: ts, Number: i + 1, Class: "boom"}, Err: errors.New("boom")})
+	}
+	l.Sample(&Sample{Iteration: Iteration{Start: ts.Add(time.Second), Number: 6, Class: "boom"}, Err: errors.New("boom")})
+	l.Sample(&Sample{Iteration: Iteration{Start: ts.Add(time.Second), Number: 7, Class: "boom"}, Err: errors.New("boom")})
+	l.Sample(&Sample{Iteration: Iteration{Start: ts.Add(time.Second), Number: 8, Class: "boom"}, Err: errors.New("boom")})
+	l.Finished()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(t, 5, len(lines))
+	var entry errorLogEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &entry))
+	assert.Equal(t, 6, entry.Iteration)
+	assert.Equal(t, int64(3), entry.Dropped)
+	assert.Contains(t, lines[4], `"dropped":1`)
+}
+
+func TestErrorLogger_Sampling(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	l := NewErrorLogger(buf, 0, 0)
+	l.Sample(&Sample{Iteration: Iteration{Start: time.Now()}, Err: errors.New("boom")})
+	assert.Equal(t, int64(1), l.dropped)
+}
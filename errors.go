@@ -0,0 +1,171 @@
+package lotgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	CLASS_TIMEOUT      = "timeout"
+	CLASS_CONN_REFUSED = "connection refused"
+	CLASS_OTHER        = "other"
+)
+
+// ErrorClassifier assigns a class to a test error, empty class means the error was not recognized
+type ErrorClassifier interface {
+	Classify(err error) string
+}
+
+// ErrorClassifierFunc is a function implementing ErrorClassifier
+type ErrorClassifierFunc func(err error) string
+
+func (f ErrorClassifierFunc) Classify(err error) string {
+	return f(err)
+}
+
+// ErrorClassifiers tries each classifier in order and returns the first non empty class
+type ErrorClassifiers []ErrorClassifier
+
+var _ ErrorClassifier = ErrorClassifiers{}
+
+func (c ErrorClassifiers) Classify(err error) string {
+	for _, cl := range c {
+		if class := cl.Classify(err); class != "" {
+			return class
+		}
+	}
+	return ""
+}
+
+// StatusCoder is implemented by errors which carry a HTTP status code
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// StatusClassifier classifies errors implementing StatusCoder by status, e.g. "HTTP 503"
+var StatusClassifier ErrorClassifier = ErrorClassifierFunc(func(err error) string {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return fmt.Sprintf("HTTP %d", sc.StatusCode())
+	}
+	return ""
+})
+
+// TimeoutClassifier classifies network timeouts and exceeded deadlines
+var TimeoutClassifier ErrorClassifier = ErrorClassifierFunc(func(err error) string {
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() || errors.Is(err, context.DeadlineExceeded) {
+		return CLASS_TIMEOUT
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out") {
+		return CLASS_TIMEOUT
+	}
+	return ""
+})
+
+// ConnRefusedClassifier classifies refused connections
+var ConnRefusedClassifier ErrorClassifier = ErrorClassifierFunc(func(err error) string {
+	if errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(err.Error(), "connection refused") {
+		return CLASS_CONN_REFUSED
+	}
+	return ""
+})
+
+// PrefixClassifier classifies errors by the message up to the first colon, at most n characters
+func PrefixClassifier(n int) ErrorClassifier {
+	return ErrorClassifierFunc(func(err error) string {
+		msg := err.Error()
+		if i := strings.Index(msg, ":"); i >= 0 {
+			msg = msg[:i]
+		}
+		msg = strings.TrimSpace(msg)
+		if len(msg) > n {
+			msg = msg[:n]
+		}
+		return msg
+	})
+}
+
+// DefaultErrorClassifier is used unless the runner is given another classifier
+var DefaultErrorClassifier ErrorClassifier = ErrorClassifiers{CheckClassifier, StatusClassifier, TimeoutClassifier, ConnRefusedClassifier, PrefixClassifier(40)}
+
+// errorStat holds the occurrences of one error class
+type errorStat struct {
+	Class   string
+	Count   int64
+	First   time.Time
+	Last    time.Time
+	Example string
+}
+
+// errorStats collects error occurrences by class
+type errorStats map[string]*errorStat
+
+func (s errorStats) add(class string, err error, t time.Time) {
+	stat, ok := s[class]
+	if !ok {
+		stat = &errorStat{Class: class, First: t, Example: err.Error()}
+		s[class] = stat
+	}
+	stat.Count++
+	stat.Last = t
+}
+
+func (s errorStats) merge(other errorStats) {
+	for class, o := range other {
+		stat, ok := s[class]
+		if !ok {
+			c := *o
+			s[class] = &c
+			continue
+		}
+		stat.Count += o.Count
+		if o.First.Before(stat.First) {
+			stat.First = o.First
+			stat.Example = o.Example
+		}
+		if o.Last.After(stat.Last) {
+			stat.Last = o.Last
+		}
+	}
+}
+
+func (s errorStats) count() int64 {
+	var c int64
+	for _, stat := range s {
+		c += stat.Count
+	}
+	return c
+}
+
+// sorted returns the error classes by descending count
+func (s errorStats) sorted() []*errorStat {
+	list := make([]*errorStat, 0, len(s))
+	for _, stat := range s {
+		c := *stat
+		list = append(list, &c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Class < list[j].Class
+	})
+	return list
+}
+
+// top returns at most n most common error classes
+func (s errorStats) top(n int) []*errorStat {
+	list := s.sorted()
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
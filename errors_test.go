@@ -0,0 +1,52 @@
+package lotgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type statusErr int
+
+func (e statusErr) Error() string   { return "status" }
+func (e statusErr) StatusCode() int { return int(e) }
+
+func TestDefaultErrorClassifier(t *testing.T) {
+	c := DefaultErrorClassifier
+	assert.Equal(t, "HTTP 503", c.Classify(fmt.Errorf("get: %w", statusErr(503))))
+	assert.Equal(t, CLASS_TIMEOUT, c.Classify(context.DeadlineExceeded))
+	assert.Equal(t, CLASS_TIMEOUT, c.Classify(errors.New("Failed to read message before timeout")))
+	assert.Equal(t, CLASS_CONN_REFUSED, c.Classify(fmt.Errorf("dial: %w", syscall.ECONNREFUSED)))
+	assert.Equal(t, "json decode", c.Classify(errors.New("json decode: unexpected end of input")))
+	assert.Equal(t, "12345", PrefixClassifier(5).Classify(errors.New("1234567890")))
+}
+
+func TestRunner_ClassifyError(t *testing.T) {
+	r := &Runner{}
+	assert.Equal(t, "boom", r.classifyError(errors.New("boom: bad")))
+	r.SetErrorClassifier(ErrorClassifierFunc(func(err error) string { return "" }))
+	assert.Equal(t, CLASS_OTHER, r.classifyError(errors.New("boom")))
+}
+
+func TestErrorStats(t *testing.T) {
+	t0 := time.Now()
+	a := errorStats{}
+	a.add("x", errors.New("x1"), t0)
+	a.add("y", errors.New("y1"), t0.Add(time.Second))
+	b := errorStats{}
+	b.add("y", errors.New("y0"), t0.Add(-time.Second))
+	b.add("y", errors.New("y2"), t0.Add(2*time.Second))
+	a.merge(b)
+	assert.Equal(t, int64(4), a.count())
+	top := a.top(1)
+	assert.Equal(t, 1, len(top))
+	assert.Equal(t, "y", top[0].Class)
+	assert.Equal(t, int64(3), top[0].Count)
+	assert.Equal(t, "y0", top[0].Example)
+	assert.Equal(t, t0.Add(-time.Second), top[0].First)
+	assert.Equal(t, t0.Add(2*time.Second), top[0].Last)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"github.com/huljas/lotgo"
+	"github.com/huljas/lotgo/scenario"
 	"os"
 	"strconv"
 	"time"
@@ -60,5 +61,6 @@ var _ lotgo.LoadTest = &ErrorTest{}
 func main() {
 	lotgo.AddTest("example/sleep", &SleepTest{Sleep: time.Second})
 	lotgo.AddTest("example/error", &ErrorTest{})
+	scenario.Register()
 	lotgo.Run()
 }
@@ -0,0 +1,324 @@
+package lotgo
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_BATCH_SIZE     = 1000
+	DEFAULT_FLUSH_INTERVAL = time.Second
+	MAX_UDP_PACKET         = 1432
+	MAX_QUEUED_BATCHES     = 10
+)
+
+// Tags are name value pairs attached to the exported metrics, e.g. run=42,env=staging
+type Tags map[string]string
+
+var _ flag.Value = &Tags{}
+
+func (t *Tags) String() string {
+	var parts []string
+	for _, k := range t.keys() {
+		parts = append(parts, k+"="+(*t)[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses comma separated name=value pairs
+func (t *Tags) Set(s string) error {
+	if *t == nil {
+		*t = Tags{}
+	}
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid tag '%s', expected name=value", part)
+		}
+		(*t)[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return nil
+}
+
+func (t *Tags) keys() []string {
+	var keys []string
+	for k := range *t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Outputs is a list of metric outputs given as type=url, e.g. influx=udp://localhost:8089
+type Outputs []string
+
+var _ flag.Value = &Outputs{}
+
+func (o *Outputs) String() string {
+	return strings.Join(*o, " ")
+}
+
+func (o *Outputs) Set(s string) error {
+	if !strings.Contains(s, "=") {
+		return fmt.Errorf("invalid output '%s', expected type=url", s)
+	}
+	*o = append(*o, s)
+	return nil
+}
+
+// NewOutputListener creates the exporter for an output given as type=url.
+//
+// Supported types are influx with http, https, udp and file urls and statsd with udp urls. Batching is
+// configured with the url parameters batch (lines per batch) and flush (flush interval), influx also
+// accepts mode=period for per-period aggregates (default) or mode=sample for every sample.
+func NewOutputListener(output string, tags Tags, period time.Duration, ps Percentiles, precision int) (SampleListener, error) {
+	kv := strings.SplitN(output, "=", 2)
+	if len(kv) != 2 {
+		return nil, fmt.Errorf("invalid output '%s', expected type=url", output)
+	}
+	u, err := url.Parse(kv[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid output url '%s': %v", kv[1], err)
+	}
+	q := u.Query()
+	batch := DEFAULT_BATCH_SIZE
+	if s := q.Get("batch"); s != "" {
+		batch, err = strconv.Atoi(s)
+		if err != nil || batch <= 0 {
+			return nil, fmt.Errorf("invalid batch size '%s'", s)
+		}
+	}
+	flush := DEFAULT_FLUSH_INTERVAL
+	if s := q.Get("flush"); s != "" {
+		flush, err = time.ParseDuration(s)
+		if err != nil || flush <= 0 {
+			return nil, fmt.Errorf("invalid flush interval '%s'", s)
+		}
+	}
+	mode := q.Get("mode")
+	q.Del("batch")
+	q.Del("flush")
+	q.Del("mode")
+	u.RawQuery = q.Encode()
+
+	switch kv[0] {
+	case "influx":
+		sink, err := newSink(u)
+		if err != nil {
+			return nil, err
+		}
+		perSample := false
+		switch mode {
+		case "", "period":
+		case "sample":
+			perSample = true
+		default:
+			return nil, fmt.Errorf("invalid influx mode '%s', expected period or sample", mode)
+		}
+		return NewInfluxListener(newBatcher(sink, batch, flush), tags, perSample, period, ps, precision), nil
+	case "statsd":
+		if u.Scheme != "udp" {
+			return nil, errors.New("statsd output requires udp url")
+		}
+		sink, err := newSink(u)
+		if err != nil {
+			return nil, err
+		}
+		return NewStatsdListener(newBatcher(sink, batch, flush), tags), nil
+	}
+	return nil, fmt.Errorf("unknown output type '%s', allowed values: influx statsd", kv[0])
+}
+
+// metricSink writes batches of metric lines to their destination
+type metricSink interface {
+	Send(lines []string) error
+	Close() error
+}
+
+func newSink(u *url.URL) (metricSink, error) {
+	switch u.Scheme {
+	case "http", "https":
+		return &httpSink{url: u.String(), client: &http.Client{Timeout: 5 * time.Second}}, nil
+	case "udp":
+		conn, err := net.Dial("udp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return &udpSink{conn: conn}, nil
+	case "file":
+		f, err := os.Create(u.Host + u.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &writerSink{w: f}, nil
+	}
+	return nil, fmt.Errorf("unsupported output url scheme '%s'", u.Scheme)
+}
+
+// httpSink posts every batch as the request body
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) Send(lines []string) error {
+	resp, err := s.client.Post(s.url, "text/plain; charset=utf-8", strings.NewReader(strings.Join(lines, "\n")+"\n"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("metrics post to %s failed with status %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
+
+// udpSink packs the lines into datagrams of at most MAX_UDP_PACKET bytes
+type udpSink struct {
+	conn net.Conn
+}
+
+func (s *udpSink) Send(lines []string) error {
+	buf := &bytes.Buffer{}
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+1+len(line) > MAX_UDP_PACKET {
+			if _, err := s.conn.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		_, err := s.conn.Write(buf.Bytes())
+		return err
+	}
+	return nil
+}
+
+func (s *udpSink) Close() error {
+	return s.conn.Close()
+}
+
+// writerSink appends the lines to a file
+type writerSink struct {
+	w io.WriteCloser
+}
+
+func (s *writerSink) Send(lines []string) error {
+	_, err := io.WriteString(s.w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func (s *writerSink) Close() error {
+	return s.w.Close()
+}
+
+// batcher collects metric lines and sends them in the background when the batch is full or the flush interval has passed
+type batcher struct {
+	sync.Mutex
+	sink    metricSink
+	size    int
+	flush   time.Duration
+	lines   []string
+	dropped int
+	kick    chan bool
+	done    chan bool
+	stopped sync.WaitGroup
+}
+
+func newBatcher(sink metricSink, size int, flush time.Duration) *batcher {
+	return &batcher{sink: sink, size: size, flush: flush, kick: make(chan bool, 1)}
+}
+
+func (b *batcher) start() {
+	b.done = make(chan bool)
+	b.stopped.Add(1)
+	go func() {
+		defer b.stopped.Done()
+		ticker := time.NewTicker(b.flush)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.Flush()
+			case <-b.kick:
+				b.Flush()
+			case <-b.done:
+				return
+			}
+		}
+	}()
+}
+
+// Add queues the lines, a full batch wakes up the sender. The lines which do not fit in MAX_QUEUED_BATCHES
+// batches are dropped, so that a slow or unreachable sink does not use up the memory of the load generator.
+func (b *batcher) Add(lines ...string) {
+	b.Lock()
+	if room := b.size*MAX_QUEUED_BATCHES - len(b.lines); len(lines) > room {
+		if room < 0 {
+			room = 0
+		}
+		b.dropped += len(lines) - room
+		lines = lines[:room]
+	}
+	b.lines = append(b.lines, lines...)
+	full := len(b.lines) >= b.size
+	b.Unlock()
+	if full {
+		select {
+		case b.kick <- true:
+		default:
+		}
+	}
+}
+
+// Flush sends all queued lines in batches and reports the lines dropped since the previous flush
+func (b *batcher) Flush() {
+	b.Lock()
+	lines, dropped := b.lines, b.dropped
+	b.lines, b.dropped = nil, 0
+	b.Unlock()
+	if dropped > 0 {
+		LOG().Warnf("Dropped %d metric lines, the output could not keep up", dropped)
+	}
+	for len(lines) > 0 {
+		n := b.size
+		if n > len(lines) {
+			n = len(lines)
+		}
+		if err := b.sink.Send(lines[:n]); err != nil {
+			LOG().Warnf("Failed to send %d metric lines: %v", n, err)
+		}
+		lines = lines[n:]
+	}
+}
+
+// Close stops the sender, sends the remaining lines and closes the sink
+func (b *batcher) Close() {
+	if b.done != nil {
+		close(b.done)
+		b.stopped.Wait()
+	}
+	b.Flush()
+	b.sink.Close()
+}
@@ -0,0 +1,129 @@
+package lotgo
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTags_Set(t *testing.T) {
+	var tags Tags
+	assert.NoError(t, tags.Set("run=42, env=staging"))
+	assert.Equal(t, Tags{"run": "42", "env": "staging"}, tags)
+	assert.Equal(t, "env=staging,run=42", tags.String())
+	assert.Error(t, tags.Set("run"))
+}
+
+func TestNewOutputListener_Invalid(t *testing.T) {
+	for _, o := range []string{"graphite=udp://localhost:2003", "statsd=http://localhost:8125", "influx=ftp://localhost", "influx=udp://localhost:8089?mode=x", "influx=udp://localhost:8089?batch=0"} {
+		_, err := NewOutputListener(o, nil, time.Second, nil, DefaultPrecision)
+		assert.Error(t, err, o)
+	}
+}
+
+func listenUDP(t *testing.T) (*net.UDPConn, func() string) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	read := func() string {
+		buf := make([]byte, 65536)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+	return conn, read
+}
+
+func TestInfluxListener_SamplesOverUDP(t *testing.T) {
+	conn, read := listenUDP(t)
+	defer conn.Close()
+	l, err := NewOutputListener("influx=udp://"+conn.LocalAddr().String()+"?mode=sample&batch=2", Tags{"env": "staging"}, time.Second, nil, DefaultPrecision)
+	require.NoError(t, err)
+	ts := time.Unix(1510565400, 0)
+	l.Started(&Runner{})
+	l.Sample(&Sample{Iteration: Iteration{Start: ts, Duration: 1500 * time.Microsecond, Test: "shop"}})
+	l.Sample(&Sample{Iteration: Iteration{Start: ts, Duration: time.Millisecond, Test: "shop", Step: "pay", Class: "HTTP 503"}, Err: errors.New("x")})
+	lines := read()
+	l.Finished()
+	assert.Equal(t, "lotgo_sample,env=staging,test=shop duration=1.5,error=0i 1510565400000000000\n"+
+		"lotgo_sample,env=staging,step=pay,test=shop,class=HTTP\\ 503 duration=1,error=1i 1510565400000000000", lines)
+}
+
+func TestInfluxListener_PeriodsOverHTTP(t *testing.T) {
+	var mutex sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		bodies = append(bodies, r.URL.RawQuery+" "+string(b))
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	l, err := NewOutputListener("influx="+server.URL+"/write?db=lotgo&flush=10ms", Tags{"run": "1"}, time.Hour, Percentiles{50}, DefaultPrecision)
+	require.NoError(t, err)
+	l.Started(&Runner{})
+	l.Sample(&Sample{Iteration: Iteration{Start: time.Now(), Duration: 2 * time.Millisecond, Test: "shop"}})
+	l.Sample(&Sample{Iteration: Iteration{Start: time.Now(), Duration: time.Millisecond, Test: "shop", Class: "timeout"}, Err: errors.New("x")})
+	l.Finished()
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Equal(t, 1, len(bodies))
+	assert.Regexp(t, `^db=lotgo lotgo_period,run=1,test=shop count=1i,errors=1i,rate=[0-9.]+,min=2,mean=2,p50=2,max=2,clients=0i [0-9]+\n$`, bodies[0])
+}
+
+func TestStatsdListener(t *testing.T) {
+	conn, read := listenUDP(t)
+	defer conn.Close()
+	l, err := NewOutputListener("statsd=udp://"+conn.LocalAddr().String(), Tags{"env": "ci"}, time.Second, nil, DefaultPrecision)
+	require.NoError(t, err)
+	l.Started(&Runner{})
+	l.Sample(&Sample{Iteration: Iteration{Duration: 3 * time.Millisecond, Test: "shop", Step: "pay", Class: "HTTP 503"}, Err: errors.New("x")})
+	l.Finished()
+	assert.Equal(t, strings.Join([]string{
+		"lotgo.requests:1|c|#env:ci,step:pay,test:shop",
+		"lotgo.errors:1|c|#env:ci,step:pay,test:shop,class:HTTP 503",
+		"lotgo.latency:3|ms|#env:ci,step:pay,test:shop"}, "\n"), read())
+}
+
+// countingSink counts the sent lines
+type countingSink struct {
+	sync.Mutex
+	lines int
+}
+
+func (s *countingSink) Send(lines []string) error {
+	s.Lock()
+	s.lines += len(lines)
+	s.Unlock()
+	return nil
+}
+
+func (s *countingSink) Close() error {
+	return nil
+}
+
+func TestBatcher_Dropped(t *testing.T) {
+	sink := &countingSink{}
+	b := newBatcher(sink, 2, time.Hour)
+	for i := 0; i < 25; i++ {
+		b.Add("line")
+	}
+	b.Add("a", "b")
+	assert.Equal(t, 2*MAX_QUEUED_BATCHES, len(b.lines))
+	assert.Equal(t, 7, b.dropped)
+	b.Flush()
+	assert.Equal(t, 2*MAX_QUEUED_BATCHES, sink.lines)
+	assert.Equal(t, 0, b.dropped)
+	b.Add("a", "b")
+	b.Close()
+	assert.Equal(t, 2*MAX_QUEUED_BATCHES+2, sink.lines)
+}
@@ -0,0 +1,212 @@
+package lotgo
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	METRIC_GOROUTINES = "lotgo_goroutines"
+	METRIC_HEAP       = "lotgo_heap_mb"
+	METRIC_CPU        = "lotgo_cpu_percent"
+	METRIC_GC_PAUSE   = "lotgo_gc_pause_us"
+	METRIC_START_LAG  = "lotgo_start_lag_us"
+
+	MAX_CPU_PERCENT = 90
+)
+
+var _ SampleListener = &healthMonitor{}
+
+/* Listener which samples the Go runtime and the iteration start lag of the load generator as custom metrics */
+type healthMonitor struct {
+	sync.Mutex
+	interval   time.Duration
+	maxLag     time.Duration
+	runner     *Runner
+	lag        *Histogram
+	periodLag  *Histogram
+	gcPauseMax float64
+	cpu        float64
+	cpuSamples int
+	cpuTotal   float64
+	goroutines int
+	heapMb     float64
+	lastGC     uint32
+	lastCPU    time.Duration
+	lastSample time.Time
+	done       chan bool
+	stopped    sync.WaitGroup
+}
+
+// healthResult is the health of the load generator during a period
+type healthResult struct {
+	CPU        float64 `json:"cpu_percent"`
+	Goroutines int     `json:"goroutines"`
+	HeapMb     float64 `json:"heap_mb"`
+	GCPauseMax float64 `json:"gc_pause_max_ms"`
+	StartLag   float64 `json:"start_lag_p99_ms"`
+}
+
+// NewHealthMonitor creates a monitor sampling the runtime every interval. The generator is reported as saturated
+// when the 99th percentile of the iteration start lag exceeds maxLag or the CPU usage stays above 90%.
+func NewHealthMonitor(interval time.Duration, maxLag time.Duration) *healthMonitor {
+	return &healthMonitor{interval: interval, maxLag: maxLag, lag: NewHistogram(DefaultPrecision), periodLag: NewHistogram(DefaultPrecision)}
+}
+
+// Started samples the runtime from now on, the runner reports the start lag of every iteration to the monitor
+func (m *healthMonitor) Started(runner *Runner) {
+	m.runner = runner
+	runner.health = m
+	m.done = make(chan bool)
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	m.lastGC = ms.NumGC
+	m.lastCPU, _ = processCPUTime()
+	m.lastSample = time.Now()
+	m.stopped.Add(1)
+	go m.sample()
+}
+
+func (m *healthMonitor) Sample(s *Sample) {
+}
+
+func (m *healthMonitor) Finished() {
+	if m.done != nil {
+		close(m.done)
+		m.stopped.Wait()
+	}
+	for _, w := range m.warnings() {
+		LOG().Warnf("%s", w)
+	}
+}
+
+// startLag records how much later than scheduled a client started an iteration, the schedule being the end of
+// the rampup delay or of the sleep after the previous iteration
+func (m *healthMonitor) startLag(view *Runner, lag time.Duration) {
+	if lag < 0 {
+		lag = 0
+	}
+	m.Lock()
+	m.lag.RecordDuration(lag)
+	m.periodLag.RecordDuration(lag)
+	m.Unlock()
+	view.metric(METRIC_START_LAG, METRIC_TREND, float64(lag/time.Microsecond))
+}
+
+func (m *healthMonitor) sample() {
+	defer m.stopped.Done()
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.sampleRuntime()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *healthMonitor) sampleRuntime() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	now := time.Now()
+	goroutines := runtime.NumGoroutine()
+	heapMb := float64(ms.HeapAlloc) / (1024 * 1024)
+
+	pauses := gcPauses(&ms, m.lastGC)
+	m.lastGC = ms.NumGC
+
+	cpuTime, cpuOk := processCPUTime()
+	cpu := -1.0
+	if cpuOk {
+		wall := now.Sub(m.lastSample)
+		if wall > 0 {
+			cpu = float64(cpuTime-m.lastCPU) / float64(wall) / float64(runtime.GOMAXPROCS(0)) * 100
+		}
+		m.lastCPU = cpuTime
+	}
+	m.lastSample = now
+
+	m.Lock()
+	m.goroutines = goroutines
+	m.heapMb = heapMb
+	for _, p := range pauses {
+		if p > m.gcPauseMax {
+			m.gcPauseMax = p
+		}
+	}
+	if cpu >= 0 {
+		m.cpu = cpu
+		m.cpuTotal += cpu
+		m.cpuSamples++
+	}
+	m.Unlock()
+
+	m.runner.metric(METRIC_GOROUTINES, METRIC_GAUGE, float64(goroutines))
+	m.runner.metric(METRIC_HEAP, METRIC_GAUGE, heapMb)
+	if cpu >= 0 {
+		m.runner.metric(METRIC_CPU, METRIC_GAUGE, cpu)
+	}
+	for _, p := range pauses {
+		m.runner.metric(METRIC_GC_PAUSE, METRIC_TREND, p)
+	}
+}
+
+// gcPauses returns the pause times in microseconds of the collections after the collection last. The pause times
+// of the latest 256 collections are kept in a circular buffer, older collections are skipped.
+func gcPauses(ms *runtime.MemStats, last uint32) []float64 {
+	start := last + 1
+	if ms.NumGC > 256 && start < ms.NumGC-255 {
+		start = ms.NumGC - 255
+	}
+	var pauses []float64
+	for n := start; n <= ms.NumGC; n++ {
+		pauses = append(pauses, float64(ms.PauseNs[(n+255)%256]/1000))
+	}
+	return pauses
+}
+
+// status returns the latest health values for the UI
+func (m *healthMonitor) status() []string {
+	m.Lock()
+	defer m.Unlock()
+	return []string{
+		fmt.Sprintf("Generator CPU:       %.0f %%", m.cpu),
+		fmt.Sprintf("Goroutines:          %d", m.goroutines),
+		fmt.Sprintf("Heap:                %.1f MB", m.heapMb),
+		fmt.Sprintf("Start lag, p99:      %.1f ms", toMillis(m.lag.ValueAtPercentile(99))),
+	}
+}
+
+// period returns the health of the load generator since the previous period for the period output
+func (m *healthMonitor) period() *healthResult {
+	m.Lock()
+	defer m.Unlock()
+	h := &healthResult{CPU: m.cpu, Goroutines: m.goroutines, HeapMb: m.heapMb, GCPauseMax: m.gcPauseMax / 1000,
+		StartLag: toMillis(m.periodLag.ValueAtPercentile(99))}
+	m.periodLag.Reset()
+	m.gcPauseMax = 0
+	return h
+}
+
+// warnings returns the reasons why the generator was likely saturated
+func (m *healthMonitor) warnings() []string {
+	m.Lock()
+	defer m.Unlock()
+	var list []string
+	if m.lag.Count() > 0 && m.maxLag > 0 {
+		p99 := m.lag.ValueAtPercentile(99)
+		if time.Duration(p99)*time.Microsecond > m.maxLag {
+			list = append(list, fmt.Sprintf("Load generator was likely saturated: iteration start lag p99 %.1f ms exceeded %.1f ms, "+
+				"the latencies include delays of the generator itself", toMillis(p99), toMillis(int64(m.maxLag/time.Microsecond))))
+		}
+	}
+	if m.cpuSamples > 0 && m.cpuTotal/float64(m.cpuSamples) > MAX_CPU_PERCENT {
+		list = append(list, fmt.Sprintf("Load generator was likely saturated: mean CPU usage %.0f%% of GOMAXPROCS %d",
+			m.cpuTotal/float64(m.cpuSamples), runtime.GOMAXPROCS(0)))
+	}
+	return list
+}
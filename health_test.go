@@ -0,0 +1,88 @@
+package lotgo
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthMonitor(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	runner := New(1, 0, 300*time.Millisecond, time.Millisecond, time.Second, &myTest{}, nil, nil, 0, false)
+	slogger := NewSummaryLogger(0, buf, &CsvFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	health := NewHealthMonitor(100*time.Millisecond, time.Nanosecond)
+	slogger.health = health
+	runner.allListeners = Listeners{slogger, health}
+	runner.Run()
+
+	status := health.status()
+	require.Equal(t, 4, len(status))
+	assert.True(t, strings.HasPrefix(status[1], "Goroutines:"))
+	out := buf.String()
+	assert.Contains(t, out, "lotgo_goroutines,gauge,")
+	assert.Contains(t, out, "lotgo_start_lag_us,trend,")
+	assert.Contains(t, out, "\nWARNING: Load generator was likely saturated: iteration start lag p99")
+	assert.True(t, health.lag.Count() >= 2, "one start lag per iteration")
+}
+
+func TestHealthMonitor_NoWarnings(t *testing.T) {
+	health := NewHealthMonitor(time.Second, 50*time.Millisecond)
+	health.lag.RecordDuration(time.Millisecond)
+	health.cpuTotal = 50
+	health.cpuSamples = 1
+	assert.Empty(t, health.warnings())
+	health.cpuTotal = 95
+	assert.Equal(t, 1, len(health.warnings()))
+}
+
+func TestPeriodLogger_Health(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	health := NewHealthMonitor(time.Second, time.Second)
+	health.cpu = 42
+	health.goroutines = 12
+	health.heapMb = 3.5
+	health.gcPauseMax = 1500
+	health.periodLag.RecordDuration(2 * time.Millisecond)
+	l := NewPeriodLogger(time.Second, buf, &MdFormat{}, DefaultPercentiles, DefaultPrecision)
+	l.setHealth(health)
+	l.runner = &Runner{}
+	l.print()
+	lines := strings.Split(buf.String(), "\n")
+	require.Equal(t, 4, len(lines), buf.String())
+	assert.True(t, strings.HasSuffix(lines[0], "| cpu %     | goroutines | heap MB   | gc max    | lag p99   |"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[2], "|        42 |         12 |       3.5 |       1.5 |       2.0 |"), lines[2])
+	assert.Equal(t, 0.0, health.gcPauseMax)
+	assert.Equal(t, int64(0), health.periodLag.Count())
+
+	buf.Reset()
+	l = NewPeriodLogger(time.Second, buf, &CsvFormat{Percentiles: Percentiles{50}}, Percentiles{50}, DefaultPrecision)
+	l.setHealth(health)
+	l.runner = &Runner{}
+	l.print()
+	assert.Equal(t, "time,count,min,mean,p50,max,rate,errs,clients,cpu_percent,goroutines,heap_mb,gc_pause_max_ms,start_lag_p99_ms\n"+
+		"0,0,0.0,0.0,0.0,0.0,0.00,0,0,42,12,3.5,0.0,0.0\n", buf.String())
+}
+
+func TestGcPauses(t *testing.T) {
+	ms := &runtime.MemStats{NumGC: 5}
+	for i := range ms.PauseNs {
+		ms.PauseNs[i] = uint64(i) * 1000
+	}
+	assert.Equal(t, []float64{0, 1, 2, 3, 4}, gcPauses(ms, 0))
+	assert.Equal(t, []float64{3, 4}, gcPauses(ms, 3))
+	assert.Empty(t, gcPauses(ms, 5))
+
+	// the buffer has wrapped around since the last sample, only the latest 256 pauses are left
+	ms.NumGC = 300
+	pauses := gcPauses(ms, 10)
+	require.Equal(t, 256, len(pauses))
+	assert.Equal(t, 44.0, pauses[0])
+	assert.Equal(t, 255.0, pauses[211])
+	assert.Equal(t, 0.0, pauses[212])
+	assert.Equal(t, 43.0, pauses[255])
+	assert.Equal(t, []float64{42, 43}, gcPauses(ms, 298))
+}
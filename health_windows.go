@@ -0,0 +1,8 @@
+package lotgo
+
+import "time"
+
+// processCPUTime is not available on windows
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
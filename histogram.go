@@ -0,0 +1,228 @@
+package lotgo
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// DefaultPrecision is the default number of significant decimal digits kept by a Histogram
+const DefaultPrecision = 3
+
+// Histogram is a HDR style histogram recording latencies in microseconds.
+//
+// Values are kept in log-linear buckets so that every recorded value is accurate to the
+// configured number of significant digits regardless of its magnitude. Histograms with the
+// same precision can be merged without losing any information. Histogram is not safe for
+// concurrent use.
+type Histogram struct {
+	precision             int
+	subBucketHalfCountMag uint
+	subBucketHalfCount    int64
+	subBucketMask         int64
+	counts                []int64
+	count                 int64
+	sum                   int64
+	min                   int64
+	max                   int64
+}
+
+// NewHistogram creates a new histogram with given precision in significant digits, 1 to 5
+func NewHistogram(precision int) *Histogram {
+	if precision < 1 || precision > 5 {
+		panic("Histogram precision must be between 1 and 5!")
+	}
+	largest := 2 * int64(math.Pow10(precision))
+	countMag := uint(math.Ceil(math.Log2(float64(largest))))
+	h := &Histogram{
+		precision:             precision,
+		subBucketHalfCountMag: countMag - 1,
+		subBucketHalfCount:    int64(1) << (countMag - 1),
+		subBucketMask:         int64(1)<<countMag - 1,
+	}
+	h.Reset()
+	return h
+}
+
+// Precision returns the number of significant digits of the histogram
+func (h *Histogram) Precision() int {
+	return h.precision
+}
+
+// Reset clears all recorded values
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.count = 0
+	h.sum = 0
+	h.min = math.MaxInt64
+	h.max = 0
+}
+
+// RecordDuration records a duration with microsecond resolution
+func (h *Histogram) RecordDuration(d time.Duration) {
+	h.RecordValue(int64(d / time.Microsecond))
+}
+
+// RecordValue records a single value, negative values are recorded as zero
+func (h *Histogram) RecordValue(v int64) {
+	h.RecordValues(v, 1)
+}
+
+// RecordValues records value v n times
+func (h *Histogram) RecordValues(v int64, n int64) {
+	if v < 0 {
+		v = 0
+	}
+	idx := h.countsIndex(v)
+	if idx >= len(h.counts) {
+		h.grow(idx + 1)
+	}
+	h.counts[idx] += n
+	h.count += n
+	h.sum += v * n
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// Merge adds all values of other into this histogram, the precisions must match
+func (h *Histogram) Merge(other *Histogram) error {
+	if other.precision != h.precision {
+		return errors.New("histogram merge: precision mismatch")
+	}
+	if other.count == 0 {
+		return nil
+	}
+	if len(other.counts) > len(h.counts) {
+		h.grow(len(other.counts))
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.count += other.count
+	h.sum += other.sum
+	if other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	return nil
+}
+
+// Copy returns an independent copy of the histogram
+func (h *Histogram) Copy() *Histogram {
+	c := *h
+	c.counts = make([]int64, len(h.counts))
+	copy(c.counts, h.counts)
+	return &c
+}
+
+// Count returns the number of recorded values
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+// Min returns the smallest recorded value or 0 if empty
+func (h *Histogram) Min() int64 {
+	if h.count == 0 {
+		return 0
+	}
+	return h.min
+}
+
+// Max returns the largest recorded value or 0 if empty
+func (h *Histogram) Max() int64 {
+	return h.max
+}
+
+// Mean returns the exact mean of the recorded values
+func (h *Histogram) Mean() float64 {
+	if h.count == 0 {
+		return 0
+	}
+	return float64(h.sum) / float64(h.count)
+}
+
+// ValueAtPercentile returns the value at given percentile, 0 to 100
+func (h *Histogram) ValueAtPercentile(p float64) int64 {
+	if h.count == 0 {
+		return 0
+	}
+	if p > 100 {
+		p = 100
+	}
+	target := int64(math.Ceil(p / 100 * float64(h.count)))
+	if target < 1 {
+		target = 1
+	}
+	var total int64
+	for i, c := range h.counts {
+		total += c
+		if total >= target {
+			v := h.highestEquivalentValue(h.valueFromIndex(i))
+			if v > h.max {
+				return h.max
+			}
+			if v < h.min {
+				return h.min
+			}
+			return v
+		}
+	}
+	return h.max
+}
+
+// Buckets calls fn for every non-empty bucket in ascending order with the bucket range and count
+func (h *Histogram) Buckets(fn func(from int64, to int64, count int64)) {
+	for i, c := range h.counts {
+		if c > 0 {
+			v := h.valueFromIndex(i)
+			fn(v, h.highestEquivalentValue(v), c)
+		}
+	}
+}
+
+func (h *Histogram) grow(size int) {
+	if size <= cap(h.counts) {
+		h.counts = h.counts[:size]
+		return
+	}
+	counts := make([]int64, size, size+int(h.subBucketHalfCount))
+	copy(counts, h.counts)
+	h.counts = counts
+}
+
+func (h *Histogram) bucketIndex(v int64) int {
+	pow2ceiling := 64 - bits.LeadingZeros64(uint64(v|h.subBucketMask))
+	return pow2ceiling - int(h.subBucketHalfCountMag) - 1
+}
+
+func (h *Histogram) countsIndex(v int64) int {
+	bucketIdx := h.bucketIndex(v)
+	subBucketIdx := v >> uint(bucketIdx)
+	base := int64(bucketIdx+1) << h.subBucketHalfCountMag
+	return int(base + subBucketIdx - h.subBucketHalfCount)
+}
+
+func (h *Histogram) valueFromIndex(idx int) int64 {
+	bucketIdx := int64(idx>>h.subBucketHalfCountMag) - 1
+	subBucketIdx := int64(idx)&(h.subBucketHalfCount-1) + h.subBucketHalfCount
+	if bucketIdx < 0 {
+		subBucketIdx -= h.subBucketHalfCount
+		bucketIdx = 0
+	}
+	return subBucketIdx << uint(bucketIdx)
+}
+
+func (h *Histogram) highestEquivalentValue(v int64) int64 {
+	bucketIdx := uint(h.bucketIndex(v))
+	lowest := v >> bucketIdx << bucketIdx
+	return lowest + int64(1)<<bucketIdx - 1
+}
@@ -0,0 +1,81 @@
+package lotgo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHistogram_Empty(t *testing.T) {
+	h := NewHistogram(3)
+	assert.Equal(t, int64(0), h.Count())
+	assert.Equal(t, int64(0), h.Min())
+	assert.Equal(t, int64(0), h.Max())
+	assert.Equal(t, 0.0, h.Mean())
+	assert.Equal(t, int64(0), h.ValueAtPercentile(99))
+}
+
+func TestHistogram_Percentiles(t *testing.T) {
+	h := NewHistogram(3)
+	for i := int64(1); i <= 10000; i++ {
+		h.RecordValue(i)
+	}
+	assert.Equal(t, int64(10000), h.Count())
+	assert.Equal(t, int64(1), h.Min())
+	assert.Equal(t, int64(10000), h.Max())
+	assert.Equal(t, 5000.5, h.Mean())
+	assert.InDelta(t, 5000, h.ValueAtPercentile(50), 5)
+	assert.InDelta(t, 9000, h.ValueAtPercentile(90), 9)
+	assert.InDelta(t, 9990, h.ValueAtPercentile(99.9), 10)
+	assert.Equal(t, int64(10000), h.ValueAtPercentile(100))
+}
+
+func TestHistogram_Precision(t *testing.T) {
+	for _, precision := range []int{1, 2, 3, 4, 5} {
+		h := NewHistogram(precision)
+		limit := 1.0
+		for i := 0; i < precision; i++ {
+			limit /= 10
+		}
+		for _, v := range []int64{1, 7, 1234, 98765, 3600000000} {
+			h.Reset()
+			h.RecordValue(v)
+			h.RecordValue(v + v/2)
+			got := h.ValueAtPercentile(50)
+			assert.InEpsilon(t, v, got, limit, "precision %d value %d", precision, v)
+		}
+	}
+}
+
+func TestHistogram_MergeIsLossless(t *testing.T) {
+	total := NewHistogram(3)
+	merged := NewHistogram(3)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		part := NewHistogram(3)
+		for j := 0; j < 1000; j++ {
+			v := r.Int63n(1000000)
+			part.RecordValue(v)
+			total.RecordValue(v)
+		}
+		assert.NoError(t, merged.Merge(part))
+	}
+	assert.Equal(t, total.Count(), merged.Count())
+	assert.Equal(t, total.Min(), merged.Min())
+	assert.Equal(t, total.Max(), merged.Max())
+	assert.Equal(t, total.Mean(), merged.Mean())
+	for _, p := range []float64{1, 50, 90, 99, 99.9} {
+		assert.Equal(t, total.ValueAtPercentile(p), merged.ValueAtPercentile(p))
+	}
+	assert.Error(t, merged.Merge(NewHistogram(2)))
+}
+
+func TestHistogram_ResetAndDuration(t *testing.T) {
+	h := NewHistogram(3)
+	h.RecordDuration(time.Second)
+	h.Reset()
+	h.RecordDuration(1500 * time.Microsecond)
+	assert.Equal(t, int64(1), h.Count())
+	assert.Equal(t, int64(1500), h.ValueAtPercentile(50))
+}
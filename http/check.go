@@ -0,0 +1,209 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckRecorder records the results of named checks, implemented by *lotgo.Runner
+type CheckRecorder interface {
+	Check(name string, err error) error
+}
+
+// CheckError is a failed check, lotgo classifies it by the check name
+type CheckError struct {
+	Name string
+	Err  error
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("check %s failed: %v", e.Name, e.Err)
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// CheckName returns the name of the failed check
+func (e *CheckError) CheckName() string {
+	return e.Name
+}
+
+// Check is a named assertion on a response, the constructors give default names which can be replaced with Named
+type Check struct {
+	Name string
+	Fn   func(resp *Response) error
+}
+
+// Named returns the check with another name, e.g. ExpectStatus(200).Named("login ok")
+func (c Check) Named(name string) Check {
+	c.Name = name
+	return c
+}
+
+// Check evaluates all checks and records their results, rec can be nil. Returns the first failed check,
+// the following checks are still evaluated so that their pass rates are counted.
+func (r *Response) Check(rec CheckRecorder, checks ...Check) error {
+	var first error
+	for _, c := range checks {
+		var err error
+		if cerr := c.Fn(r); cerr != nil {
+			err = &CheckError{Name: c.Name, Err: cerr}
+		}
+		if rec != nil {
+			rec.Check(c.Name, err)
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// ExpectStatus checks that the status is one of the codes
+func ExpectStatus(codes ...int) Check {
+	var names []string
+	for _, c := range codes {
+		names = append(names, strconv.Itoa(c))
+	}
+	return Check{Name: "status " + strings.Join(names, "|"), Fn: func(resp *Response) error {
+		for _, c := range codes {
+			if resp.Status == c {
+				return nil
+			}
+		}
+		return fmt.Errorf("status %d", resp.Status)
+	}}
+}
+
+// ExpectBodyContains checks that the body contains s
+func ExpectBodyContains(s string) Check {
+	return Check{Name: "body contains " + s, Fn: func(resp *Response) error {
+		if !strings.Contains(string(resp.Body), s) {
+			return errors.New("not found in body")
+		}
+		return nil
+	}}
+}
+
+// ExpectHeader checks the value of a response header
+func ExpectHeader(key string, value string) Check {
+	return Check{Name: "header " + key, Fn: func(resp *Response) error {
+		if v := resp.Header(key); v != value {
+			return fmt.Errorf("'%s' != '%s'", v, value)
+		}
+		return nil
+	}}
+}
+
+// ExpectJSON checks the value at a JSON path, the values are compared as formatted by fmt
+func ExpectJSON(path string, want interface{}) Check {
+	return Check{Name: "json " + path, Fn: func(resp *Response) error {
+		v, err := resp.JSONPath(path)
+		if err != nil {
+			return err
+		}
+		if fmt.Sprint(v) != fmt.Sprint(want) {
+			return fmt.Errorf("%v != %v", v, want)
+		}
+		return nil
+	}}
+}
+
+// ExpectLatency checks that the response was received within max
+func ExpectLatency(max time.Duration) Check {
+	return Check{Name: "latency < " + max.String(), Fn: func(resp *Response) error {
+		if resp.Time >= max {
+			return fmt.Errorf("latency %s", resp.Time)
+		}
+		return nil
+	}}
+}
+
+// JSONPath returns the value at a path of the JSON body, e.g. "data.items[0].id" or "$.token"
+func (r *Response) JSONPath(path string) (interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal(r.Body, &doc); err != nil {
+		return nil, fmt.Errorf("json path %s: %v", path, err)
+	}
+	return jsonPath(doc, path)
+}
+
+// JSONString returns the value at a JSON path as a string, numbers and booleans are formatted
+func (r *Response) JSONString(path string) (string, error) {
+	v, err := r.JSONPath(path)
+	if err != nil {
+		return "", err
+	}
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(t)
+		return string(b), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// Regex returns the first submatch of expr in the body, or the whole match if expr has no groups
+func (r *Response) Regex(expr string) (string, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", err
+	}
+	m := re.FindSubmatch(r.Body)
+	if m == nil {
+		return "", fmt.Errorf("regex %s: no match", expr)
+	}
+	if len(m) > 1 {
+		return string(m[1]), nil
+	}
+	return string(m[0]), nil
+}
+
+// HeaderValue returns the value of a header which must be present, e.g. a Location or a token
+func (r *Response) HeaderValue(key string) (string, error) {
+	values := r.Headers[http.CanonicalHeaderKey(key)]
+	if len(values) == 0 {
+		return "", fmt.Errorf("header %s: not found", key)
+	}
+	return values[0], nil
+}
+
+// jsonPath walks the dot separated keys and [n] indexes of path in doc
+func jsonPath(doc interface{}, path string) (interface{}, error) {
+	p := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	p = strings.Replace(p, "[", ".[", -1)
+	v := doc
+	if p == "" {
+		return v, nil
+	}
+	for _, part := range strings.Split(p, ".") {
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, "[") && strings.HasSuffix(part, "]") {
+			i, err := strconv.Atoi(part[1 : len(part)-1])
+			list, ok := v.([]interface{})
+			if err != nil || !ok || i < 0 || i >= len(list) {
+				return nil, fmt.Errorf("json path %s: no element %s", path, part)
+			}
+			v = list[i]
+			continue
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("json path %s: no key %s", path, part)
+		}
+		if v, ok = obj[part]; !ok {
+			return nil, fmt.Errorf("json path %s: no key %s", path, part)
+		}
+	}
+	return v, nil
+}
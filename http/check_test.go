@@ -0,0 +1,89 @@
+package client
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// checkResults records the check results like lotgo.Runner
+type checkResults map[string][]bool
+
+func (c checkResults) Check(name string, err error) error {
+	c[name] = append(c[name], err == nil)
+	return err
+}
+
+func jsonResponse(body string) *Response {
+	return &Response{Status: 200, Headers: http.Header{"Content-Type": {CONTENTTYPE_JSON}, "Location": {"/orders/7"}},
+		Body: []byte(body), Time: 20 * time.Millisecond}
+}
+
+func TestResponse_Extract(t *testing.T) {
+	resp := jsonResponse(`{"token": "abc", "data": {"items": [{"id": 7, "ok": true}, {"id": 8, "tags": ["a"]}]}}`)
+
+	v, err := resp.JSONString("$.token")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", v)
+	v, err = resp.JSONString("data.items[1].id")
+	require.NoError(t, err)
+	assert.Equal(t, "8", v)
+	v, err = resp.JSONString("data.items[0].ok")
+	require.NoError(t, err)
+	assert.Equal(t, "true", v)
+	v, err = resp.JSONString("data.items[1].tags")
+	require.NoError(t, err)
+	assert.Equal(t, `["a"]`, v)
+	_, err = resp.JSONPath("data.items[2].id")
+	assert.EqualError(t, err, "json path data.items[2].id: no element [2]")
+	_, err = resp.JSONPath("data.missing")
+	assert.EqualError(t, err, "json path data.missing: no key missing")
+	_, err = jsonResponse("<html>").JSONPath("token")
+	assert.Error(t, err)
+
+	v, err = resp.Regex(`"token": "(\w+)"`)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", v)
+	v, err = resp.Regex(`items`)
+	require.NoError(t, err)
+	assert.Equal(t, "items", v)
+	_, err = resp.Regex(`csrf=(\w+)`)
+	assert.Error(t, err)
+
+	v, err = resp.HeaderValue("location")
+	require.NoError(t, err)
+	assert.Equal(t, "/orders/7", v)
+	_, err = resp.HeaderValue("X-Token")
+	assert.Error(t, err)
+}
+
+func TestResponse_Check(t *testing.T) {
+	resp := jsonResponse(`{"user": {"name": "bob", "age": 34}}`)
+	rec := checkResults{}
+
+	assert.NoError(t, resp.Check(rec, ExpectStatus(200, 201), ExpectJSON("user.age", 34), ExpectBodyContains("bob"),
+		ExpectHeader("Content-Type", CONTENTTYPE_JSON), ExpectLatency(time.Second)))
+
+	err := resp.Check(rec, ExpectStatus(201).Named("created"), ExpectJSON("user.name", "bill"), ExpectLatency(10*time.Millisecond))
+	require.Error(t, err)
+	assert.Equal(t, "check created failed: status 200", err.Error())
+	var cerr *CheckError
+	require.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "created", cerr.CheckName())
+
+	assert.Equal(t, checkResults{
+		"status 200|201":      {true},
+		"json user.age":       {true},
+		"body contains bob":   {true},
+		"header Content-Type": {true},
+		"latency < 1s":        {true},
+		"created":             {false},
+		"json user.name":      {false},
+		"latency < 10ms":      {false},
+	}, rec)
+
+	assert.NoError(t, resp.Check(nil, ExpectStatus(200)))
+}
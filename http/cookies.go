@@ -0,0 +1,247 @@
+package client
+
+import (
+	"github.com/valyala/fasthttp"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar stores the cookies of one HttpClient following the domain, path, secure and expiry rules of
+// RFC 6265. The cloned LoadTests share the fields of the registered test, so a client with a jar of its
+// own is created in SetUp for each test to act as a separate logged in user:
+//
+//	func (t *MyTest) SetUp(lt *lotgo.Runner) {
+//		t.cli = client.NewSessionClient()
+//	}
+type CookieJar struct {
+	sync.Mutex
+	cookies map[string]*jarCookie
+	seq     int64
+}
+
+// jarCookie is a stored cookie, a host only cookie is sent only to the host which set it.
+// The cookies with equal paths are sent in the order they were created.
+type jarCookie struct {
+	cookie   *http.Cookie
+	hostOnly bool
+	created  int64
+}
+
+func NewCookieJar() *CookieJar {
+	return &CookieJar{cookies: map[string]*jarCookie{}}
+}
+
+func jarKey(c *http.Cookie) string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+// SetCookies stores the cookies received in a response to u, cookies for other domains are ignored and
+// expired cookies are removed
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Lock()
+	defer j.Unlock()
+	now := time.Now()
+	host := canonicalHost(u.Host)
+	for _, c := range cookies {
+		stored := *c
+		hostOnly := c.Domain == ""
+		if hostOnly {
+			stored.Domain = host
+		} else {
+			stored.Domain = strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+			if !domainMatch(host, stored.Domain) {
+				continue
+			}
+		}
+		if stored.Path == "" || stored.Path[0] != '/' {
+			stored.Path = defaultPath(u.Path)
+		}
+		if c.MaxAge > 0 {
+			stored.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		key := jarKey(&stored)
+		if c.MaxAge < 0 || !stored.Expires.IsZero() && !stored.Expires.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		j.seq++
+		created := j.seq
+		if old, ok := j.cookies[key]; ok {
+			created = old.created
+		}
+		j.cookies[key] = &jarCookie{cookie: &stored, hostOnly: hostOnly, created: created}
+	}
+}
+
+// Cookies returns the cookies to send in a request to u, the cookies with longer paths first
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.Lock()
+	defer j.Unlock()
+	now := time.Now()
+	host := canonicalHost(u.Host)
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	var list []*jarCookie
+	for key, jc := range j.cookies {
+		c := jc.cookie
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if jc.hostOnly && host != c.Domain || !jc.hostOnly && !domainMatch(host, c.Domain) {
+			continue
+		}
+		if !pathMatch(path, c.Path) || c.Secure && u.Scheme != "https" {
+			continue
+		}
+		list = append(list, jc)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if len(list[a].cookie.Path) != len(list[b].cookie.Path) {
+			return len(list[a].cookie.Path) > len(list[b].cookie.Path)
+		}
+		return list[a].created < list[b].created
+	})
+	cookies := make([]*http.Cookie, len(list))
+	for i, jc := range list {
+		c := *jc.cookie
+		cookies[i] = &c
+	}
+	return cookies
+}
+
+// All returns copies of the stored cookies which have not expired, by name
+func (j *CookieJar) All() []*http.Cookie {
+	j.Lock()
+	defer j.Unlock()
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, jc := range j.cookies {
+		if jc.cookie.Expires.IsZero() || jc.cookie.Expires.After(now) {
+			c := *jc.cookie
+			cookies = append(cookies, &c)
+		}
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		if cookies[a].Name != cookies[b].Name {
+			return cookies[a].Name < cookies[b].Name
+		}
+		return jarKey(cookies[a]) < jarKey(cookies[b])
+	})
+	return cookies
+}
+
+// Get returns the value of a cookie by name for any domain and path, e.g. a CSRF token
+func (j *CookieJar) Get(name string) (string, bool) {
+	for _, c := range j.All() {
+		if c.Name == name {
+			return c.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set stores a cookie as if it was received from rawurl
+func (j *CookieJar) Set(rawurl string, c *http.Cookie) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	j.SetCookies(u, []*http.Cookie{c})
+	return nil
+}
+
+// Delete removes the cookies with the name from all domains and paths
+func (j *CookieJar) Delete(name string) {
+	j.Lock()
+	defer j.Unlock()
+	for key, jc := range j.cookies {
+		if jc.cookie.Name == name {
+			delete(j.cookies, key)
+		}
+	}
+}
+
+// Clear removes all cookies, e.g. to log out between iterations
+func (j *CookieJar) Clear() {
+	j.Lock()
+	j.cookies = map[string]*jarCookie{}
+	j.Unlock()
+}
+
+// addCookies adds the cookies of the jar for the request uri to the request. The Cookie header is built
+// from all the pairs in order, SetCookie would keep only one of the cookies with the same name.
+func (j *CookieJar) addCookies(req *fasthttp.Request) {
+	u, err := url.Parse(string(req.URI().FullURI()))
+	if err != nil {
+		return
+	}
+	cookies := j.Cookies(u)
+	if len(cookies) == 0 {
+		return
+	}
+	pairs := make([]string, len(cookies))
+	for i, c := range cookies {
+		pairs[i] = c.Name + "=" + c.Value
+	}
+	// setting the Cookie header appends the pairs to the cookies already set on the request
+	req.Header.Set("Cookie", strings.Join(pairs, "; "))
+}
+
+// saveCookies stores the cookies of the Set-Cookie headers of the response
+func (j *CookieJar) saveCookies(req *fasthttp.Request, resp *fasthttp.Response) {
+	var raw []string
+	resp.Header.VisitAllCookie(func(key, value []byte) {
+		raw = append(raw, string(value))
+	})
+	if len(raw) == 0 {
+		return
+	}
+	u, err := url.Parse(string(req.URI().FullURI()))
+	if err != nil {
+		return
+	}
+	j.SetCookies(u, (&http.Response{Header: http.Header{"Set-Cookie": raw}}).Cookies())
+}
+
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// domainMatch tells if host is the domain or its subdomain, IP addresses match only themselves
+func domainMatch(host string, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch tells if the cookie path is the request path or its parent directory
+func pathMatch(path string, cookiePath string) bool {
+	if path == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
+
+// defaultPath is the directory of the request path
+func defaultPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func cookieNames(cookies []*http.Cookie) []string {
+	var names []string
+	for _, c := range cookies {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func mustParse(t *testing.T, rawurl string) *url.URL {
+	u, err := url.Parse(rawurl)
+	require.NoError(t, err)
+	return u
+}
+
+func TestCookieJar_DomainAndPath(t *testing.T) {
+	jar := NewCookieJar()
+	jar.SetCookies(mustParse(t, "http://www.shop.com/account/login"), []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".shop.com"},
+		{Name: "path", Value: "3", Path: "/account/orders"},
+		{Name: "other", Value: "4", Domain: "other.com"},
+		{Name: "secure", Value: "5", Path: "/", Secure: true},
+	})
+	assert.Equal(t, []string{"domain", "host", "path", "secure"}, cookieNames(jar.All()))
+
+	assert.Equal(t, []string{"path", "host", "domain"}, cookieNames(jar.Cookies(mustParse(t, "http://www.shop.com/account/orders/1"))))
+	assert.Equal(t, []string{"host", "domain", "secure"}, cookieNames(jar.Cookies(mustParse(t, "https://www.shop.com:8443/account"))))
+	assert.Equal(t, []string{"domain"}, cookieNames(jar.Cookies(mustParse(t, "http://api.shop.com/account"))))
+	assert.Empty(t, jar.Cookies(mustParse(t, "http://www.shop.com/accounts")))
+	assert.Empty(t, jar.Cookies(mustParse(t, "http://noshop.com/")))
+}
+
+func TestCookieJar_ExpiryAndHelpers(t *testing.T) {
+	jar := NewCookieJar()
+	u := mustParse(t, "http://localhost/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "abc", MaxAge: 60},
+		{Name: "csrf", Value: "tok"},
+		{Name: "old", Value: "x", Expires: time.Now().Add(-time.Hour)},
+	})
+	assert.Equal(t, []string{"csrf", "session"}, cookieNames(jar.All()))
+	v, ok := jar.Get("csrf")
+	assert.True(t, ok)
+	assert.Equal(t, "tok", v)
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", MaxAge: -1}})
+	_, ok = jar.Get("session")
+	assert.False(t, ok)
+
+	require.NoError(t, jar.Set("http://localhost/", &http.Cookie{Name: "lang", Value: "fi"}))
+	jar.Delete("csrf")
+	assert.Equal(t, []string{"lang"}, cookieNames(jar.All()))
+	jar.Clear()
+	assert.Empty(t, jar.All())
+}
+
+func TestSessionClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "bob", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "csrf", Value: "tok", Path: "/"})
+			return
+		}
+		c, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(401)
+			return
+		}
+		w.Write([]byte(c.Value + " " + r.Header.Get("X-CSRF-Token")))
+	}))
+	defer srv.Close()
+
+	cli := NewSessionClient()
+	status, _, _ := cli.Get(srv.URL + "/me")
+	assert.Equal(t, 401, status)
+	_, err := cli.PostJSON(srv.URL+"/login", "{}", nil)
+	require.NoError(t, err)
+	token, _ := cli.CookieJar().Get("csrf")
+	resp, err := cli.Request(METHOD_GET, srv.URL+"/me").Header("X-CSRF-Token", token).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "bob tok", string(resp.Body))
+
+	// without a jar the cookies are not kept
+	status, _, _ = NewHttpClient().Get(srv.URL + "/me")
+	assert.Equal(t, 401, status)
+}
+
+func TestSessionClient_SameName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "id", Value: "site", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "id", Value: "account", Path: "/account"})
+			return
+		}
+		w.Write([]byte(r.Header.Get("Cookie")))
+	}))
+	defer srv.Close()
+
+	cli := NewSessionClient()
+	_, _, err := cli.Get(srv.URL + "/login")
+	require.NoError(t, err)
+	resp, err := cli.Request(METHOD_GET, srv.URL+"/account/orders").Do()
+	require.NoError(t, err)
+	assert.Equal(t, "id=account; id=site", string(resp.Body))
+	resp, err = cli.Request(METHOD_GET, srv.URL+"/cart").Header("Cookie", "ab=b").Do()
+	require.NoError(t, err)
+	assert.Equal(t, "ab=b; id=site", string(resp.Body))
+}
@@ -10,10 +10,13 @@ import (
 
 const (
 	CONTENTTYPE_JSON string = "application/json"
+	CONTENTTYPE_FORM string = "application/x-www-form-urlencoded"
 	METHOD_GET       string = "GET"
 	METHOD_POST      string = "POST"
 	METHOD_PUT       string = "PUT"
 	METHOD_DELETE    string = "DELETE"
+	METHOD_PATCH     string = "PATCH"
+	METHOD_HEAD      string = "HEAD"
 )
 
 type HttpClient interface {
@@ -24,8 +27,39 @@ type HttpClient interface {
 	DeleteJSON(url string, respJSON interface{}) (int, error)
 }
 
+// RequestClient is implemented by the clients of NewHttpClient, e.g. cli.(RequestClient).Request(METHOD_PATCH, url)
+type RequestClient interface {
+	HttpClient
+	// Request starts building a request with headers, query parameters and a form, multipart or raw body
+	Request(method string, url string) *Request
+}
+
+// SessionClient is a client which can store the cookies of a session, implemented by the clients of NewHttpClient
+type SessionClient interface {
+	RequestClient
+	// CookieJar returns the jar of the client, nil if the cookies are not stored
+	CookieJar() *CookieJar
+	SetCookieJar(jar *CookieJar)
+}
+
+// StatusError is returned for responses with status code 400 or above, the message is the response body
+type StatusError struct {
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return e.Body
+}
+
+// StatusCode returns the response status, used by lotgo for classifying errors
+func (e *StatusError) StatusCode() int {
+	return e.Status
+}
+
 type fastHttpClient struct {
 	client *fasthttp.Client
+	jar    *CookieJar
 }
 
 func NewHttpClient(to ...time.Duration) HttpClient {
@@ -41,9 +75,31 @@ func NewHttpClient(to ...time.Duration) HttpClient {
 	}
 }
 
-var _ HttpClient = &fastHttpClient{}
+// NewSessionClient creates a client with a cookie jar of its own
+func NewSessionClient(to ...time.Duration) SessionClient {
+	cli := NewHttpClient(to...).(*fastHttpClient)
+	cli.jar = NewCookieJar()
+	return cli
+}
+
+var _ SessionClient = &fastHttpClient{}
+
+func (http *fastHttpClient) CookieJar() *CookieJar {
+	return http.jar
+}
+
+func (http *fastHttpClient) SetCookieJar(jar *CookieJar) {
+	http.jar = jar
+}
 
 func (http *fastHttpClient) Get(url string) (int, []byte, error) {
+	if http.jar != nil {
+		resp, err := http.Request(METHOD_GET, url).Do()
+		if resp != nil {
+			return resp.Status, resp.Body, nil
+		}
+		return 0, nil, err
+	}
 	status, body, err := http.client.Get(nil, url)
 	return status, body, err
 }
@@ -91,13 +147,13 @@ func (http *fastHttpClient) doJSON(method string, url string, reqJSON interface{
 		}
 		req.Header.SetContentType(CONTENTTYPE_JSON)
 	}
-	err := http.client.Do(req, resp)
+	err := http.do(req, resp)
 	if err != nil {
 		return -1, errors.New(fmt.Sprintf("httpcli do: %s", err.Error()))
 	}
 	status := resp.StatusCode()
 	if status >= 400 {
-		return status, errors.New(string(resp.Body()))
+		return status, &StatusError{Status: status, Body: string(resp.Body())}
 	}
 	if respJSON != nil {
 		if bref, ok := respJSON.(*[]byte); ok {
@@ -114,3 +170,16 @@ func (http *fastHttpClient) doJSON(method string, url string, reqJSON interface{
 	}
 	return resp.StatusCode(), nil
 }
+
+// do sends the request with the cookies of the jar and stores the cookies of the response
+func (http *fastHttpClient) do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	if http.jar == nil {
+		return http.client.Do(req, resp)
+	}
+	http.jar.addCookies(req)
+	if err := http.client.Do(req, resp); err != nil {
+		return err
+	}
+	http.jar.saveCookies(req, resp)
+	return nil
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Request is an HTTP request built with RequestClient.Request and sent with Do, e.g.
+//
+//	resp, err := cli.Request(METHOD_PATCH, url).Header("Authorization", token).Query("dry", "true").JSON(user).Do()
+//
+// Errors of the builder methods are returned by Do.
+type Request struct {
+	client      *fastHttpClient
+	method      string
+	url         string
+	headers     [][2]string
+	query       [][2]string
+	form        url.Values
+	files       []*requestFile
+	body        []byte
+	contentType string
+	err         error
+}
+
+// requestFile is a file of a multipart form
+type requestFile struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+// Response holds the status, headers and body of a response and the time from sending the request to reading the response
+type Response struct {
+	Status  int
+	Headers http.Header
+	Body    []byte
+	Time    time.Duration
+}
+
+// Header returns the first value of the response header
+func (r *Response) Header(key string) string {
+	return r.Headers.Get(key)
+}
+
+// JSON unmarshals the response body to v
+func (r *Response) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+func (http *fastHttpClient) Request(method string, url string) *Request {
+	return &Request{client: http, method: method, url: url}
+}
+
+// Header adds a request header, a header can have several values
+func (r *Request) Header(key string, value string) *Request {
+	r.headers = append(r.headers, [2]string{key, value})
+	return r
+}
+
+// Query adds a query parameter to the url
+func (r *Request) Query(key string, value string) *Request {
+	r.query = append(r.query, [2]string{key, value})
+	return r
+}
+
+// Form adds a field to a form encoded body, or to a multipart body if the request has files
+func (r *Request) Form(key string, value string) *Request {
+	if r.form == nil {
+		r.form = url.Values{}
+	}
+	r.form.Add(key, value)
+	return r
+}
+
+// File adds a file to a multipart body
+func (r *Request) File(field string, filename string, content []byte) *Request {
+	r.files = append(r.files, &requestFile{field: field, filename: filename, content: content})
+	return r
+}
+
+// Body sets a raw body with its content type
+func (r *Request) Body(contentType string, body []byte) *Request {
+	r.contentType = contentType
+	r.body = body
+	return r
+}
+
+// JSON sets the body to v which can be either object, string or []byte like with the JSON helpers
+func (r *Request) JSON(v interface{}) *Request {
+	if sval, ok := v.(string); ok {
+		return r.Body(CONTENTTYPE_JSON, []byte(sval))
+	} else if bval, ok := v.([]byte); ok {
+		return r.Body(CONTENTTYPE_JSON, bval)
+	}
+	jval, err := json.Marshal(v)
+	if err != nil {
+		r.err = errors.New(fmt.Sprintf("json marshal: %s", err.Error()))
+	}
+	return r.Body(CONTENTTYPE_JSON, jval)
+}
+
+// Do sends the request. Like with the JSON helpers a response with status code 400 or above
+// returns a StatusError, the response is returned also then.
+func (r *Request) Do() (*Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod(r.method)
+	req.SetRequestURI(r.url)
+	if len(r.query) > 0 {
+		args := req.URI().QueryArgs()
+		for _, q := range r.query {
+			args.Add(q[0], q[1])
+		}
+	}
+	seen := map[string]bool{}
+	for _, h := range r.headers {
+		// Add does not set the special headers like User-Agent and Content-Type, Set does
+		if key := http.CanonicalHeaderKey(h[0]); !seen[key] {
+			seen[key] = true
+			req.Header.Set(h[0], h[1])
+		} else {
+			req.Header.Add(h[0], h[1])
+		}
+	}
+	if err := r.writeBody(req, seen["Content-Type"]); err != nil {
+		return nil, err
+	}
+	resp.SkipBody = r.method == METHOD_HEAD
+
+	start := time.Now()
+	err := r.client.do(req, resp)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("httpcli do: %s", err.Error()))
+	}
+	res := &Response{Status: resp.StatusCode(), Headers: http.Header{}, Time: time.Since(start)}
+	resp.Header.VisitAll(func(key, value []byte) {
+		res.Headers.Add(string(key), string(value))
+	})
+	res.Body = append([]byte(nil), resp.Body()...)
+	if res.Status >= 400 {
+		return res, &StatusError{Status: res.Status, Body: string(res.Body)}
+	}
+	return res, nil
+}
+
+// writeBody sets the raw, multipart or form encoded body. The content type of a raw or form encoded body
+// is set unless typed tells that it was given with Header, a multipart body always needs its own boundary.
+func (r *Request) writeBody(req *fasthttp.Request, typed bool) error {
+	switch {
+	case len(r.files) > 0:
+		buf := &bytes.Buffer{}
+		w := multipart.NewWriter(buf)
+		for key, values := range r.form {
+			for _, v := range values {
+				if err := w.WriteField(key, v); err != nil {
+					return err
+				}
+			}
+		}
+		for _, f := range r.files {
+			part, err := w.CreateFormFile(f.field, f.filename)
+			if err != nil {
+				return err
+			}
+			part.Write(f.content)
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		req.Header.SetContentType(w.FormDataContentType())
+		req.SetBody(buf.Bytes())
+	case r.form != nil:
+		if !typed {
+			req.Header.SetContentType(CONTENTTYPE_FORM)
+		}
+		req.SetBodyString(r.form.Encode())
+	case r.body != nil:
+		if !typed {
+			req.Header.SetContentType(r.contentType)
+		}
+		req.SetBody(r.body)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// startEchoServer returns the method, query, headers and body of the request in response headers and body
+func startEchoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Query", r.URL.RawQuery)
+		w.Header().Set("X-Trace", r.Header.Get("X-Trace"))
+		w.Header().Set("X-User-Agent", r.Header.Get("User-Agent"))
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(503)
+		}
+		if r.URL.Path == "/upload" {
+			r.ParseMultipartForm(1024)
+			f, h, _ := r.FormFile("file")
+			data, _ := ioutil.ReadAll(f)
+			w.Write([]byte(r.FormValue("name") + " " + h.Filename + " " + string(data)))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+}
+
+func TestRequest_HeadersAndQuery(t *testing.T) {
+	srv := startEchoServer()
+	defer srv.Close()
+	cli := NewHttpClient().(RequestClient)
+
+	resp, err := cli.Request(METHOD_PATCH, srv.URL+"/users/bob?v=1").Header("X-Trace", "abc").Header("User-Agent", "lotgo").Query("q", "a b").JSON(map[string]string{"name": "Bob"}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.Status)
+	assert.Equal(t, "PATCH", resp.Header("X-Method"))
+	assert.Equal(t, "v=1&q=a+b", resp.Header("X-Query"))
+	assert.Equal(t, "abc", resp.Header("X-Trace"))
+	assert.Equal(t, "lotgo", resp.Header("X-User-Agent"))
+	assert.Equal(t, CONTENTTYPE_JSON, resp.Header("X-Content-Type"))
+	user := map[string]string{}
+	require.NoError(t, resp.JSON(&user))
+	assert.Equal(t, "Bob", user["name"])
+	assert.True(t, resp.Time > 0)
+
+	resp, err = cli.Request(METHOD_HEAD, srv.URL).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "HEAD", resp.Header("X-Method"))
+	assert.Equal(t, 0, len(resp.Body))
+}
+
+func TestRequest_Bodies(t *testing.T) {
+	srv := startEchoServer()
+	defer srv.Close()
+	cli := NewHttpClient().(RequestClient)
+
+	resp, err := cli.Request(METHOD_POST, srv.URL).Form("name", "Bob").Form("age", "34").Do()
+	require.NoError(t, err)
+	assert.Equal(t, CONTENTTYPE_FORM, resp.Header("X-Content-Type"))
+	assert.Equal(t, "age=34&name=Bob", string(resp.Body))
+
+	resp, err = cli.Request(METHOD_POST, srv.URL+"/upload").Form("name", "Bob").File("file", "bob.txt", []byte("hello")).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "Bob bob.txt hello", string(resp.Body))
+
+	resp, err = cli.Request(METHOD_PUT, srv.URL).Body("text/plain", []byte("plain")).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", resp.Header("X-Content-Type"))
+	assert.Equal(t, "plain", string(resp.Body))
+
+	resp, err = cli.Request(METHOD_POST, srv.URL).Header("content-type", "application/vnd.shop+json").JSON(map[string]int{"id": 1}).Do()
+	require.NoError(t, err)
+	assert.Equal(t, "application/vnd.shop+json", resp.Header("X-Content-Type"))
+	assert.Equal(t, `{"id":1}`, string(resp.Body))
+
+	resp, err = cli.Request(METHOD_POST, srv.URL).Form("name", "Bob").Header("Content-Type", "application/x-www-form-urlencoded; charset=utf-8").Do()
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded; charset=utf-8", resp.Header("X-Content-Type"))
+}
+
+func TestRequest_Errors(t *testing.T) {
+	srv := startEchoServer()
+	defer srv.Close()
+	cli := NewHttpClient().(RequestClient)
+
+	resp, err := cli.Request(METHOD_POST, srv.URL+"/fail").Body("text/plain", []byte("down")).Do()
+	require.Error(t, err)
+	assert.Equal(t, 503, err.(*StatusError).StatusCode())
+	assert.Equal(t, "down", err.Error())
+	assert.Equal(t, 503, resp.Status)
+
+	_, err = cli.Request(METHOD_POST, srv.URL).JSON(make(chan int)).Do()
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package lotgo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ SampleListener = &influxListener{}
+
+/* Listener which exports the results in InfluxDB line protocol, either every sample or aggregated per period */
+type influxListener struct {
+	sync.Mutex
+	batcher     *batcher
+	tags        Tags
+	perSample   bool
+	period      time.Duration
+	percentiles Percentiles
+	precision   int
+	stats       map[stepKey]*stats
+	start       time.Time
+	periodStart time.Time
+	done        chan bool
+	stopped     sync.WaitGroup
+	runner      *Runner
+}
+
+// NewInfluxListener creates a listener writing lotgo_sample lines for every sample or lotgo_period lines every period
+func NewInfluxListener(b *batcher, tags Tags, perSample bool, period time.Duration, ps Percentiles, precision int) *influxListener {
+	return &influxListener{batcher: b, tags: tags, perSample: perSample, period: period, percentiles: percentilesOrDefault(ps), precision: precision, stats: map[stepKey]*stats{}}
+}
+
+func (l *influxListener) Started(runner *Runner) {
+	l.runner = runner
+	l.start = time.Now()
+	l.periodStart = l.start
+	l.batcher.start()
+	if !l.perSample {
+		l.done = make(chan bool)
+		l.stopped.Add(1)
+		go l.run()
+	}
+}
+
+func (l *influxListener) run() {
+	defer l.stopped.Done()
+	ticker := time.NewTicker(l.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.writePeriod()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *influxListener) Finished() {
+	if l.done != nil {
+		close(l.done)
+		l.stopped.Wait()
+		l.writePeriod()
+	}
+	l.batcher.Close()
+}
+
+func (l *influxListener) Sample(s *Sample) {
+	it := &s.Iteration
+	if l.perSample {
+		l.batcher.Add(l.sampleLine(it, s.Err != nil))
+		return
+	}
+	l.Lock()
+	if s.Err == nil {
+		l.statsFor(it).success(it)
+	} else {
+		l.statsFor(it).error(s.Err, it)
+	}
+	l.Unlock()
+}
+
+func (l *influxListener) statsFor(it *Iteration) *stats {
+	key := stepKey{test: it.Test, step: it.Step}
+	st, ok := l.stats[key]
+	if !ok {
+		st = newStats(l.precision)
+		l.stats[key] = st
+	}
+	return st
+}
+
+func (l *influxListener) sampleLine(it *Iteration, failed bool) string {
+	tags := l.lineTags(it.Test, it.Step)
+	errorField := "0i"
+	if failed {
+		tags += ",class=" + escapeInflux(it.Class)
+		errorField = "1i"
+	}
+	return fmt.Sprintf("lotgo_sample%s duration=%s,error=%s %d", tags, formatFloat(float64(it.Duration)/float64(time.Millisecond)), errorField, it.Start.UnixNano())
+}
+
+// writePeriod writes the aggregates of every test and step and starts a new period
+func (l *influxListener) writePeriod() {
+	l.Lock()
+	defer l.Unlock()
+	var keys []stepKey
+	for key := range l.stats {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return stepKeyLess(keys[i], keys[j])
+	})
+	var active int32
+	if l.runner != nil {
+		active = l.runner.ActiveClients()
+	}
+	var lines []string
+	for _, key := range keys {
+		st := l.stats[key]
+		res := NewResult(l.start, l.periodStart, st, active, l.percentiles)
+		fields := []string{
+			"count=" + strconv.FormatInt(res.Count, 10) + "i",
+			"errors=" + strconv.FormatInt(res.ErrCount, 10) + "i",
+			"rate=" + formatFloat(res.Rate),
+			"min=" + formatFloat(res.Min),
+			"mean=" + formatFloat(res.Mean),
+		}
+		for i, name := range l.percentiles.Names() {
+			fields = append(fields, name+"="+formatFloat(res.Percentiles[i]))
+		}
+		fields = append(fields, "max="+formatFloat(res.Max), "clients="+strconv.Itoa(int(active))+"i")
+		lines = append(lines, fmt.Sprintf("lotgo_period%s %s %d", l.lineTags(key.test, key.step), strings.Join(fields, ","), res.Timestamp.UnixNano()))
+		st.reset()
+	}
+	l.periodStart = time.Now()
+	l.batcher.Add(lines...)
+}
+
+// lineTags returns the configured tags with test and step, sorted by name as recommended by InfluxDB
+func (l *influxListener) lineTags(test string, step string) string {
+	all := Tags{}
+	for k, v := range l.tags {
+		all[k] = v
+	}
+	all["test"] = test
+	if step != "" {
+		all["step"] = step
+	}
+	s := ""
+	for _, k := range all.keys() {
+		if all[k] != "" {
+			s += "," + escapeInflux(k) + "=" + escapeInflux(all[k])
+		}
+	}
+	return s
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+func escapeInflux(s string) string {
+	return influxEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,253 @@
+package lotgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// jsonResult is the JSON representation of a result, latencies are in milliseconds
+type jsonResult struct {
+	Test         string             `json:"test,omitempty"`
+	Step         string             `json:"step,omitempty"`
+	Timestamp    time.Time          `json:"timestamp"`
+	Time         float64            `json:"time"`
+	Count        int64              `json:"count"`
+	Min          float64            `json:"min"`
+	Mean         float64            `json:"mean"`
+	Percentiles  map[string]float64 `json:"percentiles"`
+	Max          float64            `json:"max"`
+	Rate         float64            `json:"rate"`
+	Errors       int64              `json:"errors"`
+	ErrorRate    float64            `json:"error_rate"`
+	Clients      int32              `json:"clients"`
+	ErrorClasses []*jsonError       `json:"error_classes,omitempty"`
+	Metrics      []*jsonMetric      `json:"metrics,omitempty"`
+	Health       *healthResult      `json:"health,omitempty"`
+}
+
+// jsonError is the JSON representation of an error class
+type jsonError struct {
+	Class   string    `json:"class"`
+	Count   int64     `json:"count"`
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+	Example string    `json:"example"`
+}
+
+// jsonMetric is the JSON representation of a custom metric
+type jsonMetric struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Count       int64              `json:"count"`
+	Value       float64            `json:"value,omitempty"`
+	Rate        float64            `json:"rate,omitempty"`
+	Min         float64            `json:"min"`
+	Mean        float64            `json:"mean"`
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+	Max         float64            `json:"max"`
+}
+
+// jsonConfig is the JSON representation of the run configuration
+type jsonConfig struct {
+	Test        string    `json:"test"`
+	Clients     int       `json:"clients"`
+	Runs        int       `json:"runs"`
+	Duration    string    `json:"duration"`
+	Sleep       string    `json:"sleep"`
+	Rampup      string    `json:"rampup"`
+	Period      string    `json:"period"`
+	Percentiles []float64 `json:"percentiles"`
+	Precision   int       `json:"precision"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+}
+
+// jsonSummary is the JSON summary document of a run
+type jsonSummary struct {
+	Config      *jsonConfig   `json:"config"`
+	Environment *environment  `json:"environment"`
+	Total       *jsonResult   `json:"total"`
+	Tests       []*jsonResult `json:"tests"`
+	Steps       []*jsonResult `json:"steps"`
+	Errors      []*jsonError  `json:"errors"`
+	Warnings    []string      `json:"warnings,omitempty"`
+}
+
+func newJsonResult(res *result, ps Percentiles) *jsonResult {
+	ps = percentilesOrDefault(ps)
+	values := map[string]float64{}
+	for i, name := range ps.Names() {
+		if i < len(res.Percentiles) {
+			values[name] = res.Percentiles[i]
+		}
+	}
+	errorRate := 0.0
+	if res.Count+res.ErrCount > 0 {
+		errorRate = float64(res.ErrCount) / float64(res.Count+res.ErrCount)
+	}
+	return &jsonResult{
+		Test:         res.Test,
+		Step:         res.Step,
+		Timestamp:    res.Timestamp,
+		Time:         res.Time.Seconds(),
+		Count:        res.Count,
+		Min:          res.Min,
+		Mean:         res.Mean,
+		Percentiles:  values,
+		Max:          res.Max,
+		Rate:         res.Rate,
+		Errors:       res.ErrCount,
+		ErrorRate:    errorRate,
+		Clients:      res.ActiveClients,
+		ErrorClasses: newJsonErrors(res.Errors),
+		Metrics:      newJsonMetrics(res.Metrics, ps),
+		Health:       res.Health,
+	}
+}
+
+func newJsonMetrics(ms []*metricResult, ps Percentiles) []*jsonMetric {
+	var list []*jsonMetric
+	for _, m := range ms {
+		jm := &jsonMetric{Name: m.Name, Type: m.Kind, Count: m.Count, Value: m.Value, Rate: m.Rate, Min: m.Min, Mean: m.Mean, Max: m.Max}
+		if len(m.Percentiles) > 0 {
+			jm.Percentiles = map[string]float64{}
+			for i, name := range ps.Names() {
+				if i < len(m.Percentiles) {
+					jm.Percentiles[name] = m.Percentiles[i]
+				}
+			}
+		}
+		list = append(list, jm)
+	}
+	return list
+}
+
+func newJsonErrors(errs []*errorStat) []*jsonError {
+	var list []*jsonError
+	for _, e := range errs {
+		list = append(list, &jsonError{Class: e.Class, Count: e.Count, First: e.First, Last: e.Last, Example: e.Example})
+	}
+	return list
+}
+
+func newJsonSummary(s *summary, ps Percentiles) *jsonSummary {
+	c := s.Config
+	js := &jsonSummary{
+		Config: &jsonConfig{
+			Test:        c.Test,
+			Clients:     c.Clients,
+			Runs:        c.Runs,
+			Duration:    c.Duration.String(),
+			Sleep:       c.Sleep.String(),
+			Rampup:      c.Rampup.String(),
+			Period:      c.Period.String(),
+			Percentiles: c.Percentiles,
+			Precision:   c.Precision,
+			Start:       c.Start,
+			End:         c.End,
+		},
+		Environment: s.Environment,
+		Total:       newJsonResult(s.Total, ps),
+		Tests:       []*jsonResult{},
+		Steps:       []*jsonResult{},
+		Errors:      newJsonErrors(s.Errors),
+		Warnings:    s.Warnings,
+	}
+	for _, res := range s.Tests {
+		js.Tests = append(js.Tests, newJsonResult(res, ps))
+	}
+	for _, res := range s.Steps {
+		js.Steps = append(js.Steps, newJsonResult(res, ps))
+	}
+	if js.Errors == nil {
+		js.Errors = []*jsonError{}
+	}
+	return js
+}
+
+// JsonlFormat formats every result as a single line JSON object
+type JsonlFormat struct {
+	Percentiles Percentiles
+}
+
+var _ Format = &JsonlFormat{}
+
+func (f *JsonlFormat) FormatHeader() []string {
+	return nil
+}
+
+func (f *JsonlFormat) Format(res *result) string {
+	return marshalJson(newJsonResult(res, f.Percentiles), false)
+}
+
+// FormatErrors returns nothing, the error classes are included in the results
+func (f *JsonlFormat) FormatErrors(errs []*errorStat) []string {
+	return nil
+}
+
+// FormatMetrics returns nothing, the metrics are included in the results
+func (f *JsonlFormat) FormatMetrics(ms []*metricResult) []string {
+	return nil
+}
+
+// JsonFormat formats the summary as a JSON document with the configuration, environment and all results
+type JsonFormat struct {
+	Percentiles Percentiles
+}
+
+var _ Format = &JsonFormat{}
+var _ SummaryFormat = &JsonFormat{}
+
+func (f *JsonFormat) FormatHeader() []string {
+	return nil
+}
+
+func (f *JsonFormat) Format(res *result) string {
+	return marshalJson(newJsonResult(res, f.Percentiles), true)
+}
+
+// FormatErrors returns nothing, the error classes are included in the results
+func (f *JsonFormat) FormatErrors(errs []*errorStat) []string {
+	return nil
+}
+
+// FormatMetrics returns nothing, the metrics are included in the results
+func (f *JsonFormat) FormatMetrics(ms []*metricResult) []string {
+	return nil
+}
+
+func (f *JsonFormat) FormatSummary(s *summary) string {
+	return marshalJson(newJsonSummary(s, f.Percentiles), true)
+}
+
+func marshalJson(v interface{}, indent bool) string {
+	var b []byte
+	var err error
+	if indent {
+		b, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		b, err = json.Marshal(v)
+	}
+	if err != nil {
+		return fmt.Sprintf("{\"error\":%q}\n", err.Error())
+	}
+	return string(b) + "\n"
+}
+
+// NewFormat returns the format by name: text, md, csv, jsonl or json
+func NewFormat(name string, ps Percentiles) (Format, error) {
+	switch name {
+	case "text":
+		return &TextFormat{MdFormat{Percentiles: ps}}, nil
+	case "md":
+		return &MdFormat{Percentiles: ps}, nil
+	case "csv":
+		return &CsvFormat{Percentiles: ps}, nil
+	case "jsonl":
+		return &JsonlFormat{Percentiles: ps}, nil
+	case "json":
+		return &JsonFormat{Percentiles: ps}, nil
+	}
+	return nil, fmt.Errorf("unknown format '%s', allowed values: text md csv jsonl json", name)
+}
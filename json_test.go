@@ -0,0 +1,71 @@
+package lotgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestJsonlFormat(t *testing.T) {
+	f := JsonlFormat{Percentiles: Percentiles{50, 99.9}}
+	assert.Nil(t, f.FormatHeader())
+	ts := time.Date(2017, 11, 13, 9, 30, 0, 0, time.UTC)
+	res := &result{Timestamp: ts, Time: time.Second * 120, Count: 90, Min: 1, Mean: 2, Percentiles: []float64{3, 4}, Max: 5, Rate: 10, ErrCount: 10, ActiveClients: 19,
+		Errors: []*errorStat{{Class: "timeout", Count: 10, First: ts, Last: ts, Example: "read timeout"}}}
+	assert.Equal(t, `{"timestamp":"2017-11-13T09:30:00Z","time":120,"count":90,"min":1,"mean":2,"percentiles":{"p50":3,"p99.9":4},"max":5,"rate":10,"errors":10,"error_rate":0.1,"clients":19,`+
+		`"error_classes":[{"class":"timeout","count":10,"first":"2017-11-13T09:30:00Z","last":"2017-11-13T09:30:00Z","example":"read timeout"}]}`+"\n", f.Format(res))
+}
+
+type stepTest struct {
+	myTest
+}
+
+func (e *stepTest) Test(lt *Runner) error {
+	err := lt.Step("login", func() error { return nil })
+	if err != nil {
+		return err
+	}
+	return lt.Step("order", func() error { return errors.New("out of stock") })
+}
+
+func TestJsonFormat_Summary(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	runner := New(2, 5, 0, 0, time.Second, &stepTest{}, nil, nil, 0, false)
+	sl := NewSummaryLogger(0, buf, &JsonFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	runner.allListeners = Listeners{sl}
+	runner.Run()
+
+	var s jsonSummary
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &s))
+	assert.Equal(t, "stepTest", s.Config.Test)
+	assert.Equal(t, 2, s.Config.Clients)
+	assert.Equal(t, []float64{50, 90, 99, 99.9}, s.Config.Percentiles)
+	assert.NotEmpty(t, s.Environment.GoVersion)
+	assert.Equal(t, int64(0), s.Total.Count)
+	assert.Equal(t, int64(10), s.Total.Errors)
+	require.Equal(t, 1, len(s.Tests))
+	assert.Equal(t, "stepTest", s.Tests[0].Test)
+	require.Equal(t, 2, len(s.Steps))
+	assert.Equal(t, "login", s.Steps[0].Step)
+	assert.Equal(t, int64(10), s.Steps[0].Count)
+	assert.Equal(t, "order", s.Steps[1].Step)
+	assert.Equal(t, int64(10), s.Steps[1].Errors)
+	assert.Equal(t, 1.0, s.Steps[1].ErrorRate)
+	assert.Contains(t, s.Steps[1].Percentiles, "p99.9")
+	require.Equal(t, 1, len(s.Errors))
+	assert.Equal(t, "out of stock", s.Errors[0].Class)
+}
+
+func TestNewFormat(t *testing.T) {
+	for _, name := range []string{"md", "csv", "jsonl", "json"} {
+		f, err := NewFormat(name, nil)
+		assert.NoError(t, err)
+		assert.NotNil(t, f)
+	}
+	_, err := NewFormat("xml", nil)
+	assert.Error(t, err)
+}
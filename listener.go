@@ -2,6 +2,19 @@ package lotgo
 
 import "time"
 
+// Iteration describes a single test pass of a client or a step within it.
+// Step is empty for the test pass itself, a step has the client and the number of the test pass it belongs to.
+// Client is -1 for a step run outside of the clients.
+type Iteration struct {
+	Start    time.Time
+	Duration time.Duration
+	Client   int
+	Number   int
+	Test     string
+	Step     string
+	Class    string
+}
+
 type Listener interface {
 	Started(runner *Runner)
 	Success(time time.Duration)
@@ -9,9 +22,18 @@ type Listener interface {
 	Finished()
 }
 
-type Listeners []Listener
+// Listeners passes the events to all listeners, a Listener added with Add receives the samples through AdaptListener
+type Listeners []SampleListener
+
+var _ Listener = Listeners{}
+var _ SampleListener = Listeners{}
 
 func (c Listeners) Add(l Listener) Listeners {
+	return append(c, AdaptListener(l))
+}
+
+// AddSample adds a listener receiving samples
+func (c Listeners) AddSample(l SampleListener) Listeners {
 	return append(c, l)
 }
 
@@ -21,15 +43,28 @@ func (c Listeners) Started(runner *Runner) {
 	}
 }
 
+// Success passes a test pass known only by its duration to the listeners
 func (c Listeners) Success(d time.Duration) {
+	c.Sample(&Sample{Iteration: Iteration{Duration: d}})
+}
+
+// Error passes a failed test pass known only by its error to the listeners, classified by the DefaultErrorClassifier
+func (c Listeners) Error(err error) {
+	c.Sample(&Sample{Iteration: Iteration{Class: classifyError(nil, err)}, Err: err})
+}
+
+func (c Listeners) Sample(s *Sample) {
 	for _, l := range c {
-		l.Success(d)
+		l.Sample(s)
 	}
 }
 
-func (c Listeners) Error(err error) {
+// Metric passes the custom metric to the listeners implementing MetricListener
+func (c Listeners) Metric(m *MetricSample) {
 	for _, l := range c {
-		l.Error(err)
+		if ml, ok := l.(MetricListener); ok {
+			ml.Metric(m)
+		}
 	}
 }
 
@@ -37,4 +72,4 @@ func (c Listeners) Finished() {
 	for _, l := range c {
 		l.Finished()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,150 @@
+package lotgo
+
+import (
+	"time"
+)
+
+// the latency percentiles drawn as charts on the dashboards
+var livePercentiles = Percentiles{50, 95, 99}
+
+// liveTick holds the results recorded between two updates
+type liveTick struct {
+	start time.Time
+	end   time.Time
+	stats *stats
+	keys  map[stepKey]*stats
+}
+
+// liveStats collects the results of a running test for the terminal and web dashboards. The values are
+// computed over a sliding window of the latest updates and the series keep the latest THROUGHPUT_COUNT updates.
+// Only success may be called concurrently, the callers synchronize the rest.
+type liveStats struct {
+	stats       *shardedStats
+	totals      *stats
+	totalHist   *Histogram
+	precision   int
+	window      time.Duration
+	ticks       []*liveTick
+	windowStats *stats
+	windowKeys  map[stepKey]*stats
+	lastUpdate  time.Time
+	lastX       float64
+	windowRate  float64
+	errs        *uiErrors
+	errors      int64
+
+	rates      []float64
+	latencies  [][]float64
+	errorRates []float64
+	clients    []float64
+}
+
+func newLiveStats(precision int, window time.Duration) *liveStats {
+	if window <= 0 {
+		window = UI_WINDOW
+	}
+	return &liveStats{stats: newShardedStats(precision, true), totals: newStats(precision), totalHist: NewHistogram(precision),
+		windowStats: newStats(precision), windowKeys: map[stepKey]*stats{}, precision: precision, window: window,
+		latencies: make([][]float64, len(livePercentiles)), errs: newUiErrors()}
+}
+
+func (l *liveStats) started(clients int) {
+	l.stats.resize(clients)
+}
+
+func (l *liveStats) success(it *Iteration) {
+	l.stats.success(it)
+}
+
+func (l *liveStats) error(err error, it *Iteration) {
+	l.stats.error(err, it)
+	if it.Step != "" {
+		return
+	}
+	l.errs.add(it.Class, err, it.Start.Add(it.Duration))
+	l.errors++
+}
+
+// update collects the results since the previous update, slides the window and adds a point to every series
+func (l *liveStats) update(now time.Time, runner *Runner) {
+	tick, keys := l.stats.collect(true)
+	start := l.lastUpdate
+	if start.IsZero() {
+		start = runner.startTime
+	}
+	l.lastUpdate = now
+	l.totals.merge(tick)
+	l.totalHist = l.totals.hist
+	if d := now.Sub(start); d > 0 {
+		l.lastX = float64(tick.hist.Count()) / d.Seconds()
+	}
+
+	l.ticks = append(l.ticks, &liveTick{start: start, end: now, stats: tick, keys: keys})
+	for len(l.ticks) > 1 && now.Sub(l.ticks[1].start) >= l.window {
+		l.ticks = l.ticks[1:]
+	}
+	l.errs.tick(len(l.ticks))
+	l.windowStats = newStats(l.precision)
+	l.windowKeys = map[stepKey]*stats{}
+	for _, t := range l.ticks {
+		l.windowStats.merge(t.stats)
+		for key, st := range t.keys {
+			mergeStats(l.windowKeys, key, st, l.precision)
+		}
+	}
+	l.windowRate = 0
+	if d := l.windowDuration(); d > 0 {
+		l.windowRate = float64(l.windowStats.hist.Count()) / d.Seconds()
+	}
+
+	l.rates = appendPoint(l.rates, l.lastX)
+	for i, p := range livePercentiles {
+		l.latencies[i] = appendPoint(l.latencies[i], toMillis(l.windowStats.hist.ValueAtPercentile(p)))
+	}
+	l.errorRates = appendPoint(l.errorRates, errorRateOf(l.windowStats))
+	l.clients = appendPoint(l.clients, float64(runner.ActiveClients()))
+}
+
+// windowDuration returns the time covered by the window, less than the window size at the start
+func (l *liveStats) windowDuration() time.Duration {
+	if len(l.ticks) == 0 {
+		return 0
+	}
+	return l.lastUpdate.Sub(l.ticks[0].start)
+}
+
+// windowErrorRate returns the percentage of failed iterations in the window
+func (l *liveStats) windowErrorRate() float64 {
+	return errorRateOf(l.windowStats)
+}
+
+// progress returns the completed percentage of the runs or the duration
+func (l *liveStats) progress(runner *Runner) int64 {
+	if runner.runs > 0 {
+		total := runner.runs * runner.clients
+		count := l.totalHist.Count() + l.errors
+		return count * 100 / int64(total)
+	} else if runner.duration > 0 {
+		since := time.Since(runner.startTime)
+		return int64(since * 100 / runner.duration)
+	}
+	return 0
+}
+
+func errorRateOf(st *stats) float64 {
+	errs := st.errors.count()
+	all := errs + st.hist.Count()
+	if all == 0 {
+		return 0
+	}
+	return float64(errs) * 100 / float64(all)
+}
+
+// appendPoint adds the value to the series keeping the latest THROUGHPUT_COUNT points
+func appendPoint(series []float64, v float64) []float64 {
+	series = append(series, v)
+	if len(series) > THROUGHPUT_COUNT {
+		series = series[len(series)-THROUGHPUT_COUNT:]
+	}
+	return series
+}
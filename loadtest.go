@@ -18,6 +18,10 @@ type LoadTest interface {
 }
 
 func deepClone(test LoadTest) LoadTest {
+	if reflect.TypeOf(test).Kind() != reflect.Ptr || reflect.TypeOf(test).Elem().Kind() != reflect.Struct {
+		// a test given by value is copied on every call, only the fields of a struct pointer are cloned
+		return test
+	}
 	t := reflect.TypeOf(test).Elem()
 	vold := reflect.ValueOf(test)
 	v := reflect.New(t)
@@ -30,6 +34,14 @@ func deepClone(test LoadTest) LoadTest {
 	return v.Interface().(LoadTest)
 }
 
+// testTypeName returns the type name of a test given by pointer or by value
+func testTypeName(test LoadTest) string {
+	t := reflect.TypeOf(test)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
 
 var regTests map[string]LoadTest = map[string]LoadTest{}
 
@@ -3,6 +3,7 @@ package lotgo
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 type exampleTest struct {
@@ -35,3 +36,27 @@ func TestLoadTest_AddTest(t *testing.T) {
 	AddTest("bar", lt)
 	assert.Equal(t, "bar foo", AllTests())
 }
+
+type valueTest struct {
+	Value int
+}
+
+func (t valueTest) SetUp(r *Runner) {
+}
+
+func (t valueTest) TearDown(r *Runner) {
+}
+
+func (t valueTest) Test(r *Runner) error {
+	return nil
+}
+
+func TestLoadTest_ByValue(t *testing.T) {
+	var lt LoadTest = valueTest{Value: 13}
+	assert.Equal(t, lt, deepClone(lt))
+	assert.Equal(t, "valueTest", testTypeName(lt))
+	assert.Equal(t, "exampleTest", testTypeName(&exampleTest{}))
+	runner := New(1, 2, 0, 0, time.Second, lt, nil, nil, 0, false)
+	assert.Equal(t, "valueTest", runner.name)
+	runner.Run()
+}
@@ -1,9 +1,12 @@
 package lotgo
 
 import (
+	"flag"
 	"fmt"
-	"github.com/rcrowley/go-metrics"
 	"io"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,90 +16,219 @@ import (
 type Format interface {
 	FormatHeader() []string
 	Format(res *result) string
+	FormatErrors(errs []*errorStat) []string
+	FormatMetrics(ms []*metricResult) []string
 }
 
-// result is a summary of test results
+// Percentiles is a list of percentiles, 0 to 100, reported in the results
+type Percentiles []float64
+
+// DefaultPercentiles are the percentiles reported unless configured otherwise
+var DefaultPercentiles = Percentiles{50, 90, 99, 99.9}
+
+var _ flag.Value = &Percentiles{}
+
+// String returns the percentiles as comma separated list
+func (p *Percentiles) String() string {
+	var strs []string
+	for _, v := range *p {
+		strs = append(strs, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return strings.Join(strs, ",")
+}
+
+// Set parses comma separated list of percentiles
+func (p *Percentiles) Set(s string) error {
+	var list Percentiles
+	for _, str := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+		if err != nil {
+			return fmt.Errorf("invalid percentile '%s'", str)
+		}
+		if v <= 0 || v > 100 {
+			return fmt.Errorf("percentile %s out of range (0,100]", str)
+		}
+		list = append(list, v)
+	}
+	*p = list
+	return nil
+}
+
+// Names returns the column names for the percentiles, e.g. p99.9
+func (p Percentiles) Names() []string {
+	names := make([]string, len(p))
+	for i, v := range p {
+		names[i] = "p" + strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return names
+}
+
+// result is a summary of test results, all latencies are in milliseconds
 type result struct {
+	Test          string
+	Step          string
+	Timestamp     time.Time
 	Time          time.Duration
 	Count         int64
+	Min           float64
 	Mean          float64
-	P75           float64
-	P95           float64
+	Percentiles   []float64
+	Max           float64
 	Rate          float64
 	ErrCount      int64
+	Errors        []*errorStat
 	ActiveClients int32
+	Metrics       []*metricResult
+	Health        *healthResult
+}
+
+// NewResult creates a new result with latency values for given percentiles
+func NewResult(start time.Time, periodStart time.Time, st *stats, activeClients int32, ps Percentiles) *result {
+	return newResultAt(time.Now(), start, periodStart, st, activeClients, ps)
 }
 
-// NewResult creates a new result
-func NewResult(start time.Time, periodStart time.Time, timer metrics.Timer, errCounter metrics.Counter, activeClients int32) *result {
-	t := time.Since(start)
-	p := time.Since(periodStart)
-	c := timer.Count()
-	m := timer.Mean() / 1000 / 1000
-	p75 := timer.Percentile(0.75) / 1000 / 1000
-	p95 := timer.Percentile(0.95) / 1000 / 1000
+// newResultAt creates a new result for a period ending at now
+func newResultAt(now time.Time, start time.Time, periodStart time.Time, st *stats, activeClients int32, ps Percentiles) *result {
+	t := now.Sub(start)
+	p := now.Sub(periodStart)
+	hist := st.hist
+	c := hist.Count()
+	values := make([]float64, len(ps))
+	for i, v := range ps {
+		values[i] = toMillis(hist.ValueAtPercentile(v))
+	}
 	rate := float64(c) / p.Seconds()
-	ec := errCounter.Count()
-	return &result{Time: t, Count: c, Mean: m, P75: p75, P95: p95, Rate: rate, ErrCount: ec, ActiveClients: activeClients}
+	return &result{Timestamp: now, Time: t, Count: c, Min: toMillis(hist.Min()), Mean: hist.Mean() / 1000, Percentiles: values, Max: toMillis(hist.Max()), Rate: rate, ErrCount: st.errors.count(), Errors: st.errors.sorted(), ActiveClients: activeClients, Metrics: st.metrics.results(p, ps)}
+}
+
+func toMillis(us int64) float64 {
+	return float64(us) / 1000
+}
+
+// stats holds the latencies and errors of a test or a step and the custom metrics of the whole run
+type stats struct {
+	hist    *Histogram
+	errors  errorStats
+	metrics metricStats
+}
+
+func newStats(precision int) *stats {
+	return &stats{hist: NewHistogram(precision), errors: errorStats{}, metrics: metricStats{}}
+}
+
+func (s *stats) success(it *Iteration) {
+	s.hist.RecordDuration(it.Duration)
+}
+
+func (s *stats) error(err error, it *Iteration) {
+	s.errors.add(it.Class, err, it.Start.Add(it.Duration))
+}
+
+func (s *stats) metric(m *MetricSample) {
+	s.metrics.add(m, s.hist.Precision())
+}
+
+func (s *stats) reset() {
+	s.hist.Reset()
+	s.errors = errorStats{}
+	s.metrics = metricStats{}
+}
+
+// merge adds the latencies, errors and metrics of other
+func (s *stats) merge(other *stats) {
+	if err := s.hist.Merge(other.hist); err != nil {
+		LOG().Errorf("Failed to merge results: %v", err)
+	}
+	s.errors.merge(other.errors)
+	s.metrics.merge(other.metrics)
 }
 
-var _ Listener = &periodLogger{}
+var _ SampleListener = &periodLogger{}
 
 /* Logger which writes results periodically */
 type periodLogger struct {
 	sync.Mutex
 	period      time.Duration
 	start       time.Time
-	timer       metrics.Timer
-	errors      metrics.Counter
+	stats       *shardedStats
+	percentiles Percentiles
 	format      Format
 	writer      io.Writer
 	periodStart time.Time
-	running     bool
-	runner 		*Runner
+	series      []*result
+	headed      bool
+	done        chan bool
+	stopped     sync.WaitGroup
+	runner      *Runner
+	health      *healthMonitor
 }
 
 /* Returns new period logger */
-func NewPeriodLogger(p time.Duration, w io.Writer, f Format) *periodLogger {
+func NewPeriodLogger(p time.Duration, w io.Writer, f Format, ps Percentiles, precision int) *periodLogger {
 	now := time.Now()
-	l := &periodLogger{period: p, start: now, writer: w, format: f}
+	l := &periodLogger{period: p, start: now, writer: w, format: f, percentiles: ps, stats: newShardedStats(precision, false)}
 	l.newPeriod()
 	return l
 }
 
-func (l *periodLogger) Success(d time.Duration) {
-	l.Lock()
-	l.timer.Update(d)
-	l.Unlock()
+// setHealth adds the health of the load generator to the periods, as extra columns of the md and csv formats
+func (l *periodLogger) setHealth(m *healthMonitor) {
+	l.health = m
+	switch f := l.format.(type) {
+	case *TextFormat:
+		f.Health = true
+	case *MdFormat:
+		f.Health = true
+	case *CsvFormat:
+		f.Health = true
+	}
 }
 
-func (l *periodLogger) Error(error) {
-	l.Lock()
-	l.errors.Inc(1)
-	l.Unlock()
+func (l *periodLogger) Sample(s *Sample) {
+	if s.Step != "" {
+		return
+	}
+	l.stats.sample(s)
+}
+
+func (l *periodLogger) Metric(m *MetricSample) {
+	l.stats.metric(m)
 }
 
 func (l *periodLogger) Started(runner *Runner) {
-	l.running = true
 	l.runner = runner
+	l.stats.resize(runner.clients)
+	l.done = make(chan bool)
+	l.stopped.Add(1)
 	go l.run()
 }
 
+// Finished stops the logging, the results of the unfinished period are added to the series without writing them
 func (l *periodLogger) Finished() {
-	l.running = false
+	if l.done != nil {
+		close(l.done)
+		l.stopped.Wait()
+	}
+	if l.runner == nil {
+		return
+	}
+	l.Lock()
+	if res := l.collect(time.Now()); res.Count+res.ErrCount > 0 {
+		l.series = append(l.series, res)
+	}
+	l.Unlock()
 }
 
 func (l *periodLogger) run() {
-	first := true
-	for l.running {
-		time.Sleep(l.period)
-		if l.running {
-			if first {
-				l.printHead()
-				first = false
-			}
+	defer l.stopped.Done()
+	ticker := time.NewTicker(l.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
 			l.print()
-			l.newPeriod()
+		case <-l.done:
+			return
 		}
 	}
 }
@@ -104,8 +236,7 @@ func (l *periodLogger) run() {
 func (l *periodLogger) newPeriod() {
 	l.Lock()
 	l.periodStart = time.Now()
-	l.timer = metrics.NewTimer()
-	l.errors = metrics.NewCounter()
+	l.stats.collect(true)
 	l.Unlock()
 }
 
@@ -115,63 +246,122 @@ func (l *periodLogger) printHead() {
 	}
 }
 
+// print writes the results of the period and its custom metrics and starts a new one.
+// The header is written again before the row following the metrics.
 func (l *periodLogger) print() {
 	l.Lock()
-	res := NewResult(l.start, l.periodStart, l.timer, l.errors, l.runner.ActiveClients())
-	line := l.format.Format(res)
-	l.writer.Write([]byte(line))
+	res := l.collect(time.Now())
+	if !l.headed {
+		if len(l.series) > 0 {
+			l.writer.Write([]byte("\n"))
+		}
+		l.printHead()
+		l.headed = true
+	}
+	l.series = append(l.series, res)
+	l.writer.Write([]byte(l.format.Format(res)))
+	if len(res.Metrics) > 0 {
+		for _, s := range l.format.FormatMetrics(res.Metrics) {
+			l.writer.Write([]byte(s))
+			l.headed = false
+		}
+	}
 	l.Unlock()
 }
 
+// collect returns the results of the period ending at now and starts a new one, call with the lock held
+func (l *periodLogger) collect(now time.Time) *result {
+	total, _ := l.stats.collect(true)
+	res := newResultAt(now, l.start, l.periodStart, total, l.runner.ActiveClients(), l.percentiles)
+	if l.health != nil {
+		res.Health = l.health.period()
+	}
+	l.periodStart = now
+	return res
+}
+
+// Series returns the results of all logged periods
+func (l *periodLogger) Series() []*result {
+	l.Lock()
+	defer l.Unlock()
+	series := make([]*result, len(l.series))
+	copy(series, l.series)
+	return series
+}
+
 type summaryLogger struct {
-	warmup time.Duration
-	start  time.Time
-	timer  metrics.Timer
-	errors metrics.Counter
-	format Format
-	writer io.Writer
-	active bool
-	runner *Runner
-}
-
-func NewSummaryLogger(warmup time.Duration, w io.Writer, f Format) *summaryLogger {
-	l := &summaryLogger{warmup: warmup, start: time.Now(), format: f, writer: w, timer: metrics.NewTimer(), errors: metrics.NewCounter()}
-	return l
+	warmup      time.Duration
+	start       time.Time
+	precision   int
+	stats       *shardedStats
+	topErrors   int
+	percentiles Percentiles
+	format      Format
+	writer      io.Writer
+	active      int32
+	runner      *Runner
+	health      *healthMonitor
+	thresholds  Thresholds
+	final       *summary
 }
 
-var _ Listener = &summaryLogger{}
+// stepKey identifies a step of a test
+type stepKey struct {
+	test string
+	step string
+}
 
-func (l *summaryLogger) startLog() {
-	l.start = time.Now()
-	l.active = true
+// NewSummaryLogger creates a logger which writes the results and the topErrors most common error classes at the end.
+// The results are collected after the warmup.
+func NewSummaryLogger(warmup time.Duration, w io.Writer, f Format, ps Percentiles, precision int, topErrors int) *summaryLogger {
+	l := &summaryLogger{warmup: warmup, start: time.Now().Add(warmup), format: f, writer: w, percentiles: ps, precision: precision, topErrors: topErrors,
+		stats: newShardedStats(precision, true)}
+	return l
 }
 
+var _ SampleListener = &summaryLogger{}
+
 func (l *summaryLogger) checkActive() bool {
-	if !l.active && time.Since(l.start) > l.warmup {
-		l.startLog()
+	if atomic.LoadInt32(&l.active) == 1 {
+		return true
+	}
+	if time.Now().Before(l.start) {
+		return false
 	}
-	return l.active
+	atomic.StoreInt32(&l.active, 1)
+	return true
 }
 
-func (l *summaryLogger) Success(d time.Duration) {
+func (l *summaryLogger) Sample(s *Sample) {
 	if l.checkActive() {
-		l.timer.Update(d)
+		l.stats.sample(s)
 	}
 }
 
-func (l *summaryLogger) Error(error) {
+func (l *summaryLogger) Metric(m *MetricSample) {
 	if l.checkActive() {
-		l.errors.Inc(1)
+		l.stats.metric(m)
 	}
 }
 
 func (l *summaryLogger) Started(runner *Runner) {
 	l.runner = runner
+	l.stats.resize(runner.clients)
 }
 
 func (l *summaryLogger) Finished() {
+	l.write(time.Now())
+}
+
+// write writes the summary of the run ending at end, the summary is kept for the listeners finishing after the logger
+func (l *summaryLogger) write(end time.Time) {
+	l.final = l.summaryAt(end)
+	if sf, ok := l.format.(SummaryFormat); ok {
+		l.writer.Write([]byte(sf.FormatSummary(l.final)))
+		return
+	}
 	l.printHead()
-	l.print(l.runner)
+	l.print(l.runner, end)
 }
 
 func (l *summaryLogger) printHead() {
@@ -180,42 +370,264 @@ func (l *summaryLogger) printHead() {
 	}
 }
 
-func (l *summaryLogger) print(lt *Runner) {
-	res := NewResult(l.start, l.start, l.timer, l.errors, atomic.LoadInt32(&lt.activeClients))
+func (l *summaryLogger) print(lt *Runner, end time.Time) {
+	total, _ := l.stats.collect(false)
+	res := newResultAt(end, l.start, l.start, total, lt.ActiveClients(), l.percentiles)
+	errs := total.errors.top(l.topErrors)
 	line := l.format.Format(res)
 	l.writer.Write([]byte(line))
+	if len(errs) > 0 {
+		for _, s := range l.format.FormatErrors(errs) {
+			l.writer.Write([]byte(s))
+		}
+	}
+	if len(res.Metrics) > 0 {
+		for _, s := range l.format.FormatMetrics(res.Metrics) {
+			l.writer.Write([]byte(s))
+		}
+	}
+	for _, w := range l.warnings() {
+		l.writer.Write([]byte("\nWARNING: " + w + "\n"))
+	}
+}
+
+// warnings returns the warnings about the reliability of the results
+func (l *summaryLogger) warnings() []string {
+	if l.health == nil {
+		return nil
+	}
+	return l.health.warnings()
 }
 
+// histogram returns a copy of the latency histogram of all test iterations
+func (l *summaryLogger) histogram() *Histogram {
+	total, _ := l.stats.collect(false)
+	return total.hist
+}
+
+// summary returns the summary written at the end of the run, or collects the results so far before the end
+func (l *summaryLogger) summary() *summary {
+	if l.final != nil {
+		return l.final
+	}
+	return l.summaryAt(time.Now())
+}
+
+// summaryAt collects the results of the run ending at end
+func (l *summaryLogger) summaryAt(end time.Time) *summary {
+	total, keys := l.stats.collect(false)
+	active := l.runner.ActiveClients()
+	s := &summary{
+		Config:      newRunConfig(l.runner, l.percentiles, l.precision, end),
+		Environment: newEnvironment(),
+		Total:       newResultAt(end, l.start, l.start, total, active, l.percentiles),
+		Errors:      total.errors.top(l.topErrors),
+		Warnings:    l.warnings(),
+		Histogram:   total.hist,
+	}
+	var sorted []stepKey
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return stepKeyLess(sorted[i], sorted[j])
+	})
+	for _, key := range sorted {
+		res := newResultAt(end, l.start, l.start, keys[key], active, l.percentiles)
+		res.Test = key.test
+		res.Step = key.step
+		if key.step == "" {
+			s.Tests = append(s.Tests, res)
+		} else {
+			s.Steps = append(s.Steps, res)
+		}
+	}
+	s.Thresholds = l.thresholds.evaluate(s, l.percentiles)
+	return s
+}
+
+// MdFormat formats the results as markdown table, with the health of the load generator as extra columns if Health is set
 type MdFormat struct {
+	Percentiles Percentiles
+	Health      bool
 }
 
 func (f *MdFormat) FormatHeader() []string {
-	return []string{
-		"| time     | count    | mean      | p75       | p95       | rate       | errs     | clients  |\n",
-		"| -------- | -------- | --------- | --------- | --------- | ---------- | -------- | -------- |\n"}
+	names := []string{"time    ", "count   ", "min      ", "mean     "}
+	for _, n := range percentilesOrDefault(f.Percentiles).Names() {
+		names = append(names, fmt.Sprintf("%-9s", n))
+	}
+	names = append(names, "max      ", "rate      ", "errs    ", "clients ")
+	if f.Health {
+		names = append(names, "cpu %    ", "goroutines", "heap MB  ", "gc max   ", "lag p99  ")
+	}
+	header := "|"
+	line := "|"
+	for _, n := range names {
+		header += " " + n + " |"
+		line += " " + strings.Repeat("-", len(n)) + " |"
+	}
+	return []string{header + "\n", line + "\n"}
 }
 
 func (f *MdFormat) getFormatString(delim string) string {
-	return delim + " %8d " + delim + " %8d " + delim + " %9.1f " + delim + " %9.1f " + delim + " %9.1f " + delim + " %10.2f " + delim + " %8d " + delim + " %8d " + delim + "\n"
+	s := delim + " %8d " + delim + " %8d " + delim + " %9.1f " + delim + " %9.1f " + delim
+	for range percentilesOrDefault(f.Percentiles) {
+		s += " %9.1f " + delim
+	}
+	return s + " %9.1f " + delim + " %10.2f " + delim + " %8d " + delim + " %8d " + delim + "\n"
 }
 
 func (f *MdFormat) Format(res *result) string {
 	delim := "|"
 	fmtString := f.getFormatString(delim)
-	return fmt.Sprintf(fmtString, int64(res.Time.Seconds()), res.Count, res.Mean, res.P75, res.P95, res.Rate, res.ErrCount, res.ActiveClients)
+	args := []interface{}{int64(res.Time.Seconds()), res.Count, res.Min, res.Mean}
+	args = append(args, percentileArgs(f.Percentiles, res)...)
+	args = append(args, res.Max, res.Rate, res.ErrCount, res.ActiveClients)
+	if f.Health {
+		h := healthOrZero(res.Health)
+		fmtString = strings.TrimSuffix(fmtString, "\n") + " %9.0f | %10d | %9.1f | %9.1f | %9.1f |\n"
+		args = append(args, h.CPU, h.Goroutines, h.HeapMb, h.GCPauseMax, h.StartLag)
+	}
+	return fmt.Sprintf(fmtString, args...)
+}
+
+// healthOrZero returns h or zero values for the periods before the health was sampled
+func healthOrZero(h *healthResult) *healthResult {
+	if h == nil {
+		return &healthResult{}
+	}
+	return h
+}
+
+// FormatErrors formats the error classes as markdown table
+func (f *MdFormat) FormatErrors(errs []*errorStat) []string {
+	lines := []string{
+		"\n| error class          | count    | first        | last         | example                                  |\n",
+		"| -------------------- | -------- | ------------ | ------------ | ---------------------------------------- |\n"}
+	for _, e := range errs {
+		lines = append(lines, fmt.Sprintf("| %-20s | %8d | %12s | %12s | %-40s |\n", truncate(e.Class, 20), e.Count, e.First.Format(ERROR_TIME_FORMAT), e.Last.Format(ERROR_TIME_FORMAT), truncate(e.Example, 40)))
+	}
+	return lines
+}
+
+// FormatMetrics formats the custom metrics as markdown table, the period logger writes its header again after them
+func (f *MdFormat) FormatMetrics(ms []*metricResult) []string {
+	header := "\n| metric               | type    | count    | value      | rate       | min        | mean       |"
+	line := "| -------------------- | ------- | -------- | ---------- | ---------- | ---------- | ---------- |"
+	names := append(percentilesOrDefault(f.Percentiles).Names(), "max")
+	for _, n := range names {
+		header += fmt.Sprintf(" %-10s |", n)
+		line += " ---------- |"
+	}
+	lines := []string{header + "\n", line + "\n"}
+	for _, m := range ms {
+		s := fmt.Sprintf("| %-20s | %-7s | %8d | %10.2f | %10.2f | %10.1f | %10.1f |", truncate(m.Name, 20), m.Kind, m.Count, m.Value, m.Rate, m.Min, m.Mean)
+		for i := range percentilesOrDefault(f.Percentiles) {
+			if i < len(m.Percentiles) {
+				s += fmt.Sprintf(" %10.1f |", m.Percentiles[i])
+			} else {
+				s += "            |"
+			}
+		}
+		lines = append(lines, s+fmt.Sprintf(" %10.1f |\n", m.Max))
+	}
+	return lines
 }
 
 var _ Format = &MdFormat{}
 
+// CsvFormat formats the results as comma separated values, with the health of the load generator as extra columns if Health is set
 type CsvFormat struct {
+	Percentiles Percentiles
+	Health      bool
 }
 
 var _ Format = &CsvFormat{}
 
 func (f *CsvFormat) FormatHeader() []string {
-	return []string{"time,count,mean,p75,p95,rate,errs\n"}
+	names := append([]string{"time", "count", "min", "mean"}, percentilesOrDefault(f.Percentiles).Names()...)
+	names = append(names, "max", "rate", "errs", "clients")
+	if f.Health {
+		names = append(names, "cpu_percent", "goroutines", "heap_mb", "gc_pause_max_ms", "start_lag_p99_ms")
+	}
+	return []string{strings.Join(names, ",") + "\n"}
 }
 
 func (f *CsvFormat) Format(res *result) string {
-	return fmt.Sprintf("%d,%d,%.1f,%.1f,%.1f,%.2f,%d\n", int64(res.Time.Seconds()), res.Count, res.Mean, res.P75, res.P95, res.Rate, res.ErrCount)
+	s := fmt.Sprintf("%d,%d,%.1f,%.1f,", int64(res.Time.Seconds()), res.Count, res.Min, res.Mean)
+	for _, v := range percentileArgs(f.Percentiles, res) {
+		s += fmt.Sprintf("%.1f,", v)
+	}
+	s += fmt.Sprintf("%.1f,%.2f,%d,%d", res.Max, res.Rate, res.ErrCount, res.ActiveClients)
+	if f.Health {
+		h := healthOrZero(res.Health)
+		s += fmt.Sprintf(",%.0f,%d,%.1f,%.1f,%.1f", h.CPU, h.Goroutines, h.HeapMb, h.GCPauseMax, h.StartLag)
+	}
+	return s + "\n"
+}
+
+// FormatErrors formats the error classes as csv separated from the results by an empty line
+func (f *CsvFormat) FormatErrors(errs []*errorStat) []string {
+	lines := []string{"\nclass,count,first,last,example\n"}
+	for _, e := range errs {
+		lines = append(lines, fmt.Sprintf("%s,%d,%s,%s,%s\n", csvQuote(e.Class), e.Count, e.First.Format(ERROR_TIME_FORMAT), e.Last.Format(ERROR_TIME_FORMAT), csvQuote(e.Example)))
+	}
+	return lines
+}
+
+// FormatMetrics formats the custom metrics as csv separated from the results by an empty line
+func (f *CsvFormat) FormatMetrics(ms []*metricResult) []string {
+	names := append([]string{"metric", "type", "count", "value", "rate", "min", "mean"}, percentilesOrDefault(f.Percentiles).Names()...)
+	lines := []string{"\n" + strings.Join(append(names, "max"), ",") + "\n"}
+	for _, m := range ms {
+		s := fmt.Sprintf("%s,%s,%d,%g,%.2f,%g,%.1f,", csvQuote(m.Name), m.Kind, m.Count, m.Value, m.Rate, m.Min, m.Mean)
+		for i := range percentilesOrDefault(f.Percentiles) {
+			if i < len(m.Percentiles) {
+				s += fmt.Sprintf("%g", m.Percentiles[i])
+			}
+			s += ","
+		}
+		lines = append(lines, s+fmt.Sprintf("%g\n", m.Max))
+	}
+	return lines
+}
+
+// ERROR_TIME_FORMAT is the time format for error occurrences
+const ERROR_TIME_FORMAT = "15:04:05.000"
+
+func truncate(s string, n int) string {
+	s = strings.Replace(s, "\n", " ", -1)
+	if len(s) > n {
+		return s[:n-3] + "..."
+	}
+	return s
+}
+
+func csvQuote(s string) string {
+	if strings.ContainsAny(s, ",\"\n\r") {
+		return "\"" + strings.Replace(s, "\"", "\"\"", -1) + "\""
+	}
+	return s
+}
+
+func percentilesOrDefault(ps Percentiles) Percentiles {
+	if len(ps) == 0 {
+		return DefaultPercentiles
+	}
+	return ps
+}
+
+// percentileArgs returns the percentile values of the result padded to the number of format columns
+func percentileArgs(ps Percentiles, res *result) []interface{} {
+	ps = percentilesOrDefault(ps)
+	args := make([]interface{}, len(ps))
+	for i := range ps {
+		v := 0.0
+		if i < len(res.Percentiles) {
+			v = res.Percentiles[i]
+		}
+		args[i] = v
+	}
+	return args
 }
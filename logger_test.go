@@ -2,7 +2,9 @@ package lotgo
 
 import (
 	"bytes"
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 	"time"
 )
@@ -10,28 +12,60 @@ import (
 func TestMdFormat(t *testing.T) {
 	f := MdFormat{}
 	expectedHeader := []string{
-		"| time     | count    | mean      | p75       | p95       | rate       | errs     | clients  |\n",
-		"| -------- | -------- | --------- | --------- | --------- | ---------- | -------- | -------- |\n"}
+		"| time     | count    | min       | mean      | p50       | p90       | p99       | p99.9     | max       | rate       | errs     | clients  |\n",
+		"| -------- | -------- | --------- | --------- | --------- | --------- | --------- | --------- | --------- | ---------- | -------- | -------- |\n"}
 	assert.Equal(t, expectedHeader, f.FormatHeader())
-	res := &result{Time: time.Second * 120, Count: 1000, Mean: 123.453243, P75: 134.3212412312, P95: 199.022311, Rate: 100.32332, ErrCount: 10, ActiveClients: 19}
-	assert.Equal(t, "|      120 |     1000 |     123.5 |     134.3 |     199.0 |     100.32 |       10 |       19 |\n", f.Format(res))
-	res = &result{Time: time.Second * 120, Count: 1000, Mean: 120000.453243, P75: 134000.3212412312, P95: 199000.022311, Rate: 100000.32332, ErrCount: 10, ActiveClients: 2}
-	assert.Equal(t, "|      120 |     1000 |  120000.5 |  134000.3 |  199000.0 |  100000.32 |       10 |        2 |\n", f.Format(res))
-	res = &result{Time: 0, Count: 0, Mean: 0, P75: 0, P95: 0.00, Rate: 0, ErrCount: 0}
-	assert.Equal(t, "|        0 |        0 |       0.0 |       0.0 |       0.0 |       0.00 |        0 |        0 |\n", f.Format(res))
+	res := &result{Time: time.Second * 120, Count: 1000, Min: 10.12, Mean: 123.453243, Percentiles: []float64{120.01, 134.3212412312, 199.022311, 250.66}, Max: 301.2, Rate: 100.32332, ErrCount: 10, ActiveClients: 19}
+	assert.Equal(t, "|      120 |     1000 |      10.1 |     123.5 |     120.0 |     134.3 |     199.0 |     250.7 |     301.2 |     100.32 |       10 |       19 |\n", f.Format(res))
+	res = &result{Time: time.Second * 120, Count: 1000, Min: 100000.1, Mean: 120000.453243, Percentiles: []float64{110000.1, 134000.3212412312, 199000.022311, 199000.1}, Max: 200000.0, Rate: 100000.32332, ErrCount: 10, ActiveClients: 2}
+	assert.Equal(t, "|      120 |     1000 |  100000.1 |  120000.5 |  110000.1 |  134000.3 |  199000.0 |  199000.1 |  200000.0 |  100000.32 |       10 |        2 |\n", f.Format(res))
+	res = &result{}
+	assert.Equal(t, "|        0 |        0 |       0.0 |       0.0 |       0.0 |       0.0 |       0.0 |       0.0 |       0.0 |       0.00 |        0 |        0 |\n", f.Format(res))
+}
+
+func TestMdFormat_CustomPercentiles(t *testing.T) {
+	f := MdFormat{Percentiles: Percentiles{75, 95}}
+	assert.Equal(t, "| time     | count    | min       | mean      | p75       | p95       | max       | rate       | errs     | clients  |\n", f.FormatHeader()[0])
+	res := &result{Time: time.Second, Count: 10, Min: 1, Mean: 2, Percentiles: []float64{3, 4}, Max: 5, Rate: 10, ErrCount: 0, ActiveClients: 1}
+	assert.Equal(t, "|        1 |       10 |       1.0 |       2.0 |       3.0 |       4.0 |       5.0 |      10.00 |        0 |        1 |\n", f.Format(res))
 }
 
 func TestCsvFormat(t *testing.T) {
 	f := CsvFormat{}
-	assert.Equal(t, []string{"time,count,mean,p75,p95,rate,errs\n"}, f.FormatHeader())
-	res := &result{Time: time.Second * 120, Count: 1000, Mean: 123.453243, P75: 134.3212412312, P95: 199.022311, Rate: 100.32332, ErrCount: 10}
-	assert.Equal(t, "120,1000,123.5,134.3,199.0,100.32,10\n", f.Format(res))
+	assert.Equal(t, []string{"time,count,min,mean,p50,p90,p99,p99.9,max,rate,errs,clients\n"}, f.FormatHeader())
+	res := &result{Time: time.Second * 120, Count: 1000, Min: 10.12, Mean: 123.453243, Percentiles: []float64{120.01, 134.3212412312, 199.022311, 250.66}, Max: 301.2, Rate: 100.32332, ErrCount: 10, ActiveClients: 5}
+	assert.Equal(t, "120,1000,10.1,123.5,120.0,134.3,199.0,250.7,301.2,100.32,10,5\n", f.Format(res))
+}
+
+func TestPercentiles_Set(t *testing.T) {
+	var p Percentiles
+	assert.NoError(t, p.Set("50, 99,99.99"))
+	assert.Equal(t, Percentiles{50, 99, 99.99}, p)
+	assert.Equal(t, "50,99,99.99", p.String())
+	assert.Equal(t, []string{"p50", "p99", "p99.99"}, p.Names())
+	assert.Error(t, p.Set("50,abc"))
+	assert.Error(t, p.Set("101"))
 }
 
 func TestSummaryLoggerGracePeriod(t *testing.T) {
 	buf := bytes.NewBuffer(make([]byte, 0))
-	l := NewSummaryLogger(time.Millisecond*100, buf, &CsvFormat{})
+	l := NewSummaryLogger(time.Millisecond*100, buf, &CsvFormat{}, DefaultPercentiles, DefaultPrecision, 10)
 	assert.False(t, l.checkActive())
 	time.Sleep(time.Millisecond * 100)
 	assert.True(t, l.checkActive())
 }
+
+func TestSummaryLogger_ErrorTable(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	l := NewSummaryLogger(0, buf, &CsvFormat{}, DefaultPercentiles, DefaultPrecision, 1)
+	l.Started(&Runner{})
+	l.Sample(&Sample{Iteration: Iteration{Start: time.Now(), Duration: time.Millisecond}})
+	l.Sample(&Sample{Iteration: Iteration{Start: time.Now(), Class: "timeout"}, Err: errors.New("read timeout")})
+	l.Sample(&Sample{Iteration: Iteration{Start: time.Now(), Class: "timeout"}, Err: errors.New("write timeout")})
+	l.Sample(&Sample{Iteration: Iteration{Start: time.Now(), Class: "HTTP 503"}, Err: errors.New("unavailable, try again")})
+	l.Finished()
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "class,count,first,last,example", lines[3])
+	assert.Regexp(t, `^timeout,2,[0-9:.]+,[0-9:.]+,read timeout$`, lines[4])
+	assert.Equal(t, "", lines[5])
+}
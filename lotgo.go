@@ -1,14 +1,14 @@
 package lotgo
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"github.com/Sirupsen/logrus"
 	"io"
 	"os"
 	"runtime"
 	"time"
-	"github.com/Sirupsen/logrus"
-	"bytes"
 )
 
 var clients int
@@ -22,21 +22,67 @@ var errorLog string
 var maxprocs int
 var rampup time.Duration
 var terminalUi bool
+var percentiles = DefaultPercentiles
+var precision int
+var topErrors int
+var errorSample = 1.0
+var errorRate int
+var periodFormat = "md"
+var summaryFormat string
+var metricsAddr string
+var webAddr string
+var outputs Outputs
+var tags Tags
+var reportFile string
+var samplesFile string
+var baselineFile string
+var tolerances = DefaultTolerances
+var healthInterval time.Duration
+var maxStartLag time.Duration
+var uiWindow time.Duration
 var myui *ui
+var myBaseline *baselineComparer
+var thresholds Thresholds
+var junitFile string
+var jobSummaryFile string
+var myThresholds *thresholdChecker
+var params Params
 
 // NewFromCommandline creates new runner using commandline arguments
 func NewFromCommandline() *Runner {
 	flag.IntVar(&clients, "clients", 1, "Number of clients to simulate")
 	flag.IntVar(&runs, "runs", 1, "Number of runs per client")
 	flag.StringVar(&testName, "test", "", "Name of the test, required. Allowed values: "+AllTests())
+	flag.Var(&params, "param", "Test parameter as name=value, can be repeated, e.g. file=scenario.yaml for http/scenario")
 	flag.DurationVar(&duration, "duration", 0, "Duration of the test, overrides runs")
 	flag.DurationVar(&period, "period", time.Second*10, "Period for logging the results")
-	flag.StringVar(&summaryFile, "summaryFile", "", "Csv summary file, default stdout")
-	flag.StringVar(&errorLog, "error", "", "Error log file, default stdout")
+	flag.StringVar(&summaryFile, "summaryFile", "", "Summary file, default stdout")
+	flag.StringVar(&periodFormat, "period-format", "md", "Format of the period results: md, csv or jsonl")
+	flag.StringVar(&summaryFormat, "summary-format", "", "Format of the summary: text, md, csv, jsonl or json, default text on stdout and csv in -summaryFile")
+	flag.StringVar(&errorLog, "error", "", "Error log file with every failed iteration as a JSON line, default none")
+	flag.Float64Var(&errorSample, "errorSample", 1, "Fraction of failed iterations written to the error log")
+	flag.IntVar(&errorRate, "errorRate", 100, "Maximum number of lines per second written to the error log, 0 for unlimited")
 	flag.DurationVar(&sleep, "sleep", 0, "Time to sleep between test calls")
 	flag.IntVar(&maxprocs, "maxprocs", 10, "Maximum number of goprocs")
 	flag.DurationVar(&rampup, "rampup", 0, "Time to rampup all clients running")
 	flag.BoolVar(&terminalUi, "termui", false, "Use terminal UI")
+	flag.DurationVar(&uiWindow, "ui-window", UI_WINDOW, "Sliding window for the latency, error rate and throughput values of the terminal UI")
+	flag.StringVar(&webAddr, "web", "", "Address for serving a live dashboard with stop and load controls in the browser e.g. 8089, a port without a host is bound to localhost, anyone who can open the dashboard can control the run")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "Address for serving Prometheus metrics during the test, e.g. :9100")
+	flag.Var(&outputs, "output", "Metrics output as type=url, can be repeated, e.g. influx=http://localhost:8086/write?db=lotgo, influx=udp://localhost:8089?mode=sample or statsd=udp://localhost:8125")
+	flag.Var(&tags, "tags", "Tags for the metrics outputs, e.g. run=42,env=staging")
+	flag.StringVar(&reportFile, "report", "", "HTML report file written at the end of the test")
+	flag.StringVar(&baselineFile, "baseline", "", "JSON summary of a previous run to compare the results against, regressions exit with status 1")
+	flag.Var(&tolerances, "tolerances", "Allowed changes against the baseline as metric=percent: latency, mean, p99 etc., rate and error_rate in percentage points")
+	flag.StringVar(&samplesFile, "samples", "", "File recording every iteration as gzip compressed CSV for lotgo analyze")
+	flag.Var(&thresholds, "threshold", "Pass or fail criterion as [test[/step]:]metric<value, can be repeated, e.g. p99<500 or error_rate<1, failures exit with status 1")
+	flag.StringVar(&junitFile, "junit", "", "JUnit XML file with every threshold as a test case")
+	flag.StringVar(&jobSummaryFile, "job-summary", "", "Markdown file the results and threshold verdicts are appended to, e.g. $GITHUB_STEP_SUMMARY")
+	flag.DurationVar(&healthInterval, "health-interval", 0, "Interval for sampling the runtime stats and the iteration start lag of the load generator, e.g. 1s, shown as extra period columns, default disabled")
+	flag.DurationVar(&maxStartLag, "max-start-lag", 50*time.Millisecond, "Iteration start lag p99 above which the load generator is reported as saturated")
+	flag.Var(&percentiles, "percentiles", "Comma separated list of latency percentiles to report")
+	flag.IntVar(&precision, "precision", DefaultPrecision, "Latency histogram precision in significant digits, 1-5")
+	flag.IntVar(&topErrors, "topErrors", 10, "Number of most common error classes in the summary, 0 for all")
 	flag.Parse()
 
 	runtime.GOMAXPROCS(maxprocs)
@@ -46,6 +92,26 @@ func NewFromCommandline() *Runner {
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
+	if precision < 1 || precision > 5 {
+		fmt.Println("-precision must be between 1 and 5")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	if periodFormat == "json" {
+		fmt.Println("-period-format json is not supported, use jsonl")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	for _, name := range []string{periodFormat, summaryFormat} {
+		if name == "" {
+			continue
+		}
+		if _, err := NewFormat(name, percentiles); err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			os.Exit(1)
+		}
+	}
 	if duration > 0 {
 		runs = 0
 	}
@@ -75,8 +141,6 @@ func NewFromCommandline() *Runner {
 		}
 		fmt.Printf("Writing errors to '%s'\n", errorLog)
 		ew = f
-	} else {
-		ew = os.Stderr
 	}
 	return New(clients, runs, duration, sleep, period, test, sw, ew, rampup, terminalUi)
 }
@@ -90,23 +154,120 @@ func New(clients int, runs int, duration time.Duration, sleep time.Duration, per
 	if terminalUi {
 		out = tmpOut
 	}
-	plogger := NewPeriodLogger(period, out, &MdFormat{})
-	var slogger Listener
+	if precision == 0 {
+		precision = DefaultPrecision
+	}
+	pformat, err := NewFormat(periodFormat, percentiles)
+	if err != nil {
+		panic(err)
+	}
+	sname := summaryFormat
+	if sname == "" {
+		sname = "text"
+		if sw != nil {
+			sname = "csv"
+		}
+	}
+	sformat, err := NewFormat(sname, percentiles)
+	if err != nil {
+		panic(err)
+	}
+	plogger := NewPeriodLogger(period, out, pformat, percentiles, precision)
+	var slogger *summaryLogger
 	if sw != nil {
-		slogger = NewSummaryLogger(duration/5, sw, &CsvFormat{})
+		slogger = NewSummaryLogger(duration/5, sw, sformat, percentiles, precision, topErrors)
+	} else if termui {
+		// the ui owns the terminal until the end, the summary is printed on stdout after it has closed
+		slogger = NewSummaryLogger(duration/5, summaryOut, sformat, percentiles, precision, topErrors)
 	} else {
-		slogger = NewSummaryLogger(duration/5, out, &CsvFormat{})
+		slogger = NewSummaryLogger(duration/5, out, sformat, percentiles, precision, topErrors)
 	}
+	slogger.thresholds = thresholds
 	allListeners := Listeners{plogger, slogger}
+	var health *healthMonitor
+	if healthInterval > 0 {
+		health = NewHealthMonitor(healthInterval, maxStartLag)
+		plogger.setHealth(health)
+		slogger.health = health
+		allListeners = allListeners.AddSample(health)
+	}
 	if termui {
-		myui = NewUi()
-		allListeners = allListeners.Add(myui)
+		myui = NewUi(percentiles, precision, uiWindow)
+		myui.health = health
+		allListeners = allListeners.AddSample(myui)
+	}
+	if errw != nil {
+		allListeners = allListeners.AddSample(NewErrorLogger(errw, errorSample, errorRate))
+	}
+	if webAddr != "" {
+		allListeners = allListeners.AddSample(NewWebDashboard(webAddr, precision, uiWindow))
+	}
+	if metricsAddr != "" {
+		allListeners = allListeners.AddSample(NewPrometheusListener(metricsAddr))
+	}
+	if reportFile != "" {
+		f, err := os.Create(reportFile)
+		if err != nil {
+			LOG().Fatalf("Failed to open '%s', reason %v", reportFile, err)
+		}
+		LOG().Infof("Writing report to '%s'", reportFile)
+		allListeners = allListeners.AddSample(NewReportWriter(f, plogger, slogger))
+	}
+	if baselineFile != "" {
+		baseline, err := readSummary(baselineFile)
+		if err != nil {
+			LOG().Fatalf("Failed to read baseline, reason %v", err)
+		}
+		myBaseline = newBaselineComparer(baseline, tolerances, out, slogger)
+		allListeners = allListeners.AddSample(myBaseline)
+	}
+	if len(thresholds) > 0 {
+		myThresholds = newThresholdChecker(slogger)
+		allListeners = allListeners.AddSample(myThresholds)
+	}
+	if junitFile != "" {
+		f, err := os.Create(junitFile)
+		if err != nil {
+			LOG().Fatalf("Failed to open '%s', reason %v", junitFile, err)
+		}
+		LOG().Infof("Writing JUnit XML to '%s'", junitFile)
+		allListeners = allListeners.AddSample(newJUnitWriter(f, slogger))
+	}
+	if jobSummaryFile != "" {
+		f, err := os.OpenFile(jobSummaryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			LOG().Fatalf("Failed to open '%s', reason %v", jobSummaryFile, err)
+		}
+		LOG().Infof("Writing job summary to '%s'", jobSummaryFile)
+		allListeners = allListeners.AddSample(newJobSummaryWriter(f, slogger))
 	}
-	return &Runner{clients: clients, runs: runs, duration: duration, sleep: sleep, test: test, allListeners: allListeners, errout: errw, rampup: rampup}
+	if samplesFile != "" {
+		f, err := os.Create(samplesFile)
+		if err != nil {
+			LOG().Fatalf("Failed to open '%s', reason %v", samplesFile, err)
+		}
+		LOG().Infof("Writing samples to '%s'", samplesFile)
+		allListeners = allListeners.AddSample(NewSamplesWriter(f))
+	}
+	for _, o := range outputs {
+		l, err := NewOutputListener(o, tags, period, percentiles, precision)
+		if err != nil {
+			LOG().Fatalf("Invalid output '%s': %v", o, err)
+		}
+		allListeners = allListeners.AddSample(l)
+	}
+	name := testName
+	if name == "" {
+		name = testTypeName(test)
+	}
+	return &Runner{clients: clients, runs: runs, duration: duration, sleep: sleep, period: period, test: test, name: name, allListeners: allListeners, rampup: rampup, params: params}
 }
 
-// Run runs the test based on the commandline arguments
+// Run runs the test based on the commandline arguments, or a sub command if one is given as the first argument
 func Run() {
+	if runCommand(os.Args[1:]) {
+		return
+	}
 	LOG().Infof("Starting test ...")
 	runner := NewFromCommandline()
 	go runner.Run()
@@ -119,6 +280,7 @@ func Run() {
 		}
 		LOG().Infof("Ui finished")
 		LogToErr()
+		os.Stdout.Write(summaryOut.Bytes())
 	} else {
 		LogToErr()
 		for !runner.IsDone() {
@@ -126,9 +288,22 @@ func Run() {
 		}
 	}
 	LOG().Infof("Test done!")
+	failed := false
+	if myBaseline != nil && myBaseline.Regressions() > 0 {
+		LOG().Errorf("%d regressions against the baseline '%s'", myBaseline.Regressions(), baselineFile)
+		failed = true
+	}
+	if myThresholds != nil && myThresholds.Failures() > 0 {
+		LOG().Errorf("%d of %d thresholds failed", myThresholds.Failures(), len(thresholds))
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
 }
 
 var tmpOut *bytes.Buffer = new(bytes.Buffer)
+var summaryOut *bytes.Buffer = new(bytes.Buffer)
 
 func LogToBuffer() {
 	logrus.SetOutput(tmpOut)
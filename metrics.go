@@ -0,0 +1,294 @@
+package lotgo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	METRIC_COUNTER = "counter"
+	METRIC_GAUGE   = "gauge"
+	METRIC_TREND   = "trend"
+	METRIC_CHECK   = "check"
+)
+
+// MetricSample is a single update of a custom metric, Step is the step in which a client updated it
+type MetricSample struct {
+	Time   time.Time
+	Name   string
+	Kind   string
+	Value  float64
+	Client int
+	Test   string
+	Step   string
+}
+
+// MetricListener is implemented by listeners which receive the custom metrics of the tests
+type MetricListener interface {
+	Metric(m *MetricSample)
+}
+
+// Counter is a custom metric counting events, e.g. items added to carts
+type Counter struct {
+	runner *Runner
+	name   string
+	value  int64
+}
+
+// Add adds n to the counter
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+	c.runner.metric(c.name, METRIC_COUNTER, float64(n))
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Value returns the total count
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// Gauge is a custom metric with the latest value of something, e.g. the queue length reported by the server
+type Gauge struct {
+	runner *Runner
+	name   string
+	bits   uint64
+}
+
+// Set sets the current value
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+	g.runner.metric(g.name, METRIC_GAUGE, v)
+}
+
+// Value returns the latest value
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// Trend is a custom metric with the distribution of recorded values, e.g. messages received per push
+type Trend struct {
+	runner *Runner
+	name   string
+}
+
+// Record records a value, the values are kept in a histogram of integers
+func (t *Trend) Record(v int64) {
+	t.runner.metric(t.name, METRIC_TREND, float64(v))
+}
+
+// RecordDuration records a duration in milliseconds
+func (t *Trend) RecordDuration(d time.Duration) {
+	t.Record(int64(d / time.Millisecond))
+}
+
+// customMetrics holds the metric handles of a runner by name
+type customMetrics struct {
+	sync.Mutex
+	counters map[string]*Counter
+	gauges   map[string]*Gauge
+	trends   map[string]*Trend
+}
+
+// Counter returns the counter with the given name, the same counter is shared by all clients
+func (runner *Runner) Counter(name string) *Counter {
+	m := runner.customMetrics()
+	m.Lock()
+	defer m.Unlock()
+	c, ok := m.counters[name]
+	if !ok {
+		c = &Counter{runner: runner.shared(), name: name}
+		m.counters[name] = c
+	}
+	return c
+}
+
+// Gauge returns the gauge with the given name, the same gauge is shared by all clients
+func (runner *Runner) Gauge(name string) *Gauge {
+	m := runner.customMetrics()
+	m.Lock()
+	defer m.Unlock()
+	g, ok := m.gauges[name]
+	if !ok {
+		g = &Gauge{runner: runner.shared(), name: name}
+		m.gauges[name] = g
+	}
+	return g
+}
+
+// Histogram returns the trend with the given name, the same trend is shared by all clients
+func (runner *Runner) Histogram(name string) *Trend {
+	m := runner.customMetrics()
+	m.Lock()
+	defer m.Unlock()
+	t, ok := m.trends[name]
+	if !ok {
+		t = &Trend{runner: runner.shared(), name: name}
+		m.trends[name] = t
+	}
+	return t
+}
+
+func (runner *Runner) customMetrics() *customMetrics {
+	shared := runner.shared()
+	shared.metricsOnce.Do(func() {
+		shared.metrics = &customMetrics{counters: map[string]*Counter{}, gauges: map[string]*Gauge{}, trends: map[string]*Trend{}}
+	})
+	return shared.metrics
+}
+
+func (runner *Runner) metric(name string, kind string, v float64) {
+	if ml, ok := runner.allListeners.(MetricListener); ok {
+		ml.Metric(&MetricSample{Time: time.Now(), Name: name, Kind: kind, Value: v, Client: runner.Client(),
+			Test: runner.name, Step: runner.step})
+	}
+}
+
+// metricStat aggregates the updates of a custom metric
+type metricStat struct {
+	kind  string
+	count int64
+	sum   float64
+	last  float64
+	min   float64
+	max   float64
+	hist  *Histogram
+}
+
+// metricKey identifies a custom metric, metrics of different kinds can share a name
+type metricKey struct {
+	name string
+	kind string
+}
+
+// metricStats collects the custom metrics by name and kind
+type metricStats map[metricKey]*metricStat
+
+func (s metricStats) add(m *MetricSample, precision int) {
+	key := metricKey{name: m.Name, kind: m.Kind}
+	stat, ok := s[key]
+	if !ok {
+		stat = &metricStat{kind: m.Kind, min: m.Value, max: m.Value}
+		if m.Kind == METRIC_TREND {
+			stat.hist = NewHistogram(precision)
+		}
+		s[key] = stat
+	}
+	stat.count++
+	stat.sum += m.Value
+	stat.last = m.Value
+	stat.min = math.Min(stat.min, m.Value)
+	stat.max = math.Max(stat.max, m.Value)
+	if stat.hist != nil {
+		stat.hist.RecordValue(int64(m.Value))
+	}
+}
+
+func (s metricStats) merge(other metricStats) {
+	for key, o := range other {
+		stat, ok := s[key]
+		if !ok {
+			c := *o
+			if o.hist != nil {
+				c.hist = o.hist.Copy()
+			}
+			s[key] = &c
+			continue
+		}
+		stat.count += o.count
+		stat.sum += o.sum
+		stat.last = o.last
+		stat.min = math.Min(stat.min, o.min)
+		stat.max = math.Max(stat.max, o.max)
+		if stat.hist != nil && o.hist != nil {
+			stat.hist.Merge(o.hist)
+		}
+	}
+}
+
+// metricResult is the summary of a custom metric. Value is the total of a counter, the latest value of a gauge
+// and the pass percentage of a check, Rate is the counter total per second.
+type metricResult struct {
+	Name        string
+	Kind        string
+	Count       int64
+	Value       float64
+	Rate        float64
+	Min         float64
+	Mean        float64
+	Percentiles []float64
+	Max         float64
+}
+
+// results returns the metrics sorted by name and kind for a period of length p
+func (s metricStats) results(p time.Duration, ps Percentiles) []*metricResult {
+	var keys []metricKey
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].kind < keys[j].kind
+	})
+	var list []*metricResult
+	for _, key := range keys {
+		stat := s[key]
+		res := &metricResult{Name: key.name, Kind: stat.kind, Count: stat.count, Min: stat.min, Max: stat.max, Mean: stat.sum / float64(stat.count)}
+		switch stat.kind {
+		case METRIC_COUNTER:
+			res.Value = stat.sum
+			if p > 0 {
+				res.Rate = stat.sum / p.Seconds()
+			}
+		case METRIC_GAUGE:
+			res.Value = stat.last
+		case METRIC_CHECK:
+			res.Value = stat.sum * 100 / float64(stat.count)
+		case METRIC_TREND:
+			for _, v := range ps {
+				res.Percentiles = append(res.Percentiles, float64(stat.hist.ValueAtPercentile(v)))
+			}
+		}
+		list = append(list, res)
+	}
+	return list
+}
+
+// formatMetricsLine formats the metrics compactly on one line, e.g. "metrics: cart_items 120 (12.0/s), queue 4"
+func formatMetricsLine(ms []*metricResult, ps Percentiles) string {
+	var parts []string
+	for _, m := range ms {
+		switch m.Kind {
+		case METRIC_COUNTER:
+			parts = append(parts, fmt.Sprintf("%s %g (%.1f/s)", m.Name, m.Value, m.Rate))
+		case METRIC_GAUGE:
+			parts = append(parts, fmt.Sprintf("%s %g (min %g, max %g)", m.Name, m.Value, m.Min, m.Max))
+		case METRIC_CHECK:
+			parts = append(parts, fmt.Sprintf("%s %.2f%% passed (%d of %d)", m.Name, m.Value, checkPasses(m), m.Count))
+		case METRIC_TREND:
+			s := fmt.Sprintf("%s n=%d mean %.1f", m.Name, m.Count, m.Mean)
+			for i, name := range percentilesOrDefault(ps).Names() {
+				if i < len(m.Percentiles) {
+					s += fmt.Sprintf(" %s %g", name, m.Percentiles[i])
+				}
+			}
+			parts = append(parts, s+fmt.Sprintf(" max %g", m.Max))
+		}
+	}
+	return "metrics: " + strings.Join(parts, ", ") + "\n"
+}
+
+// checkPasses returns the number of passed checks of a check result
+func checkPasses(m *metricResult) int64 {
+	return int64(math.Round(m.Mean * float64(m.Count)))
+}
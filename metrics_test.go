@@ -0,0 +1,133 @@
+package lotgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+type metricTest struct {
+	myTest
+}
+
+func (e *metricTest) Test(lt *Runner) error {
+	lt.Counter("cart_items").Add(3)
+	lt.Gauge("queue").Set(7)
+	lt.Histogram("push_messages").Record(10)
+	return nil
+}
+
+func TestRunner_CustomMetrics(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	runner := New(2, 5, 0, 0, time.Second, &metricTest{}, nil, nil, 0, false)
+	sl := NewSummaryLogger(0, buf, &JsonFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	runner.allListeners = Listeners{sl}
+	runner.Run()
+
+	assert.Equal(t, int64(30), runner.Counter("cart_items").Value())
+	assert.Equal(t, 7.0, runner.Gauge("queue").Value())
+	assert.True(t, runner.Counter("cart_items") == runner.Counter("cart_items"))
+
+	var s jsonSummary
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &s))
+	require.Equal(t, 3, len(s.Total.Metrics))
+	cart := s.Total.Metrics[0]
+	assert.Equal(t, "cart_items", cart.Name)
+	assert.Equal(t, METRIC_COUNTER, cart.Type)
+	assert.Equal(t, int64(10), cart.Count)
+	assert.Equal(t, 30.0, cart.Value)
+	push := s.Total.Metrics[1]
+	assert.Equal(t, "push_messages", push.Name)
+	assert.Equal(t, 10.0, push.Percentiles["p99"])
+	assert.Equal(t, "queue", s.Total.Metrics[2].Name)
+	assert.Equal(t, 7.0, s.Total.Metrics[2].Value)
+}
+
+func TestMetricStats_Results(t *testing.T) {
+	s := metricStats{}
+	now := time.Now()
+	for i := 1; i <= 4; i++ {
+		s.add(&MetricSample{Time: now, Name: "lag", Kind: METRIC_TREND, Value: float64(i * 10)}, 3)
+		s.add(&MetricSample{Time: now, Name: "queue", Kind: METRIC_GAUGE, Value: float64(5 - i)}, 3)
+		s.add(&MetricSample{Time: now, Name: "items", Kind: METRIC_COUNTER, Value: 2}, 3)
+	}
+	list := s.results(2*time.Second, Percentiles{50})
+	require.Equal(t, 3, len(list))
+	assert.Equal(t, &metricResult{Name: "items", Kind: METRIC_COUNTER, Count: 4, Value: 8, Rate: 4, Min: 2, Mean: 2, Max: 2}, list[0])
+	assert.Equal(t, &metricResult{Name: "lag", Kind: METRIC_TREND, Count: 4, Min: 10, Mean: 25, Percentiles: []float64{20}, Max: 40}, list[1])
+	assert.Equal(t, &metricResult{Name: "queue", Kind: METRIC_GAUGE, Count: 4, Value: 1, Min: 1, Mean: 2.5, Max: 4}, list[2])
+
+	assert.Equal(t, "metrics: items 8 (4.0/s), lag n=4 mean 25.0 p50 20 max 40, queue 1 (min 1, max 4)\n", formatMetricsLine(list, Percentiles{50}))
+	lines := (&CsvFormat{Percentiles: Percentiles{50}}).FormatMetrics(list)
+	assert.Equal(t, "\nmetric,type,count,value,rate,min,mean,p50,max\n", lines[0])
+	assert.Equal(t, "items,counter,4,8,4.00,2,2.0,,2\n", lines[1])
+	assert.Equal(t, "lag,trend,4,0,0.00,10,25.0,20,40\n", lines[2])
+}
+
+func TestMetricStats_SameName(t *testing.T) {
+	s := metricStats{}
+	now := time.Now()
+	s.add(&MetricSample{Time: now, Name: "login", Kind: METRIC_COUNTER, Value: 3}, 3)
+	s.add(&MetricSample{Time: now, Name: "login", Kind: METRIC_GAUGE, Value: 7}, 3)
+	s.add(&MetricSample{Time: now, Name: "login", Kind: METRIC_CHECK, Value: 0}, 3)
+	other := metricStats{}
+	other.add(&MetricSample{Time: now, Name: "login", Kind: METRIC_CHECK, Value: 1}, 3)
+	s.merge(other)
+	list := s.results(time.Second, Percentiles{50})
+	require.Equal(t, 3, len(list))
+	assert.Equal(t, &metricResult{Name: "login", Kind: METRIC_CHECK, Count: 2, Value: 50, Min: 0, Mean: 0.5, Max: 1}, list[0])
+	assert.Equal(t, &metricResult{Name: "login", Kind: METRIC_COUNTER, Count: 1, Value: 3, Rate: 3, Min: 3, Mean: 3, Max: 3}, list[1])
+	assert.Equal(t, &metricResult{Name: "login", Kind: METRIC_GAUGE, Count: 1, Value: 7, Min: 7, Mean: 7, Max: 7}, list[2])
+}
+
+func TestPeriodLogger_Metrics(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	l := NewPeriodLogger(time.Second, buf, &MdFormat{}, DefaultPercentiles, DefaultPrecision)
+	l.runner = &Runner{}
+	l.Metric(&MetricSample{Time: time.Now(), Name: "items", Kind: METRIC_COUNTER, Value: 2})
+	l.print()
+	l.print()
+	lines := strings.Split(buf.String(), "\n")
+	require.Equal(t, 12, len(lines), buf.String())
+	assert.True(t, strings.HasPrefix(lines[0], "| time     | count    |"), lines[0])
+	assert.True(t, strings.HasPrefix(lines[2], "|        0 |        0 |"), lines[2])
+	assert.Equal(t, "", lines[3])
+	assert.True(t, strings.HasPrefix(lines[4], "| metric               | type    |"), lines[4])
+	assert.True(t, strings.HasPrefix(lines[6], "| items                | counter |        1 |       2.00 |"), lines[6])
+	assert.Equal(t, "", lines[7])
+	assert.Equal(t, lines[0], lines[8])
+	assert.True(t, strings.HasPrefix(lines[10], "|        0 |        0 |"), lines[10])
+
+	buf.Reset()
+	l = NewPeriodLogger(time.Second, buf, &CsvFormat{Percentiles: Percentiles{50}}, Percentiles{50}, DefaultPrecision)
+	l.runner = &Runner{}
+	l.Metric(&MetricSample{Time: time.Now(), Name: "items", Kind: METRIC_COUNTER, Value: 2})
+	l.print()
+	assert.Contains(t, buf.String(), "0,0,0.0,0.0,0.0,0.0,0.00,0,0\n\nmetric,type,count,value,rate,min,mean,p50,max\nitems,counter,1,2,")
+
+	md := (&MdFormat{Percentiles: Percentiles{50}}).FormatMetrics([]*metricResult{
+		{Name: "items", Kind: METRIC_COUNTER, Count: 4, Value: 8, Rate: 4, Min: 2, Mean: 2, Max: 2},
+		{Name: "lag", Kind: METRIC_TREND, Count: 4, Min: 10, Mean: 25, Percentiles: []float64{20}, Max: 40}})
+	assert.Equal(t, []string{
+		"\n| metric               | type    | count    | value      | rate       | min        | mean       | p50        | max        |\n",
+		"| -------------------- | ------- | -------- | ---------- | ---------- | ---------- | ---------- | ---------- | ---------- |\n",
+		"| items                | counter |        4 |       8.00 |       4.00 |        2.0 |        2.0 |            |        2.0 |\n",
+		"| lag                  | trend   |        4 |       0.00 |       0.00 |       10.0 |       25.0 |       20.0 |       40.0 |\n"}, md)
+}
+
+func TestPrometheusListener_CustomMetrics(t *testing.T) {
+	l := NewPrometheusListener("")
+	l.Metric(&MetricSample{Name: "items", Kind: METRIC_COUNTER, Value: 2})
+	l.Metric(&MetricSample{Name: "items", Kind: METRIC_COUNTER, Value: 3})
+	l.Metric(&MetricSample{Name: "queue", Kind: METRIC_GAUGE, Value: 4})
+	l.Metric(&MetricSample{Name: "lag", Kind: METRIC_TREND, Value: 100})
+	text := string(l.metrics())
+	assert.Contains(t, text, "# TYPE lotgo_custom_total counter\nlotgo_custom_total{name=\"items\"} 5\n")
+	assert.Contains(t, text, "lotgo_custom_gauge{name=\"queue\"} 4\n")
+	assert.Contains(t, text, "lotgo_custom_trend{name=\"lag\",quantile=\"0.99\"} 100\n")
+	assert.Contains(t, text, "lotgo_custom_trend_count{name=\"lag\"} 1\n")
+}
@@ -0,0 +1,232 @@
+package lotgo
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the exported latency histogram buckets
+var DefaultLatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// errorKey identifies an error class of a test or a step
+type errorKey struct {
+	stepKey
+	class string
+}
+
+// promHistogram is a cumulative latency histogram in the Prometheus format
+type promHistogram struct {
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+var _ SampleListener = &prometheusListener{}
+var _ http.Handler = &prometheusListener{}
+var _ MetricListener = &prometheusListener{}
+
+/* Listener which serves the test metrics in the Prometheus text format */
+type prometheusListener struct {
+	sync.Mutex
+	addr     string
+	buckets  []float64
+	server   *http.Server
+	runner   *Runner
+	requests map[stepKey]int64
+	errors   map[errorKey]int64
+	latency  map[stepKey]*promHistogram
+	custom   metricStats
+}
+
+// NewPrometheusListener creates a listener serving the metrics at http://addr/metrics
+func NewPrometheusListener(addr string) *prometheusListener {
+	return &prometheusListener{addr: addr, buckets: DefaultLatencyBuckets, requests: map[stepKey]int64{}, errors: map[errorKey]int64{}, latency: map[stepKey]*promHistogram{}, custom: metricStats{}}
+}
+
+func (l *prometheusListener) Started(runner *Runner) {
+	l.runner = runner
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", l)
+	l.server = &http.Server{Addr: l.addr, Handler: mux}
+	go func() {
+		err := l.server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			LOG().Errorf("Metrics server failed: %v", err)
+		}
+	}()
+	LOG().Infof("Serving metrics at %s/metrics", l.addr)
+}
+
+func (l *prometheusListener) Finished() {
+	if l.server != nil {
+		l.server.Close()
+	}
+}
+
+func (l *prometheusListener) Sample(s *Sample) {
+	l.Lock()
+	l.record(&s.Iteration)
+	if s.Err != nil {
+		l.errors[errorKey{stepKey: stepKey{test: s.Test, step: s.Step}, class: s.Class}]++
+	}
+	l.Unlock()
+}
+
+func (l *prometheusListener) Metric(m *MetricSample) {
+	l.Lock()
+	l.custom.add(m, DefaultPrecision)
+	l.Unlock()
+}
+
+func (l *prometheusListener) record(it *Iteration) {
+	key := stepKey{test: it.Test, step: it.Step}
+	l.requests[key]++
+	h, ok := l.latency[key]
+	if !ok {
+		h = &promHistogram{counts: make([]int64, len(l.buckets))}
+		l.latency[key] = h
+	}
+	secs := it.Duration.Seconds()
+	for i, b := range l.buckets {
+		if secs <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+func (l *prometheusListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(l.metrics())
+}
+
+// metrics returns the current metrics in the Prometheus text exposition format
+func (l *prometheusListener) metrics() []byte {
+	l.Lock()
+	defer l.Unlock()
+	buf := &bytes.Buffer{}
+
+	keys := sortedStepKeys(l.requests)
+	fmt.Fprintf(buf, "# HELP lotgo_requests_total Number of test iterations and steps run.\n# TYPE lotgo_requests_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(buf, "lotgo_requests_total%s %d\n", labels("test", key.test, "step", key.step), l.requests[key])
+	}
+
+	fmt.Fprintf(buf, "# HELP lotgo_errors_total Number of failed test iterations and steps by error class.\n# TYPE lotgo_errors_total counter\n")
+	var ekeys []errorKey
+	for key := range l.errors {
+		ekeys = append(ekeys, key)
+	}
+	sort.Slice(ekeys, func(i, j int) bool {
+		if ekeys[i].stepKey != ekeys[j].stepKey {
+			return stepKeyLess(ekeys[i].stepKey, ekeys[j].stepKey)
+		}
+		return ekeys[i].class < ekeys[j].class
+	})
+	for _, key := range ekeys {
+		fmt.Fprintf(buf, "lotgo_errors_total%s %d\n", labels("test", key.test, "step", key.step, "class", key.class), l.errors[key])
+	}
+
+	fmt.Fprintf(buf, "# HELP lotgo_latency_seconds Latency of test iterations and steps.\n# TYPE lotgo_latency_seconds histogram\n")
+	for _, key := range keys {
+		h := l.latency[key]
+		for i, b := range l.buckets {
+			le := strconv.FormatFloat(b, 'g', -1, 64)
+			fmt.Fprintf(buf, "lotgo_latency_seconds_bucket%s %d\n", labels("test", key.test, "step", key.step, "le", le), h.counts[i])
+		}
+		fmt.Fprintf(buf, "lotgo_latency_seconds_bucket%s %d\n", labels("test", key.test, "step", key.step, "le", "+Inf"), h.count)
+		fmt.Fprintf(buf, "lotgo_latency_seconds_sum%s %g\n", labels("test", key.test, "step", key.step), h.sum)
+		fmt.Fprintf(buf, "lotgo_latency_seconds_count%s %d\n", labels("test", key.test, "step", key.step), h.count)
+	}
+
+	l.customMetrics(buf)
+
+	if l.runner != nil {
+		fmt.Fprintf(buf, "# HELP lotgo_active_clients Number of clients currently running the test.\n# TYPE lotgo_active_clients gauge\n")
+		fmt.Fprintf(buf, "lotgo_active_clients%s %d\n", labels("test", l.runner.name), l.runner.ActiveClients())
+		fmt.Fprintf(buf, "# HELP lotgo_target_clients Number of clients allowed to run, the configured clients unless limited during the run.\n# TYPE lotgo_target_clients gauge\n")
+		fmt.Fprintf(buf, "lotgo_target_clients%s %d\n", labels("test", l.runner.name), l.runner.ClientLimit())
+		fmt.Fprintf(buf, "# HELP lotgo_elapsed_seconds Time since the start of the test.\n# TYPE lotgo_elapsed_seconds gauge\n")
+		fmt.Fprintf(buf, "lotgo_elapsed_seconds%s %g\n", labels("test", l.runner.name), time.Since(l.runner.startTime).Seconds())
+	}
+	return buf.Bytes()
+}
+
+// customMetrics writes the custom metrics of the tests, counters as totals, gauges with the latest value, trends as summaries
+// and checks as totals of passed and failed checks
+func (l *prometheusListener) customMetrics(buf *bytes.Buffer) {
+	results := l.custom.results(0, Percentiles{50, 90, 99})
+	for _, kind := range []string{METRIC_COUNTER, METRIC_GAUGE, METRIC_TREND, METRIC_CHECK} {
+		first := true
+		for _, m := range results {
+			if m.Kind != kind {
+				continue
+			}
+			switch kind {
+			case METRIC_COUNTER:
+				if first {
+					fmt.Fprintf(buf, "# HELP lotgo_custom_total Custom counters of the tests.\n# TYPE lotgo_custom_total counter\n")
+				}
+				fmt.Fprintf(buf, "lotgo_custom_total%s %g\n", labels("name", m.Name), m.Value)
+			case METRIC_GAUGE:
+				if first {
+					fmt.Fprintf(buf, "# HELP lotgo_custom_gauge Custom gauges of the tests.\n# TYPE lotgo_custom_gauge gauge\n")
+				}
+				fmt.Fprintf(buf, "lotgo_custom_gauge%s %g\n", labels("name", m.Name), m.Value)
+			case METRIC_TREND:
+				if first {
+					fmt.Fprintf(buf, "# HELP lotgo_custom_trend Custom trends of the tests.\n# TYPE lotgo_custom_trend summary\n")
+				}
+				for i, q := range []string{"0.5", "0.9", "0.99"} {
+					fmt.Fprintf(buf, "lotgo_custom_trend%s %g\n", labels("name", m.Name, "quantile", q), m.Percentiles[i])
+				}
+				fmt.Fprintf(buf, "lotgo_custom_trend_sum%s %g\n", labels("name", m.Name), m.Mean*float64(m.Count))
+				fmt.Fprintf(buf, "lotgo_custom_trend_count%s %d\n", labels("name", m.Name), m.Count)
+			case METRIC_CHECK:
+				if first {
+					fmt.Fprintf(buf, "# HELP lotgo_checks_total Passed and failed checks of the tests.\n# TYPE lotgo_checks_total counter\n")
+				}
+				passes := checkPasses(m)
+				fmt.Fprintf(buf, "lotgo_checks_total%s %d\n", labels("name", m.Name, "result", "pass"), passes)
+				fmt.Fprintf(buf, "lotgo_checks_total%s %d\n", labels("name", m.Name, "result", "fail"), m.Count-passes)
+			}
+			first = false
+		}
+	}
+}
+
+func sortedStepKeys(m map[stepKey]int64) []stepKey {
+	var keys []stepKey
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return stepKeyLess(keys[i], keys[j])
+	})
+	return keys
+}
+
+func stepKeyLess(a stepKey, b stepKey) bool {
+	if a.test != b.test {
+		return a.test < b.test
+	}
+	return a.step < b.step
+}
+
+var labelEscaper = strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`)
+
+// labels formats name value pairs as Prometheus labels
+func labels(nameValues ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(nameValues); i += 2 {
+		parts = append(parts, nameValues[i]+"=\""+labelEscaper.Replace(nameValues[i+1])+"\"")
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,43 @@
+package lotgo
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPrometheusListener_Metrics(t *testing.T) {
+	l := NewPrometheusListener("")
+	l.buckets = []float64{0.01, 0.1}
+	l.runner = &Runner{name: "shop", clients: 10, activeClients: 4, startTime: time.Now()}
+	l.Sample(&Sample{Iteration: Iteration{Test: "shop", Duration: 5 * time.Millisecond}})
+	l.Sample(&Sample{Iteration: Iteration{Test: "shop", Duration: 50 * time.Millisecond}})
+	l.Sample(&Sample{Iteration: Iteration{Test: "shop", Step: "pay \"card\"", Duration: time.Second, Class: "HTTP 503"}, Err: errors.New("x")})
+
+	server := httptest.NewServer(l)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	text := string(body)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+	assert.Contains(t, text, "# TYPE lotgo_requests_total counter\n")
+	assert.Contains(t, text, "lotgo_requests_total{test=\"shop\",step=\"\"} 2\n")
+	assert.Contains(t, text, "lotgo_requests_total{test=\"shop\",step=\"pay \\\"card\\\"\"} 1\n")
+	assert.Contains(t, text, "lotgo_errors_total{test=\"shop\",step=\"pay \\\"card\\\"\",class=\"HTTP 503\"} 1\n")
+	assert.Contains(t, text, "lotgo_latency_seconds_bucket{test=\"shop\",step=\"\",le=\"0.01\"} 1\n")
+	assert.Contains(t, text, "lotgo_latency_seconds_bucket{test=\"shop\",step=\"\",le=\"0.1\"} 2\n")
+	assert.Contains(t, text, "lotgo_latency_seconds_bucket{test=\"shop\",step=\"\",le=\"+Inf\"} 2\n")
+	assert.Contains(t, text, "lotgo_latency_seconds_count{test=\"shop\",step=\"\"} 2\n")
+	assert.Contains(t, text, "lotgo_active_clients{test=\"shop\"} 4\n")
+	assert.Contains(t, text, "lotgo_target_clients{test=\"shop\"} 10\n")
+
+	l.runner.SetClientLimit(5)
+	text = string(l.metrics())
+	assert.Contains(t, text, "lotgo_target_clients{test=\"shop\"} 5\n")
+}
@@ -0,0 +1,644 @@
+package lotgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/gizak/termui"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	REPLAY_TICK      = 250 * time.Millisecond
+	REPLAY_MIN_ZOOM  = 10
+	REPLAY_JUMP      = 10
+	REPLAY_MAX_SPEED = 64
+)
+
+const replayHelp = `q                 quit
+space, p          play or pause
+left, right       step one frame back or forward
+[, ]              jump 10 frames back or forward
+pgup, pgdn        jump a tenth of the visible range
+home, end         go to the start or the end of the visible range
++, -              zoom in around the cursor or zoom out
+0                 show the whole run
+f, s              play faster or slower
+h, ?              show or hide this help`
+
+func init() {
+	AddCommand("replay", func(args []string) int {
+		if err := replay(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	})
+}
+
+// replayOptions are the arguments of lotgo replay
+type replayOptions struct {
+	frame     time.Duration
+	precision int
+}
+
+// replay opens a samples file or period results in JSON lines in the terminal UI:
+//
+//	lotgo replay [flags] results-file
+func replay(args []string) error {
+	o := &replayOptions{}
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.DurationVar(&o.frame, "frame", 0, "Length of one frame of a samples file, default the period of the recorded run")
+	fs.IntVar(&o.precision, "precision", DefaultPrecision, "Latency histogram precision in significant digits, 1-5")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: lotgo replay [flags] samples-or-jsonl-file")
+	}
+	if o.precision < 1 || o.precision > 5 {
+		return errors.New("-precision must be between 1 and 5")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	t, err := loadReplay(f, o.frame, o.precision)
+	if err != nil {
+		return err
+	}
+	return newReplayUi(newReplayState(t)).Loop()
+}
+
+// replayFrame holds the results of one step of the recorded timeline
+type replayFrame struct {
+	Start     time.Time
+	End       time.Time
+	Count     int64
+	Errors    int64
+	Rate      float64
+	ErrorRate float64
+	Clients   float64
+	Mean      float64
+	Max       float64
+	Latencies []float64
+	stats     *stats
+	errors    errorStats
+}
+
+// replayTimeline is a recorded run split to frames, the frames of a samples file keep their
+// histograms so that the results of any range are exact
+type replayTimeline struct {
+	test      string
+	source    string
+	frames    []*replayFrame
+	precision int
+}
+
+// loadReplay reads a samples file or period results in JSON lines, recognized by the first line
+func loadReplay(r io.Reader, frame time.Duration, precision int) (*replayTimeline, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(SAMPLES_MAGIC))
+	if err != nil && len(magic) < 2 {
+		return nil, errors.New("empty results file")
+	}
+	if magic[0] == 0x1f && magic[1] == 0x8b || string(magic) == SAMPLES_MAGIC {
+		sr, err := newSamplesReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return loadReplaySamples(sr, frame, precision)
+	}
+	return loadReplayPeriods(br)
+}
+
+func loadReplaySamples(sr *samplesReader, frame time.Duration, precision int) (*replayTimeline, error) {
+	meta := sr.meta
+	if frame <= 0 {
+		frame = meta.Period
+	}
+	if frame <= 0 {
+		frame = time.Second
+	}
+	t := &replayTimeline{test: meta.Test, source: "samples", precision: precision}
+	var clients []map[int]bool
+	end := meta.Start
+	for {
+		it, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if it.Step != "" || it.Start.Before(meta.Start) {
+			continue
+		}
+		n := int(it.Start.Sub(meta.Start) / frame)
+		for len(t.frames) <= n {
+			start := meta.Start.Add(time.Duration(len(t.frames)) * frame)
+			t.frames = append(t.frames, &replayFrame{Start: start, End: start.Add(frame), stats: newStats(precision)})
+			clients = append(clients, map[int]bool{})
+		}
+		if it.Class != "" {
+			t.frames[n].stats.error(errors.New(it.Class), it)
+		} else {
+			t.frames[n].stats.success(it)
+		}
+		if it.Client >= 0 {
+			clients[n][it.Client] = true
+		}
+		if e := it.Start.Add(it.Duration); e.After(end) {
+			end = e
+		}
+	}
+	if len(t.frames) == 0 {
+		return nil, errors.New("no samples in the file")
+	}
+	if last := t.frames[len(t.frames)-1]; end.Before(last.End) && end.After(last.Start) {
+		last.End = end
+	}
+	for i, f := range t.frames {
+		hist := f.stats.hist
+		f.Count = hist.Count()
+		f.Errors = f.stats.errors.count()
+		f.errors = f.stats.errors
+		f.ErrorRate = errorRateOf(f.stats)
+		f.Clients = float64(len(clients[i]))
+		if d := f.End.Sub(f.Start); d > 0 {
+			f.Rate = float64(f.Count) / d.Seconds()
+		}
+		f.Mean = hist.Mean() / 1000
+		f.Max = toMillis(hist.Max())
+		for _, p := range livePercentiles {
+			f.Latencies = append(f.Latencies, toMillis(hist.ValueAtPercentile(p)))
+		}
+	}
+	return t, nil
+}
+
+// loadReplayPeriods reads the period results written with -period-format jsonl. Lines which are not
+// JSON objects, results of single tests or steps and the summary line at the end are skipped.
+func loadReplayPeriods(r *bufio.Reader) (*replayTimeline, error) {
+	t := &replayTimeline{source: "periods", precision: DefaultPrecision}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		res := &jsonResult{}
+		if err := json.Unmarshal(line, res); err != nil || res.Timestamp.IsZero() || res.Test != "" || res.Step != "" {
+			continue
+		}
+		// the time of a result is the time since the start of the run, a period starts at the end of the previous one
+		f := &replayFrame{End: res.Timestamp, Start: res.Timestamp.Add(-time.Duration(res.Time * float64(time.Second))),
+			Count: res.Count, Errors: res.Errors, Rate: res.Rate, ErrorRate: res.ErrorRate * 100, Clients: float64(res.Clients),
+			Mean: res.Mean, Max: res.Max, errors: errorStats{}}
+		if n := len(t.frames); n > 0 {
+			// the summary ends with the last period
+			if !f.End.After(t.frames[n-1].End) {
+				continue
+			}
+			f.Start = t.frames[n-1].End
+		}
+		for _, name := range livePercentiles.Names() {
+			f.Latencies = append(f.Latencies, res.Percentiles[name])
+		}
+		for _, e := range res.ErrorClasses {
+			f.errors[e.Class] = &errorStat{Class: e.Class, Count: e.Count, First: e.First, Last: e.Last, Example: e.Example}
+		}
+		t.frames = append(t.frames, f)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(t.frames) == 0 {
+		return nil, errors.New("no samples or period results in JSON lines in the file")
+	}
+	return t, nil
+}
+
+// offset returns the time from the start of the run to the end of frame i
+func (t *replayTimeline) offset(i int) time.Duration {
+	return t.frames[i].End.Sub(t.frames[0].Start)
+}
+
+// rangeItems returns the results of the frames from - to, exact for samples and from
+// the period results otherwise
+func (t *replayTimeline) rangeItems(from int, to int) []string {
+	var count, errs int64
+	var mean, max float64
+	peaks := make([]float64, len(livePercentiles))
+	merged := newStats(t.precision)
+	for _, f := range t.frames[from:to] {
+		count += f.Count
+		errs += f.Errors
+		mean += f.Mean * float64(f.Count)
+		if f.Max > max {
+			max = f.Max
+		}
+		for i, v := range f.Latencies {
+			if v > peaks[i] {
+				peaks[i] = v
+			}
+		}
+		if f.stats != nil {
+			merged.merge(f.stats)
+		}
+	}
+	d := t.frames[to-1].End.Sub(t.frames[from].Start)
+	rate, errRate := 0.0, 0.0
+	if d > 0 {
+		rate = float64(count) / d.Seconds()
+	}
+	if count+errs > 0 {
+		errRate = float64(errs) * 100 / float64(count+errs)
+		mean /= float64(count)
+	}
+	items := []string{
+		fmt.Sprintf("Iterations:          %d, %d errors", count+errs, errs),
+		fmt.Sprintf("Throughput:          %.1f r/s", rate),
+		fmt.Sprintf("Error rate:          %.2f %%", errRate),
+	}
+	if t.source == "samples" {
+		hist := merged.hist
+		items = append(items, fmt.Sprintf("Response time, mean: %.1f ms", hist.Mean()/1000))
+		for i, p := range livePercentiles {
+			items = append(items, fmt.Sprintf("Response time, %-6s%.1f ms", livePercentiles.Names()[i]+":", toMillis(hist.ValueAtPercentile(p))))
+		}
+		items = append(items, fmt.Sprintf("Response time, max:  %.1f ms", toMillis(hist.Max())))
+	} else {
+		items = append(items, fmt.Sprintf("Response time, mean: %.1f ms", mean))
+		for i, name := range livePercentiles.Names() {
+			items = append(items, fmt.Sprintf("Peak %-15s%.1f ms", name+":", peaks[i]))
+		}
+		items = append(items, fmt.Sprintf("Response time, max:  %.1f ms", max))
+	}
+	return items
+}
+
+// rangeErrors returns the error classes of the frames from - to by descending count
+func (t *replayTimeline) rangeErrors(from int, to int) []*errorStat {
+	errs := errorStats{}
+	for _, f := range t.frames[from:to] {
+		errs.merge(f.errors)
+	}
+	return errs.sorted()
+}
+
+// replayState is the position of the player, the cursor is always within the visible range from - to
+type replayState struct {
+	*replayTimeline
+	cursor  int
+	from    int
+	to      int
+	playing bool
+	speed   int
+}
+
+func newReplayState(t *replayTimeline) *replayState {
+	return &replayState{replayTimeline: t, to: len(t.frames), speed: 1}
+}
+
+// seek moves the cursor by n frames, panning the visible range to keep the cursor in it
+func (s *replayState) seek(n int) {
+	s.seekTo(s.cursor + n)
+}
+
+func (s *replayState) seekTo(i int) {
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(s.frames) {
+		i = len(s.frames) - 1
+	}
+	s.cursor = i
+	size := s.to - s.from
+	if i < s.from {
+		s.from, s.to = i, i+size
+	} else if i >= s.to {
+		s.from, s.to = i-size+1, i+1
+	}
+}
+
+// tick advances a playing cursor, the playing stops at the end of the run
+func (s *replayState) tick() bool {
+	if !s.playing {
+		return false
+	}
+	s.seek(s.speed)
+	if s.cursor == len(s.frames)-1 {
+		s.playing = false
+	}
+	return true
+}
+
+// togglePlay starts or stops playing, at the end of the run the playing starts again from the visible range
+func (s *replayState) togglePlay() {
+	if !s.playing && s.cursor == len(s.frames)-1 {
+		s.cursor = s.from
+	}
+	s.playing = !s.playing
+}
+
+// zoom sets the visible range to size frames around the cursor
+func (s *replayState) zoom(size int) {
+	if size < REPLAY_MIN_ZOOM {
+		size = REPLAY_MIN_ZOOM
+	}
+	if size > len(s.frames) {
+		size = len(s.frames)
+	}
+	s.from = s.cursor - size/2
+	if s.from < 0 {
+		s.from = 0
+	}
+	s.to = s.from + size
+	if s.to > len(s.frames) {
+		s.to = len(s.frames)
+		s.from = s.to - size
+	}
+}
+
+func (s *replayState) zoomIn() {
+	s.zoom((s.to - s.from) / 2)
+}
+
+func (s *replayState) zoomOut() {
+	s.zoom((s.to - s.from) * 2)
+}
+
+func (s *replayState) resetZoom() {
+	s.from, s.to = 0, len(s.frames)
+}
+
+// setSpeed multiplies the frames played per tick by m, between 1 and REPLAY_MAX_SPEED
+func (s *replayState) setSpeed(m float64) {
+	s.speed = int(float64(s.speed) * m)
+	if s.speed < 1 {
+		s.speed = 1
+	}
+	if s.speed > REPLAY_MAX_SPEED {
+		s.speed = REPLAY_MAX_SPEED
+	}
+}
+
+// series returns the values of the visible frames
+func (s *replayState) series(value func(f *replayFrame) float64) []float64 {
+	data := make([]float64, 0, s.to-s.from)
+	for _, f := range s.frames[s.from:s.to] {
+		data = append(data, value(f))
+	}
+	return data
+}
+
+// status returns the player state, the cursor position and the visible range
+func (s *replayState) status() string {
+	state := "PAUSED"
+	if s.playing {
+		state = "PLAYING"
+	}
+	return fmt.Sprintf("%s x%d  %s / %s  range %s - %s  [h] help", state, s.speed, s.offset(s.cursor), s.offset(len(s.frames)-1),
+		s.frames[s.from].Start.Sub(s.frames[0].Start), s.offset(s.to-1))
+}
+
+// cursorItems returns the results of the frame at the cursor
+func (s *replayState) cursorItems() []string {
+	f := s.frames[s.cursor]
+	items := []string{
+		fmt.Sprintf("Test:                %s (%s)", s.test, s.source),
+		fmt.Sprintf("Frame:               %d of %d, %s", s.cursor+1, len(s.frames), f.End.Format("15:04:05")),
+		fmt.Sprintf("Clients:             %.0f", f.Clients),
+		fmt.Sprintf("Throughput:          %.1f r/s", f.Rate),
+		fmt.Sprintf("Error rate:          %.2f %%", f.ErrorRate),
+		fmt.Sprintf("Response time, mean: %.1f ms", f.Mean),
+	}
+	for i, name := range livePercentiles.Names() {
+		items = append(items, fmt.Sprintf("Response time, %-6s%.1f ms", name+":", f.Latencies[i]))
+	}
+	return append(items, fmt.Sprintf("Response time, max:  %.1f ms", f.Max))
+}
+
+// replayUi shows the recorded timeline with the charts of the terminal UI
+type replayUi struct {
+	sync.Mutex
+	*replayState
+	topText     *termui.Par
+	position    *termui.Gauge
+	cursorList  *termui.List
+	rangeList   *termui.List
+	errorList   *termui.List
+	throughPut  *termui.LineChart
+	latency     []*termui.LineChart
+	errorRate   *termui.LineChart
+	clients     *termui.LineChart
+	help        *termui.Par
+	showHelp    bool
+	initialized bool
+}
+
+func newReplayUi(s *replayState) *replayUi {
+	return &replayUi{replayState: s}
+}
+
+func (r *replayUi) Loop() error {
+	if err := termui.Init(); err != nil {
+		return err
+	}
+	defer termui.Close()
+
+	r.topText = termui.NewPar("")
+	r.topText.Height = 3
+	r.topText.BorderLabel = "Replay"
+	r.topText.BorderFg = termui.ColorCyan
+	r.position = termui.NewGauge()
+	r.position.Height = 3
+	r.position.BorderLabel = "Position in range"
+	r.position.BarColor = termui.ColorCyan
+	r.cursorList = termui.NewList()
+	r.cursorList.BorderLabel = "At cursor"
+	r.rangeList = termui.NewList()
+	r.rangeList.BorderLabel = "Visible range"
+	r.errorList = termui.NewList()
+	r.errorList.ItemFgColor = termui.ColorYellow
+	r.errorList.BorderLabel = "Errors in range"
+	r.throughPut = newUiChart("Throughput r/s", termui.ColorRed)
+	r.errorRate = newUiChart("Error rate %", termui.ColorYellow)
+	r.clients = newUiChart("Active clients", termui.ColorCyan)
+	for _, name := range livePercentiles.Names() {
+		r.latency = append(r.latency, newUiChart("Latency "+name+" ms", termui.ColorGreen))
+	}
+	r.help = termui.NewPar(replayHelp)
+	r.help.BorderLabel = "Help"
+	r.help.BorderFg = termui.ColorCyan
+	r.help.Width = 60
+	r.help.Height = strings.Count(replayHelp, "\n") + 3
+	r.initialized = true
+
+	r.Lock()
+	r.layout(termui.TermWidth(), termui.TermHeight())
+	r.updateWidgets()
+	r.Unlock()
+	r.render()
+
+	keys := map[string]func(){
+		"q":          termui.StopLoop,
+		"<space>":    r.togglePlay,
+		"p":          r.togglePlay,
+		"<left>":     func() { r.seek(-1) },
+		"<right>":    func() { r.seek(1) },
+		"[":          func() { r.seek(-REPLAY_JUMP) },
+		"]":          func() { r.seek(REPLAY_JUMP) },
+		"<previous>": func() { r.seek(-r.jump()) },
+		"<next>":     func() { r.seek(r.jump()) },
+		"<home>":     func() { r.seekTo(r.from) },
+		"<end>":      func() { r.seekTo(r.to - 1) },
+		"+":          r.zoomIn,
+		"=":          r.zoomIn,
+		"-":          r.zoomOut,
+		"0":          r.resetZoom,
+		"f":          func() { r.setSpeed(2) },
+		"s":          func() { r.setSpeed(0.5) },
+		"h":          func() { r.showHelp = !r.showHelp },
+		"?":          func() { r.showHelp = !r.showHelp },
+		"<escape>":   func() { r.showHelp = false },
+	}
+	for key, fn := range keys {
+		fn := fn
+		termui.Handle("/sys/kbd/"+key, func(termui.Event) {
+			r.control(fn)
+		})
+	}
+	termui.Handle("/sys/wnd/resize", func(e termui.Event) {
+		w := e.Data.(termui.EvtWnd)
+		r.Lock()
+		r.layout(w.Width, w.Height)
+		r.Unlock()
+		termui.Clear()
+		r.render()
+	})
+	termui.Merge("replay", termui.NewTimerCh(REPLAY_TICK))
+	termui.Handle("/timer/"+REPLAY_TICK.String(), func(termui.Event) {
+		r.Lock()
+		changed := r.tick()
+		if changed {
+			r.updateWidgets()
+		}
+		r.Unlock()
+		if changed {
+			r.render()
+		}
+	})
+	termui.Loop()
+	return nil
+}
+
+// jump returns a tenth of the visible range, at least one frame
+func (r *replayUi) jump() int {
+	if n := (r.to - r.from) / 10; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// control applies a keyboard action and redraws
+func (r *replayUi) control(fn func()) {
+	r.Lock()
+	fn()
+	r.updateWidgets()
+	r.Unlock()
+	r.render()
+}
+
+// layout arranges the widgets like the dashboard of a running test
+func (r *replayUi) layout(width int, height int) {
+	l := newUiLayout(width, height)
+	for _, list := range []*termui.List{r.cursorList, r.rangeList, r.errorList} {
+		list.Height = l.listHeight
+	}
+	charts := append([]*termui.LineChart{r.throughPut, r.errorRate, r.clients}, r.latency...)
+	for _, chart := range charts {
+		chart.Height = l.chartHeight
+	}
+
+	termui.Body.Rows = nil
+	termui.Body.Width = width
+	termui.Body.X = 0
+	termui.Body.Y = 0
+	if l.wide {
+		termui.Body.AddRows(
+			termui.NewRow(termui.NewCol(6, 0, r.topText), termui.NewCol(6, 0, r.position)),
+			termui.NewRow(termui.NewCol(4, 0, r.cursorList), termui.NewCol(4, 0, r.rangeList), termui.NewCol(4, 0, r.errorList)),
+			termui.NewRow(termui.NewCol(4, 0, r.throughPut), termui.NewCol(4, 0, r.errorRate), termui.NewCol(4, 0, r.clients)),
+			termui.NewRow(termui.NewCol(4, 0, r.latency[0]), termui.NewCol(4, 0, r.latency[1]), termui.NewCol(4, 0, r.latency[2])))
+	} else {
+		termui.Body.AddRows(
+			termui.NewRow(termui.NewCol(12, 0, r.topText)),
+			termui.NewRow(termui.NewCol(6, 0, r.cursorList), termui.NewCol(6, 0, r.rangeList)),
+			termui.NewRow(termui.NewCol(12, 0, r.errorList)),
+			termui.NewRow(termui.NewCol(6, 0, r.throughPut), termui.NewCol(6, 0, r.errorRate)),
+			termui.NewRow(termui.NewCol(6, 0, r.latency[0]), termui.NewCol(6, 0, r.latency[1])),
+			termui.NewRow(termui.NewCol(6, 0, r.latency[2]), termui.NewCol(6, 0, r.clients)))
+	}
+	termui.Body.Align()
+
+	r.help.X = (width - r.help.Width) / 2
+	if r.help.X < 0 {
+		r.help.X = 0
+	}
+	r.help.Y = 3
+}
+
+func (r *replayUi) render() {
+	if r.showHelp {
+		termui.Render(termui.Body, r.help)
+	} else {
+		termui.Render(termui.Body)
+	}
+}
+
+// updateWidgets copies the values at the cursor and of the visible range to the widgets
+func (r *replayUi) updateWidgets() {
+	if !r.initialized {
+		return
+	}
+	r.topText.Text = r.status()
+	if n := r.to - r.from; n > 1 {
+		r.position.Percent = (r.cursor - r.from) * 100 / (n - 1)
+	} else {
+		r.position.Percent = 100
+	}
+	r.position.Label = r.offset(r.cursor).String()
+	r.cursorList.Items = r.cursorItems()
+	r.rangeList.Items = r.rangeItems(r.from, r.to)
+	var items []string
+	for _, e := range r.rangeErrors(r.from, r.to) {
+		items = append(items, fmt.Sprintf("%7d  %s", e.Count, strings.Replace(e.Class, "\n", " ", -1)))
+	}
+	if len(items) == 0 {
+		items = []string{"No errors"}
+	}
+	r.errorList.Items = items
+	f := r.frames[r.cursor]
+	r.throughPut.BorderLabel = fmt.Sprintf("Throughput r/s (%.1f)", f.Rate)
+	r.throughPut.Data = chartData(r.series(func(f *replayFrame) float64 { return f.Rate }))
+	r.errorRate.BorderLabel = fmt.Sprintf("Error rate %% (%.2f)", f.ErrorRate)
+	r.errorRate.Data = chartData(r.series(func(f *replayFrame) float64 { return f.ErrorRate }))
+	r.clients.BorderLabel = fmt.Sprintf("Active clients (%.0f)", f.Clients)
+	r.clients.Data = chartData(r.series(func(f *replayFrame) float64 { return f.Clients }))
+	for i, chart := range r.latency {
+		i := i
+		chart.BorderLabel = fmt.Sprintf("Latency %s ms (%.1f)", livePercentiles.Names()[i], f.Latencies[i])
+		chart.Data = chartData(r.series(func(f *replayFrame) float64 { return f.Latencies[i] }))
+	}
+}
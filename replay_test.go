@@ -0,0 +1,144 @@
+package lotgo
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadReplay_Samples(t *testing.T) {
+	buf, start := writeSamples(t)
+	tl, err := loadReplay(buf, 0, 3)
+	require.Nil(t, err)
+	assert.Equal(t, "shop", tl.test)
+	assert.Equal(t, "samples", tl.source)
+	require.Equal(t, 4, len(tl.frames))
+	f := tl.frames[1]
+	assert.Equal(t, toMicros(start.Add(time.Second)), toMicros(f.Start))
+	assert.Equal(t, int64(9), f.Count)
+	assert.Equal(t, int64(1), f.Errors)
+	assert.Equal(t, 10.0, f.ErrorRate)
+	assert.Equal(t, 9.0, f.Rate)
+	assert.Equal(t, 2.0, f.Clients)
+	assert.Equal(t, 19.0, f.Max)
+	assert.Equal(t, 3, len(f.Latencies))
+	assert.Equal(t, 4*time.Second, tl.offset(3).Round(time.Second))
+
+	items := tl.rangeItems(0, 4)
+	assert.Equal(t, "Iterations:          40, 4 errors", items[0])
+	assert.Contains(t, items, "Response time, max:  39.0 ms")
+	errs := tl.rangeErrors(2, 4)
+	require.Equal(t, 1, len(errs))
+	assert.Equal(t, "HTTP 503", errs[0].Class)
+	assert.Equal(t, int64(2), errs[0].Count)
+
+	buf, _ = writeSamples(t)
+	tl, err = loadReplay(buf, 2*time.Second, 3)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(tl.frames))
+	assert.Equal(t, int64(18), tl.frames[0].Count)
+}
+
+func TestLoadReplay_Periods(t *testing.T) {
+	buf, _ := writeSamples(t)
+	out := &bytes.Buffer{}
+	o := &analyzeOptions{periodFormat: "jsonl", summaryFormat: "jsonl", percentiles: Percentiles{50, 95, 99}, precision: 3}
+	require.Nil(t, o.run(buf, out))
+	out.WriteString("not json\n")
+
+	tl, err := loadReplay(out, 0, 3)
+	require.Nil(t, err)
+	assert.Equal(t, "periods", tl.source)
+	require.Equal(t, 4, len(tl.frames))
+	f := tl.frames[1]
+	assert.Equal(t, int64(9), f.Count)
+	assert.Equal(t, int64(1), f.Errors)
+	assert.InDelta(t, 10.0, f.ErrorRate, 0.001)
+	assert.Equal(t, 2.0, f.Clients)
+	assert.Equal(t, 19.0, f.Latencies[2])
+	assert.Equal(t, int64(1), f.errors["HTTP 503"].Count)
+
+	items := tl.rangeItems(0, 4)
+	assert.Equal(t, "Iterations:          40, 4 errors", items[0])
+	assert.Contains(t, items, "Peak p99:           39.0 ms")
+
+	_, err = loadReplay(strings.NewReader("time,count\n1,2\n"), 0, 3)
+	assert.NotNil(t, err)
+	_, err = loadReplay(strings.NewReader(""), 0, 3)
+	assert.NotNil(t, err)
+}
+
+func replayFrames(n int) *replayTimeline {
+	start := time.Now()
+	tl := &replayTimeline{source: "periods", precision: 3}
+	for i := 0; i < n; i++ {
+		tl.frames = append(tl.frames, &replayFrame{Start: start.Add(time.Duration(i) * time.Second),
+			End: start.Add(time.Duration(i+1) * time.Second), Rate: float64(i), Latencies: []float64{1, 2, 3}})
+	}
+	return tl
+}
+
+func TestReplayState_SeekAndZoom(t *testing.T) {
+	s := newReplayState(replayFrames(100))
+	assert.Equal(t, 0, s.from)
+	assert.Equal(t, 100, s.to)
+
+	s.seek(-5)
+	assert.Equal(t, 0, s.cursor)
+	s.seekTo(50)
+	s.zoomIn()
+	assert.Equal(t, 25, s.from)
+	assert.Equal(t, 75, s.to)
+	s.zoomIn()
+	s.zoomIn()
+	s.zoomIn()
+	assert.Equal(t, REPLAY_MIN_ZOOM, s.to-s.from)
+	assert.Equal(t, 45, s.from)
+
+	// the visible range follows the cursor
+	s.seek(10)
+	assert.Equal(t, 60, s.cursor)
+	assert.Equal(t, 51, s.from)
+	assert.Equal(t, 61, s.to)
+	s.seekTo(0)
+	assert.Equal(t, 0, s.from)
+	assert.Equal(t, 10, s.to)
+	assert.Equal(t, []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, s.series(func(f *replayFrame) float64 { return f.Rate }))
+
+	s.seekTo(99)
+	s.zoomOut()
+	assert.Equal(t, 80, s.from)
+	assert.Equal(t, 100, s.to)
+	s.resetZoom()
+	assert.Equal(t, 0, s.from)
+	assert.Equal(t, 100, s.to)
+	assert.True(t, strings.HasPrefix(s.status(), "PAUSED x1  1m40s / 1m40s  range 0s - 1m40s"), s.status())
+}
+
+func TestReplayState_Play(t *testing.T) {
+	s := newReplayState(replayFrames(10))
+	assert.False(t, s.tick())
+	s.togglePlay()
+	s.setSpeed(4)
+	assert.Equal(t, 4, s.speed)
+	assert.True(t, s.tick())
+	assert.Equal(t, 4, s.cursor)
+	s.tick()
+	s.tick()
+	assert.Equal(t, 9, s.cursor)
+	assert.False(t, s.playing)
+
+	// playing again starts from the beginning of the visible range
+	s.togglePlay()
+	assert.True(t, s.playing)
+	assert.Equal(t, 0, s.cursor)
+
+	s.setSpeed(0.1)
+	assert.Equal(t, 1, s.speed)
+	s.setSpeed(1000)
+	assert.Equal(t, REPLAY_MAX_SPEED, s.speed)
+	assert.Equal(t, "Frame:               1 of 10, "+s.frames[0].End.Format("15:04:05"), s.cursorItems()[1])
+}
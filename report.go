@@ -0,0 +1,319 @@
+package lotgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"time"
+)
+
+var chartColors = []string{"#d62728", "#1f77b4", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+var _ SampleListener = &reportWriter{}
+
+/* Listener which writes a self-contained HTML report with charts when the test has finished */
+type reportWriter struct {
+	writer      io.Writer
+	periods     *periodLogger
+	summary     *summaryLogger
+	percentiles Percentiles
+}
+
+// NewReportWriter creates a report of the period results and the summary collected by the given loggers
+func NewReportWriter(w io.Writer, p *periodLogger, s *summaryLogger) *reportWriter {
+	return &reportWriter{writer: w, periods: p, summary: s, percentiles: percentilesOrDefault(s.percentiles)}
+}
+
+func (r *reportWriter) Started(runner *Runner) {
+}
+
+func (r *reportWriter) Sample(s *Sample) {
+}
+
+// Finished writes the report and closes the writer if it is a file
+func (r *reportWriter) Finished() {
+	err := writeReport(r.writer, r.summary.summary(), r.periods.Series(), r.summary.histogram(), r.percentiles)
+	if err != nil {
+		LOG().Errorf("Failed to write report: %v", err)
+	}
+	if c, ok := r.writer.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			LOG().Errorf("Failed to close report: %v", err)
+		}
+	}
+}
+
+// chartSeries is a named line in a chart
+type chartSeries struct {
+	Name   string
+	Values []float64
+}
+
+// reportData is the data of the report template
+type reportData struct {
+	Summary     *summary
+	Names       []string
+	Charts      []template.HTML
+	Histogram   template.HTML
+	Data        template.JS
+	GeneratedAt time.Time
+}
+
+func writeReport(w io.Writer, s *summary, series []*result, hist *Histogram, ps Percentiles) error {
+	var xs, rate, clients, errorRate, mean, min, max []float64
+	perc := make([][]float64, len(ps))
+	for _, res := range series {
+		xs = append(xs, res.Time.Seconds())
+		rate = append(rate, res.Rate)
+		clients = append(clients, float64(res.ActiveClients))
+		errorRate = append(errorRate, errorPercent(res))
+		mean = append(mean, res.Mean)
+		min = append(min, res.Min)
+		max = append(max, res.Max)
+		for i := range ps {
+			v := 0.0
+			if i < len(res.Percentiles) {
+				v = res.Percentiles[i]
+			}
+			perc[i] = append(perc[i], v)
+		}
+	}
+	latency := []chartSeries{{Name: "min", Values: min}, {Name: "mean", Values: mean}}
+	for i, name := range ps.Names() {
+		latency = append(latency, chartSeries{Name: name, Values: perc[i]})
+	}
+	latency = append(latency, chartSeries{Name: "max", Values: max})
+
+	var periods []*jsonResult
+	for _, res := range series {
+		periods = append(periods, newJsonResult(res, ps))
+	}
+	data, err := json.Marshal(map[string]interface{}{"summary": newJsonSummary(s, ps), "periods": periods})
+	if err != nil {
+		return err
+	}
+	labels, counts := latencyBins(hist, 40)
+	return reportTemplate.Execute(w, &reportData{
+		Summary: s,
+		Names:   ps.Names(),
+		Charts: []template.HTML{
+			lineChart("Throughput", "r/s", xs, []chartSeries{{Name: "rate", Values: rate}}),
+			lineChart("Latency", "ms", xs, latency),
+			lineChart("Active clients", "", xs, []chartSeries{{Name: "clients", Values: clients}}),
+			lineChart("Error rate", "%", xs, []chartSeries{{Name: "errors", Values: errorRate}}),
+		},
+		Histogram:   barChart("Latency histogram", "ms", labels, counts),
+		Data:        template.JS(data),
+		GeneratedAt: time.Now(),
+	})
+}
+
+func errorPercent(res *result) float64 {
+	if res.Count+res.ErrCount == 0 {
+		return 0
+	}
+	return float64(res.ErrCount) * 100 / float64(res.Count+res.ErrCount)
+}
+
+// latencyBins groups the histogram into at most n logarithmic bins, labels are the bin upper bounds in ms
+func latencyBins(h *Histogram, n int) ([]string, []float64) {
+	if h.Count() == 0 {
+		return nil, nil
+	}
+	lo := math.Max(float64(h.Min()), 1)
+	hi := math.Max(float64(h.Max()), lo+1)
+	step := math.Log(hi/lo) / float64(n)
+	counts := make([]float64, n)
+	h.Buckets(func(from int64, to int64, count int64) {
+		mid := math.Max(float64(from+to)/2, lo)
+		i := int(math.Log(mid/lo) / step)
+		if i >= n {
+			i = n - 1
+		}
+		counts[i] += float64(count)
+	})
+	labels := make([]string, n)
+	for i := range labels {
+		labels[i] = fmt.Sprintf("%.3g", lo*math.Exp(step*float64(i+1))/1000)
+	}
+	return labels, counts
+}
+
+const (
+	chartWidth  = 860
+	chartHeight = 260
+	chartLeft   = 60
+	chartRight  = 20
+	chartTop    = 30
+	chartBottom = 30
+)
+
+// lineChart draws the series as an inline SVG line chart, xs are seconds since the start of the test
+func lineChart(title string, unit string, xs []float64, series []chartSeries) template.HTML {
+	buf := &bytes.Buffer{}
+	chartStart(buf, title, unit)
+	maxX, maxY := 1.0, 0.0
+	for _, x := range xs {
+		maxX = math.Max(maxX, x)
+	}
+	for _, s := range series {
+		for _, v := range s.Values {
+			maxY = math.Max(maxY, v)
+		}
+	}
+	maxY = niceMax(maxY)
+	chartAxes(buf, maxY)
+	for i := 0; i <= 4; i++ {
+		x := chartLeft + float64(chartWidth-chartLeft-chartRight)*float64(i)/4
+		fmt.Fprintf(buf, `<text x="%.1f" y="%d" text-anchor="middle">%.0fs</text>`, x, chartHeight-chartBottom+16, maxX*float64(i)/4)
+	}
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+		buf.WriteString(`<polyline fill="none" stroke-width="1.5" stroke="` + color + `" points="`)
+		for j, v := range s.Values {
+			if j < len(xs) {
+				fmt.Fprintf(buf, "%.1f,%.1f ", chartX(xs[j], maxX), chartY(v, maxY))
+			}
+		}
+		buf.WriteString(`"/>`)
+		fmt.Fprintf(buf, `<text x="%d" y="%d" fill="%s" text-anchor="end">%s</text>`, chartWidth-chartRight-(len(series)-1-i)*70, chartTop-10, color, html.EscapeString(s.Name))
+	}
+	buf.WriteString("</svg>")
+	return template.HTML(buf.String())
+}
+
+// barChart draws the values as an inline SVG bar chart
+func barChart(title string, unit string, labels []string, values []float64) template.HTML {
+	buf := &bytes.Buffer{}
+	chartStart(buf, title, unit)
+	maxY := 0.0
+	for _, v := range values {
+		maxY = math.Max(maxY, v)
+	}
+	maxY = niceMax(maxY)
+	chartAxes(buf, maxY)
+	if len(values) > 0 {
+		width := float64(chartWidth-chartLeft-chartRight) / float64(len(values))
+		for i, v := range values {
+			x := chartLeft + width*float64(i)
+			y := chartY(v, maxY)
+			fmt.Fprintf(buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>&le; %s %s: %.0f</title></rect>`,
+				x+1, y, math.Max(width-2, 1), float64(chartHeight-chartBottom)-y, chartColors[1], labels[i], unit, v)
+			if i%5 == 4 || i == len(values)-1 {
+				fmt.Fprintf(buf, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x+width, chartHeight-chartBottom+16, labels[i])
+			}
+		}
+	}
+	buf.WriteString("</svg>")
+	return template.HTML(buf.String())
+}
+
+func chartStart(buf *bytes.Buffer, title string, unit string) {
+	fmt.Fprintf(buf, `<svg class="chart" viewBox="0 0 %d %d" width="%d" height="%d">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	label := title
+	if unit != "" {
+		label += " (" + unit + ")"
+	}
+	fmt.Fprintf(buf, `<text class="title" x="%d" y="%d">%s</text>`, chartLeft, chartTop-10, html.EscapeString(label))
+}
+
+func chartAxes(buf *bytes.Buffer, maxY float64) {
+	for i := 0; i <= 4; i++ {
+		v := maxY * float64(i) / 4
+		y := chartY(v, maxY)
+		fmt.Fprintf(buf, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`, chartLeft, y, chartWidth-chartRight, y)
+		fmt.Fprintf(buf, `<text x="%d" y="%.1f" text-anchor="end">%.4g</text>`, chartLeft-6, y+4, v)
+	}
+}
+
+func chartX(x float64, maxX float64) float64 {
+	return chartLeft + x/maxX*float64(chartWidth-chartLeft-chartRight)
+}
+
+func chartY(v float64, maxY float64) float64 {
+	return float64(chartHeight-chartBottom) - v/maxY*float64(chartHeight-chartTop-chartBottom)
+}
+
+// niceMax rounds the maximum up to 1, 2 or 5 times a power of ten
+func niceMax(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	p := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*p {
+			return m * p
+		}
+	}
+	return 10 * p
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms":     func(v float64) string { return fmt.Sprintf("%.1f", v) },
+	"rate":   func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"errpct": func(res *result) string { return fmt.Sprintf("%.2f%%", errorPercent(res)) },
+	"clock":  func(t time.Time) string { return t.Format(ERROR_TIME_FORMAT) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>lotgo report: {{.Summary.Config.Test}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 3px 8px; text-align: right; }
+th { background: #f4f4f4; }
+td.text { text-align: left; }
+svg.chart { display: block; margin-bottom: 1em; font-size: 11px; }
+svg.chart text.title { font-size: 14px; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>lotgo report: {{.Summary.Config.Test}}</h1>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>Configuration</h2>
+{{with .Summary.Config}}<table>
+<tr><th>Test</th><td class="text">{{.Test}}</td></tr>
+<tr><th>Clients</th><td>{{.Clients}}</td></tr>
+<tr><th>Runs</th><td>{{.Runs}}</td></tr>
+<tr><th>Duration</th><td>{{.Duration}}</td></tr>
+<tr><th>Sleep</th><td>{{.Sleep}}</td></tr>
+<tr><th>Rampup</th><td>{{.Rampup}}</td></tr>
+<tr><th>Period</th><td>{{.Period}}</td></tr>
+<tr><th>Start</th><td>{{.Start.Format "2006-01-02 15:04:05"}}</td></tr>
+<tr><th>End</th><td>{{.End.Format "2006-01-02 15:04:05"}}</td></tr>
+</table>{{end}}
+{{with .Summary.Environment}}<table>
+<tr><th>Host</th><td class="text">{{.Hostname}}</td></tr>
+<tr><th>Go</th><td class="text">{{.GoVersion}} {{.OS}}/{{.Arch}}</td></tr>
+<tr><th>CPUs / GOMAXPROCS</th><td>{{.NumCPU}} / {{.GOMAXPROCS}}</td></tr>
+</table>{{end}}
+
+<h2>Results</h2>
+<table>
+<tr><th>test</th><th>step</th><th>count</th><th>min</th><th>mean</th>{{range .Names}}<th>{{.}}</th>{{end}}<th>max</th><th>rate</th><th>errors</th><th>error rate</th></tr>
+{{with .Summary.Total}}<tr><td class="text"><b>total</b></td><td></td><td>{{.Count}}</td><td>{{ms .Min}}</td><td>{{ms .Mean}}</td>{{range .Percentiles}}<td>{{ms .}}</td>{{end}}<td>{{ms .Max}}</td><td>{{rate .Rate}}</td><td>{{.ErrCount}}</td><td>{{errpct .}}</td></tr>{{end}}
+{{range .Summary.Tests}}<tr><td class="text">{{.Test}}</td><td></td><td>{{.Count}}</td><td>{{ms .Min}}</td><td>{{ms .Mean}}</td>{{range .Percentiles}}<td>{{ms .}}</td>{{end}}<td>{{ms .Max}}</td><td>{{rate .Rate}}</td><td>{{.ErrCount}}</td><td>{{errpct .}}</td></tr>
+{{end}}{{range .Summary.Steps}}<tr><td class="text">{{.Test}}</td><td class="text">{{.Step}}</td><td>{{.Count}}</td><td>{{ms .Min}}</td><td>{{ms .Mean}}</td>{{range .Percentiles}}<td>{{ms .}}</td>{{end}}<td>{{ms .Max}}</td><td>{{rate .Rate}}</td><td>{{.ErrCount}}</td><td>{{errpct .}}</td></tr>
+{{end}}</table>
+<p>Latencies in milliseconds, rate in iterations per second.</p>
+
+<h2>Charts</h2>
+{{range .Charts}}{{.}}
+{{end}}{{.Histogram}}
+
+<h2>Errors</h2>
+{{if .Summary.Errors}}<table>
+<tr><th>class</th><th>count</th><th>first</th><th>last</th><th>example</th></tr>
+{{range .Summary.Errors}}<tr><td class="text">{{.Class}}</td><td>{{.Count}}</td><td>{{clock .First}}</td><td>{{clock .Last}}</td><td class="text">{{.Example}}</td></tr>
+{{end}}</table>{{else}}<p>No errors.</p>{{end}}
+
+<script type="application/json" id="lotgo-data">{{.Data}}</script>
+</body>
+</html>
+`))
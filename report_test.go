@@ -0,0 +1,79 @@
+package lotgo
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportWriter(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	runner := New(2, 0, 250*time.Millisecond, time.Millisecond, 50*time.Millisecond, &stepTest{}, nil, nil, 0, false)
+	plogger := NewPeriodLogger(50*time.Millisecond, bytes.NewBuffer(make([]byte, 0)), &MdFormat{}, DefaultPercentiles, DefaultPrecision)
+	slogger := NewSummaryLogger(0, bytes.NewBuffer(make([]byte, 0)), &CsvFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	runner.allListeners = Listeners{plogger, slogger, NewReportWriter(buf, plogger, slogger)}
+	runner.Run()
+
+	report := buf.String()
+	require.True(t, strings.HasPrefix(report, "<!DOCTYPE html>"))
+	assert.Contains(t, report, "<title>lotgo report: stepTest</title>")
+	assert.Contains(t, report, "<th>p99.9</th>")
+	assert.Contains(t, report, "Throughput (r/s)")
+	assert.Contains(t, report, "Latency histogram (ms)")
+	assert.Contains(t, report, "<td class=\"text\">out of stock</td>")
+	assert.Contains(t, report, `<script type="application/json" id="lotgo-data">{"periods":[{`)
+	assert.NotContains(t, report, "http://")
+	assert.NotContains(t, report, "https://")
+}
+
+// closingBuffer records that the report was closed
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestReportWriter_ShortRun(t *testing.T) {
+	buf := &closingBuffer{}
+	runner := New(1, 0, 100*time.Millisecond, time.Millisecond, time.Second, &stepTest{}, nil, nil, 0, false)
+	plogger := NewPeriodLogger(time.Second, bytes.NewBuffer(make([]byte, 0)), &MdFormat{}, DefaultPercentiles, DefaultPrecision)
+	slogger := NewSummaryLogger(0, bytes.NewBuffer(make([]byte, 0)), &CsvFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	runner.allListeners = Listeners{plogger, slogger, NewReportWriter(buf, plogger, slogger)}
+	runner.Run()
+
+	require.Equal(t, 1, len(plogger.Series()))
+	assert.True(t, plogger.Series()[0].ErrCount > 0)
+	assert.True(t, buf.closed)
+	assert.Contains(t, buf.String(), `<script type="application/json" id="lotgo-data">{"periods":[{`)
+}
+
+func TestLatencyBins(t *testing.T) {
+	h := NewHistogram(3)
+	labels, counts := latencyBins(h, 10)
+	assert.Nil(t, labels)
+	for i := int64(1); i <= 1000; i++ {
+		h.RecordValue(i * 100)
+	}
+	labels, counts = latencyBins(h, 10)
+	assert.Equal(t, 10, len(labels))
+	assert.Equal(t, "100", labels[9])
+	total := 0.0
+	for _, c := range counts {
+		total += c
+	}
+	assert.Equal(t, 1000.0, total)
+}
+
+func TestNiceMax(t *testing.T) {
+	assert.Equal(t, 1.0, niceMax(0))
+	assert.Equal(t, 2.0, niceMax(1.5))
+	assert.Equal(t, 500.0, niceMax(420))
+	assert.Equal(t, 1000.0, niceMax(999))
+}
@@ -2,27 +2,43 @@ package lotgo
 
 import (
 	"errors"
+	"flag"
 	"fmt"
-	"io"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"runtime"
 )
 
+const PAUSE_POLL_INTERVAL = 10 * time.Millisecond
+
 type Runner struct {
 	clients       int
 	runs          int
 	duration      time.Duration
 	sleep         time.Duration
+	period        time.Duration
 	test          LoadTest
-	done          bool
-	errout        io.Writer
+	name          string
+	done          int32
 	rampup        time.Duration
 	activeClients int32
-	allListeners  Listener
-	stopped       bool
+	allListeners  SampleListener
+	stopped       int32
+	paused        int32
+	parked        int32
 	startTime     time.Time
+	classifier    ErrorClassifier
+	health        *healthMonitor
+	metricsOnce   sync.Once
+	metrics       *customMetrics
+	params        Params
+	root          *Runner
+	client        int
+	iteration     int
+	step          string
 }
 
 type EndCondition interface {
@@ -52,20 +68,115 @@ func (c *TimeCondition) Run() bool {
 	return time.Since(c.Start) < c.Duration
 }
 
+// clientView returns the runner given to the test of a client. The view reports the steps, checks and metrics
+// of the test with the client and the iteration, the state of the run is kept by the shared runner.
+func (runner *Runner) clientView(client int) *Runner {
+	return &Runner{root: runner, client: client, clients: runner.clients, runs: runner.runs, duration: runner.duration,
+		sleep: runner.sleep, period: runner.period, test: runner.test, name: runner.name, rampup: runner.rampup,
+		allListeners: runner.allListeners, startTime: runner.startTime, classifier: runner.classifier, params: runner.params}
+}
+
+// shared returns the runner shared by all clients
+func (runner *Runner) shared() *Runner {
+	if runner.root != nil {
+		return runner.root
+	}
+	return runner
+}
+
+// Client returns the index of the client running the test from 0, or -1 outside of the clients
+func (runner *Runner) Client() int {
+	if runner.root == nil {
+		return -1
+	}
+	return runner.client
+}
+
+// Iteration returns the number of the current test pass of the client from 1, or 0 outside of the clients
+func (runner *Runner) Iteration() int {
+	return runner.iteration
+}
+
 func (runner *Runner) Stop() {
-	runner.stopped = true
+	atomic.StoreInt32(&runner.shared().stopped, 1)
+}
+
+func (runner *Runner) isStopped() bool {
+	return atomic.LoadInt32(&runner.shared().stopped) == 1
+}
+
+// Pause stops the clients from starting new iterations until Resume, the paused time counts towards the duration
+func (runner *Runner) Pause() {
+	atomic.StoreInt32(&runner.shared().paused, 1)
+}
+
+// Resume continues a paused run
+func (runner *Runner) Resume() {
+	atomic.StoreInt32(&runner.shared().paused, 0)
+}
+
+func (runner *Runner) IsPaused() bool {
+	return atomic.LoadInt32(&runner.shared().paused) == 1
+}
+
+// SetClientLimit adjusts the load by letting only the first n clients run, the other clients wait
+// after their current iteration and do not count as active. The limit is between 0 and the number of clients.
+func (runner *Runner) SetClientLimit(n int) {
+	if n < 0 {
+		n = 0
+	}
+	if n > runner.clients {
+		n = runner.clients
+	}
+	atomic.StoreInt32(&runner.shared().parked, int32(runner.clients-n))
+}
+
+// ClientLimit returns the number of clients allowed to run
+func (runner *Runner) ClientLimit() int {
+	return runner.clients - int(atomic.LoadInt32(&runner.shared().parked))
+}
+
+func (runner *Runner) isParked(client int) bool {
+	return client >= runner.ClientLimit()
+}
+
+// waitWhilePaused blocks a client while the run is paused or the client is above the client limit, and not stopped.
+// Returns true if the client was blocked.
+func (runner *Runner) waitWhilePaused(client int) bool {
+	waited := false
+	parked := false
+	for (runner.IsPaused() || runner.isParked(client)) && !runner.isStopped() {
+		if parked != runner.isParked(client) {
+			parked = !parked
+			if parked {
+				atomic.AddInt32(&runner.activeClients, -1)
+			} else {
+				atomic.AddInt32(&runner.activeClients, 1)
+			}
+		}
+		time.Sleep(PAUSE_POLL_INTERVAL)
+		waited = true
+	}
+	if parked {
+		atomic.AddInt32(&runner.activeClients, 1)
+	}
+	return waited
 }
 
 func (runner *Runner) IsDone() bool {
-	return runner.done
+	return atomic.LoadInt32(&runner.shared().done) == 1
 }
 
 func (runner *Runner) setDone(done bool) {
-	runner.done = done
+	var v int32
+	if done {
+		v = 1
+	}
+	atomic.StoreInt32(&runner.done, v)
 }
 
 func (runner *Runner) ActiveClients() int32 {
-	return atomic.LoadInt32(&runner.activeClients)
+	return atomic.LoadInt32(&runner.shared().activeClients)
 }
 
 func (runner *Runner) Run() {
@@ -75,18 +186,19 @@ func (runner *Runner) Run() {
 	finishedWG.Add(runner.clients)
 	setupWG := &sync.WaitGroup{}
 	setupWG.Add(runner.clients)
+	runner.startTime = time.Now()
 	runner.allListeners.Started(runner)
 	LOG().Infof("Listeners started")
 	LOG().Infof("Runner starting clients")
-	runner.startTime = time.Now()
 	for p := 0; p < runner.clients; p++ {
 		myTest := deepClone(runner.test)
 		rampupDelay := time.Duration(0)
 		if runner.rampup > 0 {
 			rampupDelay = runner.rampup * time.Duration(p) / time.Duration(runner.clients)
 		}
+		client := p
 		go func() {
-			runner.runTest(myTest, setupWG, rampupDelay)
+			runner.runTest(client, myTest, setupWG, rampupDelay)
 			finishedWG.Done()
 		}()
 	}
@@ -99,23 +211,43 @@ func (runner *Runner) Run() {
 	LOG().Infof("Runner run complete")
 }
 
-func (runner *Runner) runTest(test LoadTest, setupWG *sync.WaitGroup, rampupDelay time.Duration) {
+func (runner *Runner) runTest(client int, test LoadTest, setupWG *sync.WaitGroup, rampupDelay time.Duration) {
 	LOG().Debugf("Client starting")
 	end := runner.EndCondition()
-	test.SetUp(runner)
-	defer test.TearDown(runner)
+	view := runner.clientView(client)
+	test.SetUp(view)
+	defer test.TearDown(view)
 	setupWG.Done()
+	scheduled := time.Now().Add(rampupDelay)
 	time.Sleep(rampupDelay)
 	atomic.AddInt32(&runner.activeClients, 1)
-	for end.Run() && !runner.stopped {
+	st, sampled := test.(SampleTest)
+	for n := 1; end.Run() && !runner.isStopped(); n++ {
+		if runner.waitWhilePaused(client) {
+			scheduled = time.Now()
+		}
+		if runner.isStopped() {
+			break
+		}
+		view.iteration = n
+		s := &Sample{}
 		ts := time.Now()
-		err := test.Test(runner)
-		dur := time.Since(ts)
-		if err == nil {
-			runner.allListeners.Success(dur)
+		if runner.health != nil {
+			runner.health.startLag(view, ts.Sub(scheduled))
+		}
+		var err error
+		if sampled {
+			err = st.TestSample(view, s)
 		} else {
-			runner.allListeners.Error(err)
+			err = test.Test(view)
+		}
+		s.Iteration = Iteration{Start: ts, Duration: time.Since(ts), Client: client, Number: n, Test: runner.name}
+		if err != nil {
+			s.Err = err
+			s.Class = runner.classifyError(err)
 		}
+		runner.allListeners.Sample(s)
+		scheduled = time.Now().Add(runner.sleep)
 		if runner.sleep > 0 && end.Run() {
 			time.Sleep(runner.sleep)
 		}
@@ -124,8 +256,132 @@ func (runner *Runner) runTest(test LoadTest, setupWG *sync.WaitGroup, rampupDela
 	atomic.AddInt32(&runner.activeClients, -1)
 }
 
-func (runner *Runner) logError(err error, dur time.Duration) {
-	runner.errout.Write([]byte(fmt.Sprintf("%s (%dms) Error : %s\n", time.Now().Format(time.RFC822Z), dur/time.Millisecond, err.Error())))
+// SetErrorClassifier sets the classifier used for grouping test errors, call before Run
+func (runner *Runner) SetErrorClassifier(c ErrorClassifier) {
+	runner.classifier = c
+}
+
+// SetParams sets the test parameters, replacing the ones given with -param, call before Run
+func (runner *Runner) SetParams(p Params) {
+	runner.params = p
+}
+
+// AddListener adds a listener of the samples, and of the custom metrics if it is a MetricListener, call before Run
+func (runner *Runner) AddListener(l SampleListener) {
+	if runner.allListeners == nil {
+		runner.allListeners = Listeners{l}
+		return
+	}
+	runner.allListeners = Listeners{runner.allListeners, l}
+}
+
+func (runner *Runner) classifyError(err error) string {
+	return classifyError(runner.classifier, err)
+}
+
+// classifyError classifies err with c or the DefaultErrorClassifier if c is nil
+func classifyError(c ErrorClassifier, err error) string {
+	if c == nil {
+		c = DefaultErrorClassifier
+	}
+	class := c.Classify(err)
+	if class == "" {
+		class = CLASS_OTHER
+	}
+	return class
+}
+
+// Step runs fn as a named step of the test. The step is timed and reported to the listeners
+// separately from the test pass and an error returned by fn is reported with the step name.
+func (runner *Runner) Step(name string, fn func() error) error {
+	return runner.StepSample(name, func(s *Sample) error {
+		return fn()
+	})
+}
+
+// StepSample runs fn as a named step like Step, fn can annotate the sample of the step with tags and transferred bytes.
+func (runner *Runner) StepSample(name string, fn func(s *Sample) error) error {
+	s := &Sample{}
+	parent := runner.step
+	runner.step = name
+	ts := time.Now()
+	err := fn(s)
+	runner.step = parent
+	s.Iteration = Iteration{Start: ts, Duration: time.Since(ts), Client: runner.Client(), Number: runner.iteration, Test: runner.name, Step: name}
+	if err == nil {
+		runner.allListeners.Sample(s)
+		return nil
+	}
+	s.Err = err
+	s.Class = runner.classifyError(err)
+	runner.allListeners.Sample(s)
+	if stepName(err) == "" {
+		return &stepError{step: name, err: err}
+	}
+	return err
+}
+
+// stepError is an error returned from a named step
+type stepError struct {
+	step string
+	err  error
+}
+
+func (e *stepError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+func stepName(err error) string {
+	var se *stepError
+	if errors.As(err, &se) {
+		return se.step
+	}
+	return ""
+}
+
+// Params are the name=value parameters given to the test with -param
+type Params map[string]string
+
+var _ flag.Value = &Params{}
+
+func (p *Params) String() string {
+	var parts []string
+	for k, v := range *p {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+// Set parses one name=value pair, the value can contain commas and equal signs
+func (p *Params) Set(s string) error {
+	if *p == nil {
+		*p = Params{}
+	}
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+		return fmt.Errorf("invalid parameter '%s', expected name=value", s)
+	}
+	(*p)[strings.TrimSpace(kv[0])] = kv[1]
+	return nil
+}
+
+// Param returns the value of a test parameter given with -param name=value, empty if not given
+func (runner *Runner) Param(name string) string {
+	return runner.params[name]
+}
+
+// Params returns a copy of the test parameters
+func (runner *Runner) Params() Params {
+	c := Params{}
+	for k, v := range runner.params {
+		c[k] = v
+	}
+	return c
 }
 
 func (runner *Runner) EndCondition() EndCondition {
@@ -137,11 +393,11 @@ func (runner *Runner) EndCondition() EndCondition {
 }
 
 func (runner *Runner) NewErr(str string, args ...interface{}) error {
-	return errors.New(fmt.Sprintf(str, args))
+	return errors.New(fmt.Sprintf(str, args...))
 }
 
 func (runner *Runner) Fail(args ...interface{}) {
 	if len(args) > 0 && args[0] != nil {
-		LOG().Fatal(args)
+		LOG().Fatal(args...)
 	}
 }
@@ -1,7 +1,10 @@
 package lotgo
 
 import (
+	"bytes"
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -43,6 +46,42 @@ func TestRunner_RightNumberOfTestRunsMultiProc(t *testing.T) {
 	assert.Equal(t, int32(10000), myCount)
 }
 
+func TestRunner_PauseAndResume(t *testing.T) {
+	atomic.StoreInt32(&myCount, 0)
+	runner := New(2, 100, 0, 0, time.Second, &myTest{}, nil, nil, 0, false)
+	runner.Pause()
+	go runner.Run()
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, runner.IsPaused())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&myCount))
+	runner.Resume()
+	for !runner.IsDone() {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, int32(200), atomic.LoadInt32(&myCount))
+}
+
+func TestRunner_ClientLimit(t *testing.T) {
+	atomic.StoreInt32(&myCount, 0)
+	runner := New(4, 0, 300*time.Millisecond, time.Millisecond, time.Second, &myTest{}, nil, nil, 0, false)
+	runner.SetClientLimit(1)
+	assert.Equal(t, 1, runner.ClientLimit())
+	go runner.Run()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), runner.ActiveClients())
+	runner.SetClientLimit(10)
+	assert.Equal(t, 4, runner.ClientLimit())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(4), runner.ActiveClients())
+	runner.SetClientLimit(-1)
+	assert.Equal(t, 0, runner.ClientLimit())
+	runner.Stop()
+	for !runner.IsDone() {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, int32(0), runner.ActiveClients())
+}
+
 func TestRunner_TimeDuration(t *testing.T) {
 	e := &TimeCondition{Start: time.Now(), Duration: time.Second}
 	assert.True(t, e.Run())
@@ -61,3 +100,22 @@ func TestRunner_RightDurationOfTestRun(t *testing.T) {
 	assert.True(t, time.Millisecond*100 < d)
 	assert.True(t, time.Millisecond*200 > d)
 }
+
+type failingTest struct {
+	myTest
+}
+
+func (e *failingTest) Test(lt *Runner) error {
+	return lt.Step("login", func() error {
+		return lt.Step("inner", func() error { return errors.New("connection refused") })
+	})
+}
+
+func TestRunner_ErrorLog(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	runner := New(2, 3, 0, 0, time.Second, &failingTest{}, nil, buf, 0, false)
+	runner.Run()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 6, len(lines))
+	assert.Contains(t, lines[0], `"test":"failingTest","step":"inner","class":"connection refused","message":"connection refused"`)
+}
@@ -0,0 +1,44 @@
+package lotgo
+
+// Sample is a test pass or a step with everything known about it.
+// Tags and the transferred bytes are set by tests implementing SampleTest or by steps run with StepSample.
+type Sample struct {
+	Iteration
+	Tags          Tags
+	BytesSent     int64
+	BytesReceived int64
+	Err           error
+}
+
+// SampleListener receives every test pass and step as a single sample
+type SampleListener interface {
+	Started(runner *Runner)
+	Sample(s *Sample)
+	Finished()
+}
+
+// SampleTest is implemented by tests which annotate their samples, TestSample is called instead of Test
+type SampleTest interface {
+	TestSample(lt *Runner, s *Sample) error
+}
+
+// AdaptListener returns a SampleListener passing the samples of the test passes to l as Success and Error calls,
+// the steps are left out so that l counts the test passes
+func AdaptListener(l Listener) SampleListener {
+	return &listenerAdapter{Listener: l}
+}
+
+type listenerAdapter struct {
+	Listener
+}
+
+func (a *listenerAdapter) Sample(s *Sample) {
+	if s.Step != "" {
+		return
+	}
+	if s.Err == nil {
+		a.Success(s.Duration)
+	} else {
+		a.Error(s.Err)
+	}
+}
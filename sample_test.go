@@ -0,0 +1,157 @@
+package lotgo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sampleRecorder struct {
+	sync.Mutex
+	samples  []*Sample
+	finished bool
+}
+
+func (r *sampleRecorder) Started(runner *Runner) {
+}
+
+func (r *sampleRecorder) Sample(s *Sample) {
+	r.Lock()
+	r.samples = append(r.samples, s)
+	r.Unlock()
+}
+
+func (r *sampleRecorder) Finished() {
+	r.finished = true
+}
+
+type taggedTest struct {
+	myTest
+}
+
+func (e *taggedTest) TestSample(lt *Runner, s *Sample) error {
+	s.Tags = Tags{"region": "eu"}
+	s.BytesSent = 100
+	return lt.StepSample("upload", func(ss *Sample) error {
+		ss.BytesSent = 100
+		ss.BytesReceived = 20
+		return errors.New("quota exceeded")
+	})
+}
+
+func TestListeners_Sample(t *testing.T) {
+	rec := &sampleRecorder{}
+	counter := &countingListener{}
+	runner := &Runner{clients: 1, runs: 2, name: "tagged", test: &taggedTest{}}
+	runner.allListeners = Listeners{}.Add(counter).AddSample(rec)
+	runner.Run()
+
+	require.Equal(t, 4, len(rec.samples))
+	assert.True(t, rec.finished)
+	step := rec.samples[0]
+	assert.Equal(t, "upload", step.Step)
+	assert.Equal(t, 0, step.Client)
+	assert.Equal(t, 1, step.Number)
+	assert.Equal(t, int64(20), step.BytesReceived)
+	assert.EqualError(t, step.Err, "quota exceeded")
+	it := rec.samples[1]
+	assert.Equal(t, "", it.Step)
+	assert.Equal(t, 0, it.Client)
+	assert.Equal(t, 1, it.Number)
+	assert.Equal(t, "eu", it.Tags["region"])
+	assert.Equal(t, int64(100), it.BytesSent)
+	assert.Equal(t, "quota exceeded", it.Class)
+	assert.Equal(t, 2, rec.samples[2].Number)
+	assert.Equal(t, 2, rec.samples[3].Number)
+
+	assert.Equal(t, 2, counter.errors)
+}
+
+type countingListener struct {
+	sync.Mutex
+	success int
+	errors  int
+	time    time.Duration
+}
+
+func (l *countingListener) Started(runner *Runner) {
+}
+
+func (l *countingListener) Success(d time.Duration) {
+	l.Lock()
+	l.success++
+	l.time += d
+	l.Unlock()
+}
+
+func (l *countingListener) Error(err error) {
+	l.Lock()
+	l.errors++
+	l.Unlock()
+}
+
+func (l *countingListener) Finished() {
+}
+
+func TestAdaptListener(t *testing.T) {
+	counter := &countingListener{}
+	sl := AdaptListener(counter)
+	sl.Sample(&Sample{Iteration: Iteration{Duration: time.Second}})
+	sl.Sample(&Sample{Err: errors.New("x")})
+	sl.Sample(&Sample{Iteration: Iteration{Duration: time.Second}})
+	sl.Sample(&Sample{Iteration: Iteration{Step: "login"}})
+	assert.Equal(t, 2, counter.success)
+	assert.Equal(t, 1, counter.errors)
+	assert.Equal(t, 2*time.Second, counter.time)
+
+	rec := &sampleRecorder{}
+	l := Listeners{}.Add(counter).AddSample(rec)
+	l.Sample(&Sample{Iteration: Iteration{Number: 3}})
+	l.Sample(&Sample{Iteration: Iteration{Number: 4, Class: "y"}, Err: errors.New("y")})
+	require.Equal(t, 2, len(rec.samples))
+	assert.Equal(t, 3, rec.samples[0].Number)
+	assert.EqualError(t, rec.samples[1].Err, "y")
+	l.Success(time.Second)
+	l.Error(errors.New("z"))
+	require.Equal(t, 4, len(rec.samples))
+	assert.Equal(t, time.Second, rec.samples[2].Duration)
+	assert.EqualError(t, rec.samples[3].Err, "z")
+	assert.Equal(t, "z", rec.samples[3].Class)
+	assert.Equal(t, 4, counter.success)
+	assert.Equal(t, 3, counter.errors)
+}
+
+// clientStepTest runs a step tagged with the client and the iteration it sees, the second client stops the run
+type clientStepTest struct {
+	myTest
+}
+
+func (e *clientStepTest) Test(lt *Runner) error {
+	lt.Step(fmt.Sprintf("step %d/%d", lt.Client(), lt.Iteration()), func() error { return nil })
+	if lt.Client() == 1 && lt.Iteration() == 3 {
+		lt.Stop()
+	}
+	return nil
+}
+
+func TestRunner_ClientSteps(t *testing.T) {
+	rec := &sampleRecorder{}
+	runner := &Runner{clients: 2, runs: 100, name: "steps", test: &clientStepTest{}}
+	runner.allListeners = Listeners{}.AddSample(rec)
+	runner.Run()
+
+	assert.True(t, runner.isStopped())
+	assert.Equal(t, -1, runner.Client())
+	steps := 0
+	for _, s := range rec.samples {
+		if s.Step != "" {
+			assert.Equal(t, fmt.Sprintf("step %d/%d", s.Client, s.Number), s.Step)
+			steps++
+		}
+	}
+	assert.True(t, steps >= 3 && steps < 200, "steps %d", steps)
+}
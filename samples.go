@@ -0,0 +1,188 @@
+package lotgo
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SAMPLES_MAGIC = "#lotgo-samples"
+
+var samplesHeader = []string{"start_us", "client", "iteration", "test", "step", "latency_us", "class"}
+
+// samplesMeta describes the run which recorded the samples
+type samplesMeta struct {
+	Test    string
+	Clients int
+	Period  time.Duration
+	Start   time.Time
+}
+
+var _ SampleListener = &samplesWriter{}
+
+/* Listener which records every iteration and step as a row of gzip compressed CSV */
+type samplesWriter struct {
+	sync.Mutex
+	writer io.Writer
+	gz     *gzip.Writer
+	csv    *csv.Writer
+	row    []string
+}
+
+// NewSamplesWriter creates a listener writing all samples to w, the samples are read back with lotgo analyze
+func NewSamplesWriter(w io.Writer) *samplesWriter {
+	gz := gzip.NewWriter(w)
+	return &samplesWriter{writer: w, gz: gz, csv: csv.NewWriter(gz), row: make([]string, len(samplesHeader))}
+}
+
+func (l *samplesWriter) Started(runner *Runner) {
+	l.Lock()
+	defer l.Unlock()
+	l.csv.Write([]string{SAMPLES_MAGIC, "test=" + runner.name, "clients=" + strconv.Itoa(runner.clients),
+		"period=" + runner.period.String(), "start_us=" + strconv.FormatInt(toMicros(runner.startTime), 10)})
+	l.csv.Write(samplesHeader)
+}
+
+func (l *samplesWriter) Sample(s *Sample) {
+	l.write(&s.Iteration)
+}
+
+func (l *samplesWriter) write(it *Iteration) {
+	l.Lock()
+	defer l.Unlock()
+	l.row[0] = strconv.FormatInt(toMicros(it.Start), 10)
+	l.row[1] = strconv.Itoa(it.Client)
+	l.row[2] = strconv.Itoa(it.Number)
+	l.row[3] = it.Test
+	l.row[4] = it.Step
+	l.row[5] = strconv.FormatInt(int64(it.Duration/time.Microsecond), 10)
+	l.row[6] = it.Class
+	l.csv.Write(l.row)
+}
+
+func (l *samplesWriter) Finished() {
+	l.Lock()
+	defer l.Unlock()
+	l.csv.Flush()
+	if err := l.csv.Error(); err != nil {
+		LOG().Errorf("Failed to write samples: %v", err)
+	}
+	l.gz.Close()
+	if c, ok := l.writer.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func toMicros(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Microsecond)
+}
+
+func fromMicros(us int64) time.Time {
+	return time.Unix(0, us*int64(time.Microsecond))
+}
+
+// samplesReader reads samples written by samplesWriter, both gzip compressed and plain CSV is accepted
+type samplesReader struct {
+	csv  *csv.Reader
+	meta *samplesMeta
+	line int
+}
+
+func newSamplesReader(r io.Reader) (*samplesReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil {
+		return nil, fmt.Errorf("not a samples file: %v", err)
+	}
+	var in io.Reader = br
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		in = gz
+	}
+	sr := &samplesReader{csv: csv.NewReader(in)}
+	sr.csv.FieldsPerRecord = -1
+	sr.csv.ReuseRecord = true
+	rec, err := sr.next()
+	if err != nil || len(rec) == 0 || rec[0] != SAMPLES_MAGIC {
+		return nil, errors.New("not a samples file, missing " + SAMPLES_MAGIC + " line")
+	}
+	if sr.meta, err = parseSamplesMeta(rec[1:]); err != nil {
+		return nil, err
+	}
+	rec, err = sr.next()
+	if err != nil || strings.Join(rec, ",") != strings.Join(samplesHeader, ",") {
+		return nil, fmt.Errorf("unexpected samples header, expected %s", strings.Join(samplesHeader, ","))
+	}
+	return sr, nil
+}
+
+func parseSamplesMeta(fields []string) (*samplesMeta, error) {
+	meta := &samplesMeta{}
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		var err error
+		switch kv[0] {
+		case "test":
+			meta.Test = kv[1]
+		case "clients":
+			meta.Clients, err = strconv.Atoi(kv[1])
+		case "period":
+			meta.Period, err = time.ParseDuration(kv[1])
+		case "start_us":
+			var us int64
+			us, err = strconv.ParseInt(kv[1], 10, 64)
+			meta.Start = fromMicros(us)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid samples %s '%s': %v", kv[0], kv[1], err)
+		}
+	}
+	return meta, nil
+}
+
+func (r *samplesReader) next() ([]string, error) {
+	r.line++
+	return r.csv.Read()
+}
+
+// Next returns the next sample, io.EOF at the end of the file
+func (r *samplesReader) Next() (*Iteration, error) {
+	rec, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if len(rec) != len(samplesHeader) {
+		return nil, fmt.Errorf("line %d: expected %d fields, got %d", r.line, len(samplesHeader), len(rec))
+	}
+	start, err := strconv.ParseInt(rec[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: invalid start_us '%s'", r.line, rec[0])
+	}
+	client, err := strconv.Atoi(rec[1])
+	if err != nil {
+		return nil, fmt.Errorf("line %d: invalid client '%s'", r.line, rec[1])
+	}
+	number, err := strconv.Atoi(rec[2])
+	if err != nil {
+		return nil, fmt.Errorf("line %d: invalid iteration '%s'", r.line, rec[2])
+	}
+	latency, err := strconv.ParseInt(rec[5], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: invalid latency_us '%s'", r.line, rec[5])
+	}
+	return &Iteration{Start: fromMicros(start), Client: client, Number: number, Test: rec[3], Step: rec[4],
+		Duration: time.Duration(latency) * time.Microsecond, Class: rec[6]}, nil
+}
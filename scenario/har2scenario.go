@@ -0,0 +1,763 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	client "github.com/huljas/lotgo/http"
+	"go/format"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	HAR_EXCLUDE   = `(?i)\.(css|js|mjs|map|png|jpe?g|gif|svg|ico|webp|avif|bmp|woff2?|ttf|otf|eot|mp4|webm|mp3)(\?|$)`
+	HAR_MIN_VALUE = 8
+	HAR_THINK     = 100 * time.Millisecond
+)
+
+// harOptions are the arguments of lotgo har2scenario
+type harOptions struct {
+	output   string
+	format   string
+	name     string
+	pkg      string
+	exclude  string
+	include  string
+	minThink time.Duration
+}
+
+// har2scenario converts a HAR recording of a browser session to a scenario for http/scenario:
+//
+//	lotgo har2scenario [flags] session.har
+//
+// The scenario is written to stdout or -o, the found dynamic values are reported to stderr.
+func har2scenario(args []string, out io.Writer, report io.Writer) error {
+	o := &harOptions{}
+	fs := flag.NewFlagSet("har2scenario", flag.ContinueOnError)
+	fs.StringVar(&o.output, "o", "", "Output file, default stdout")
+	fs.StringVar(&o.format, "format", "", "Output format yaml, json or go for LoadTest source, default by the -o extension or yaml")
+	fs.StringVar(&o.name, "name", "", "Name of the scenario and of the test in Go source, default the HAR file name")
+	fs.StringVar(&o.pkg, "package", "main", "Package of the Go source")
+	fs.StringVar(&o.exclude, "exclude", HAR_EXCLUDE, "Regex of the URLs of static assets left out of the scenario, empty keeps all")
+	fs.StringVar(&o.include, "include", "", "Regex of the URLs to keep, e.g. a host name, default all")
+	fs.DurationVar(&o.minThink, "min-think", 500*time.Millisecond, "Shortest pause between the requests recorded as think time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: lotgo har2scenario [flags] session.har")
+	}
+	file := fs.Arg(0)
+	if o.name == "" {
+		o.name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if o.format == "" {
+		switch filepath.Ext(o.output) {
+		case ".json":
+			o.format = "json"
+		case ".go":
+			o.format = "go"
+		default:
+			o.format = "yaml"
+		}
+	}
+	if o.format != "yaml" && o.format != "json" && o.format != "go" {
+		return fmt.Errorf("unknown -format %s, expected yaml, json or go", o.format)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return fmt.Errorf("%s is not a HAR file: %v", file, err)
+	}
+	c, err := newHarConverter(o, report)
+	if err != nil {
+		return err
+	}
+	s, err := c.convert(har.Log.Entries)
+	if err != nil {
+		return err
+	}
+	b, err := formatScenario(s, o)
+	if err != nil {
+		return err
+	}
+	if o.output == "" {
+		_, err = out.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(o.output, b, 0644)
+}
+
+// harFile is the part of the HTTP Archive format converted to a scenario
+type harFile struct {
+	Log struct {
+		Entries []*harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Started  time.Time   `json:"startedDateTime"`
+	Time     float64     `json:"time"`
+	Request  harRequest  `json:"request"`
+	Response harResponse `json:"response"`
+}
+
+type harRequest struct {
+	Method   string       `json:"method"`
+	URL      string       `json:"url"`
+	Headers  []harPair    `json:"headers"`
+	PostData *harPostData `json:"postData"`
+}
+
+type harPostData struct {
+	MimeType string    `json:"mimeType"`
+	Text     string    `json:"text"`
+	Params   []harPair `json:"params"`
+}
+
+type harResponse struct {
+	Status  int       `json:"status"`
+	Headers []harPair `json:"headers"`
+	Cookies []harPair `json:"cookies"`
+	Content struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"encoding"`
+	} `json:"content"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// end returns the time the response was received
+func (e *harEntry) end() time.Time {
+	return e.Started.Add(time.Duration(e.Time * float64(time.Millisecond)))
+}
+
+// body returns the response content, decoded if the HAR has it in base64
+func (r *harResponse) body() string {
+	if r.Content.Encoding == "base64" {
+		b, err := base64.StdEncoding.DecodeString(r.Content.Text)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+	return r.Content.Text
+}
+
+// harSkipHeaders are set by the client, the cookie jar or the browser cache and are left out of the steps
+var harSkipHeaders = map[string]bool{
+	"Host": true, "Content-Length": true, "Connection": true, "Keep-Alive": true, "Cookie": true,
+	"Accept-Encoding": true, "If-None-Match": true, "If-Modified-Since": true, "Te": true, "Upgrade": true,
+	"Transfer-Encoding": true, "Proxy-Connection": true,
+}
+
+// harStaticHeaders describe the browser and are never flagged as dynamic
+var harStaticHeaders = map[string]bool{
+	"User-Agent": true, "Accept": true, "Accept-Language": true, "Referer": true, "Origin": true, "Content-Type": true,
+}
+
+var (
+	harDynamicName  = regexp.MustCompile(`(?i)(csrf|xsrf|token|nonce|session|^sid$|signature)`)
+	harDynamicValue = regexp.MustCompile(`^([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,}|eyJ[\w-]*\.[\w-]+\.[\w-]*|[A-Za-z0-9+/_-]{32,}={0,2})$`)
+	harHtmlTag      = regexp.MustCompile(`(?i)<(input|meta)\s[^>]*>`)
+	harHtmlAttr     = regexp.MustCompile(`([\w-]+)\s*=\s*"([^"]*)"`)
+	harIdName       = regexp.MustCompile(`^(?i:id|uuid|location)$|(?i:[_-]id)$|[a-z](Id|ID)$`)
+	harVariableChar = regexp.MustCompile(`[^\w.-]+`)
+	harIdentWord    = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// harValue is a value of a response that later requests send back, e.g. a CSRF token or the id of a created resource
+type harValue struct {
+	value    string
+	escaped  string
+	name     string
+	step     int
+	extract  *ScenarioExtract
+	variable string
+	used     []int
+}
+
+// harConverter turns the entries to steps and replaces the dynamic values of the requests with extracted variables
+type harConverter struct {
+	opts     *harOptions
+	exclude  *regexp.Regexp
+	include  *regexp.Regexp
+	report   io.Writer
+	steps    []*ScenarioStep
+	values   []*harValue
+	known    map[string]bool
+	vars     map[string]bool
+	requests string
+}
+
+func newHarConverter(o *harOptions, report io.Writer) (*harConverter, error) {
+	c := &harConverter{opts: o, report: report, known: map[string]bool{}, vars: map[string]bool{}}
+	var err error
+	if o.exclude != "" {
+		if c.exclude, err = regexp.Compile(o.exclude); err != nil {
+			return nil, fmt.Errorf("invalid -exclude: %v", err)
+		}
+	}
+	if o.include != "" {
+		if c.include, err = regexp.Compile(o.include); err != nil {
+			return nil, fmt.Errorf("invalid -include: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// keep tells if the entry is a request of the scenario rather than a static asset
+func (c *harConverter) keep(e *harEntry) bool {
+	u := e.Request.URL
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return false
+	}
+	if c.include != nil && !c.include.MatchString(u) {
+		return false
+	}
+	return c.exclude == nil || !c.exclude.MatchString(u)
+}
+
+// convert builds the scenario from the entries in the order they were started
+func (c *harConverter) convert(entries []*harEntry) (*Scenario, error) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Started.Before(entries[j].Started) })
+	var kept []*harEntry
+	for _, e := range entries {
+		if c.keep(e) {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("no requests left of %d entries, check -include and -exclude", len(entries))
+	}
+	s := &Scenario{Name: c.opts.name, BaseURL: harOrigin(kept[0].Request.URL)}
+	for i, e := range kept {
+		step, err := c.step(i+1, e, s.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		c.correlate(i+1, step)
+		c.flag(i+1, step)
+		c.steps = append(c.steps, step)
+		c.requests += harRequestText(e)
+		c.collect(i+1, step, e)
+	}
+	c.thinkTimes(entries, kept)
+	s.Steps = c.steps
+	s.Headers = c.commonHeaders()
+	for _, v := range c.values {
+		if v.variable != "" {
+			fmt.Fprintf(c.report, "extract %s from step %d %s, used in steps %s\n", v.variable, v.step, v.describe(), harJoinInts(v.used))
+		}
+	}
+	fmt.Fprintf(c.report, "%d steps from %d entries, %d left out\n", len(kept), len(entries), len(entries)-len(kept))
+	return s, s.validate()
+}
+
+// step converts the request of an entry, the urls of the first origin are relative to the base url
+func (c *harConverter) step(n int, e *harEntry, base string) (*ScenarioStep, error) {
+	r := e.Request
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("entry %d: %v", n, err)
+	}
+	step := &ScenarioStep{Method: strings.ToUpper(r.Method), URL: r.URL, Checks: []*ScenarioCheck{{Status: e.Response.Status}}}
+	if harOrigin(r.URL) == base {
+		step.URL = strings.TrimPrefix(r.URL, base)
+		if step.URL == "" {
+			step.URL = "/"
+		}
+	}
+	step.Name = fmt.Sprintf("%d %s %s", n, step.Method, u.Path)
+	for _, h := range r.Headers {
+		key := http.CanonicalHeaderKey(h.Name)
+		if strings.HasPrefix(h.Name, ":") || harSkipHeaders[key] {
+			continue
+		}
+		if step.Headers == nil {
+			step.Headers = map[string]string{}
+		}
+		if _, ok := step.Headers[key]; !ok {
+			step.Headers[key] = h.Value
+		}
+	}
+	if r.PostData == nil || (r.PostData.Text == "" && len(r.PostData.Params) == 0) {
+		return step, nil
+	}
+	mime := strings.ToLower(r.PostData.MimeType)
+	switch {
+	case strings.HasPrefix(mime, client.CONTENTTYPE_FORM):
+		step.Form = map[string]string{}
+		values, err := url.ParseQuery(r.PostData.Text)
+		if err != nil || r.PostData.Text == "" {
+			values = url.Values{}
+			for _, p := range r.PostData.Params {
+				v, err := url.QueryUnescape(p.Value)
+				if err != nil {
+					v = p.Value
+				}
+				values.Add(p.Name, v)
+			}
+		}
+		for k := range values {
+			step.Form[k] = values.Get(k)
+		}
+	case strings.Contains(mime, "json") && json.Unmarshal([]byte(r.PostData.Text), &step.JSON) == nil && step.JSON != nil:
+	default:
+		step.JSON = nil
+		step.Body = r.PostData.Text
+		step.ContentType = r.PostData.MimeType
+	}
+	delete(step.Headers, "Content-Type")
+	if len(step.Headers) == 0 {
+		step.Headers = nil
+	}
+	return step, nil
+}
+
+// collect finds the values of the response which were not sent in any request so far
+func (c *harConverter) collect(n int, step *ScenarioStep, e *harEntry) {
+	add := func(value string, name string, extract *ScenarioExtract) {
+		if value == "" || value == "true" || value == "false" || value == "null" || c.known[value] || strings.Contains(c.requests, value) {
+			return
+		}
+		// short values and numbers are common words and counts, only the values of the id like keys are correlated
+		if _, err := strconv.ParseFloat(value, 64); (err == nil || len(value) < HAR_MIN_VALUE) && !harIdLike(name) {
+			return
+		}
+		c.known[value] = true
+		c.values = append(c.values, &harValue{value: value, escaped: url.QueryEscape(value), name: name, step: n, extract: extract})
+	}
+	for _, h := range e.Response.Headers {
+		key := http.CanonicalHeaderKey(h.Name)
+		if key == "Location" || harDynamicName.MatchString(key) {
+			add(h.Value, strings.ToLower(key), &ScenarioExtract{Header: key})
+		}
+	}
+	for _, ck := range e.Response.Cookies {
+		add(ck.Value, ck.Name, &ScenarioExtract{Cookie: ck.Name})
+	}
+	body := e.Response.body()
+	mime := strings.ToLower(e.Response.Content.MimeType)
+	if strings.Contains(mime, "json") {
+		var doc interface{}
+		if json.Unmarshal([]byte(body), &doc) == nil {
+			harJSONLeaves(doc, "", "", func(path, name, value string) {
+				add(value, name, &ScenarioExtract{JSON: path})
+			})
+		}
+	}
+	if strings.Contains(mime, "html") {
+		for _, tag := range harHtmlTag.FindAllString(body, -1) {
+			harHtmlValue(tag, func(name, value, expr string) {
+				add(value, name, &ScenarioExtract{Regex: expr})
+			})
+		}
+	}
+	sort.SliceStable(c.values, func(i, j int) bool { return len(c.values[i].value) > len(c.values[j].value) })
+}
+
+// harIdLike tells if the key names an id, a token or a location whose short and numeric values are correlated
+func harIdLike(name string) bool {
+	return harIdName.MatchString(name) || harDynamicName.MatchString(name)
+}
+
+// correlate replaces the values the request sends back with variables extracted from the earlier responses,
+// the longest values first so that a value is not replaced inside another
+func (c *harConverter) correlate(n int, step *ScenarioStep) {
+	for _, v := range c.values {
+		name := v.variable
+		if name == "" {
+			name = c.variable(v.name)
+		}
+		found := false
+		replace := func(s string) string {
+			out, ok := harReplaceToken(s, v.value, "{{"+name+"}}")
+			if !ok && v.escaped != v.value {
+				out, ok = harReplaceToken(s, v.escaped, "{{"+name+"}}")
+			}
+			found = found || ok
+			return out
+		}
+		step.URL = replace(step.URL)
+		for k, h := range step.Headers {
+			step.Headers[k] = replace(h)
+		}
+		for k, f := range step.Form {
+			step.Form[k] = replace(f)
+		}
+		step.Body = replace(step.Body)
+		step.JSON = harMapStrings(step.JSON, replace)
+		if !found {
+			continue
+		}
+		if v.variable == "" {
+			v.variable = name
+			c.vars[name] = true
+			c.steps[v.step-1].addExtract(name, v.extract)
+		}
+		v.used = append(v.used, n)
+	}
+}
+
+// variable returns an unused variable name which does not hide the built in variables
+func (c *harConverter) variable(name string) string {
+	name = strings.Trim(harVariableChar.ReplaceAllString(name, "_"), "_.-")
+	if name == "" {
+		name = "value"
+	}
+	switch name {
+	case "client", "iteration", "timestamp", "random":
+		name += "_value"
+	}
+	v := name
+	for i := 2; c.vars[v]; i++ {
+		v = fmt.Sprintf("%s_%d", name, i)
+	}
+	return v
+}
+
+func (step *ScenarioStep) addExtract(name string, e *ScenarioExtract) {
+	if step.Extract == nil {
+		step.Extract = map[string]*ScenarioExtract{}
+	}
+	step.Extract[name] = e
+}
+
+// flag reports the values of the request which look dynamic but were not found in the earlier responses
+func (c *harConverter) flag(n int, step *ScenarioStep) {
+	check := func(what, name, value string) {
+		if strings.Contains(value, "{{") {
+			return
+		}
+		if i := strings.IndexByte(value, ' '); i > 0 && what == "header" {
+			value = value[i+1:]
+		}
+		if value != "" && (harDynamicName.MatchString(name) || harDynamicValue.MatchString(value)) {
+			fmt.Fprintf(c.report, "candidate step %d %s %s: dynamic value %s not found in earlier responses\n", n, what, name, harShorten(value))
+		}
+	}
+	for _, k := range harSortedKeys(step.Headers) {
+		if !harStaticHeaders[k] {
+			check("header", k, step.Headers[k])
+		}
+	}
+	if u, err := url.Parse(step.URL); err == nil {
+		for _, seg := range strings.Split(u.Path, "/") {
+			if harDynamicValue.MatchString(seg) {
+				check("path", "segment", seg)
+			}
+		}
+		q := u.Query()
+		for _, k := range harSortedKeys(map[string][]string(q)) {
+			check("query", k, q.Get(k))
+		}
+	}
+	for _, k := range harSortedKeys(step.Form) {
+		check("form", k, step.Form[k])
+	}
+	harJSONLeaves(step.JSON, "", "", func(path, name, value string) {
+		check("json", path, value)
+	})
+}
+
+// thinkTimes sets the pauses after the steps, a pause ends the page loads which the left out requests belong to
+func (c *harConverter) thinkTimes(entries []*harEntry, kept []*harEntry) {
+	var last time.Time
+	k := 0
+	for _, e := range entries {
+		if k < len(kept) && e == kept[k] {
+			if k > 0 {
+				if gap := e.Started.Sub(last); gap >= c.opts.minThink {
+					c.steps[k-1].Think = gap.Round(HAR_THINK).String()
+				}
+			}
+			k++
+		}
+		if e.end().After(last) {
+			last = e.end()
+		}
+	}
+}
+
+// commonHeaders moves the headers every step sends with the same value to the scenario
+func (c *harConverter) commonHeaders() map[string]string {
+	if len(c.steps) < 2 {
+		return nil
+	}
+	common := map[string]string{}
+	for k, v := range c.steps[0].Headers {
+		common[k] = v
+	}
+	for _, step := range c.steps[1:] {
+		for k, v := range common {
+			if step.Headers[k] != v {
+				delete(common, k)
+			}
+		}
+	}
+	if len(common) == 0 {
+		return nil
+	}
+	for _, step := range c.steps {
+		for k := range common {
+			delete(step.Headers, k)
+		}
+		if len(step.Headers) == 0 {
+			step.Headers = nil
+		}
+	}
+	return common
+}
+
+func (v *harValue) describe() string {
+	switch {
+	case v.extract.JSON != "":
+		return "json " + v.extract.JSON
+	case v.extract.Header != "":
+		return "header " + v.extract.Header
+	case v.extract.Cookie != "":
+		return "cookie " + v.extract.Cookie
+	}
+	return "regex " + v.extract.Regex
+}
+
+// harOrigin returns the scheme and the host of a url
+func harOrigin(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// harRequestText returns everything the request sends for finding out which values were known before a response
+func harRequestText(e *harEntry) string {
+	var b strings.Builder
+	b.WriteString(e.Request.URL)
+	for _, h := range e.Request.Headers {
+		b.WriteString("\n" + h.Value)
+	}
+	if e.Request.PostData != nil {
+		b.WriteString("\n" + e.Request.PostData.Text)
+		for _, p := range e.Request.PostData.Params {
+			b.WriteString("\n" + p.Value)
+		}
+	}
+	return b.String() + "\n"
+}
+
+// harReplaceToken replaces the occurrences of value which are not part of a longer word
+func harReplaceToken(s string, value string, ref string) (string, bool) {
+	if value == "" || !strings.Contains(s, value) {
+		return s, false
+	}
+	var b strings.Builder
+	found := false
+	for {
+		i := strings.Index(s, value)
+		if i < 0 {
+			break
+		}
+		end := i + len(value)
+		if (i == 0 || !harWordByte(s[i-1])) && (end == len(s) || !harWordByte(s[end])) {
+			b.WriteString(s[:i] + ref)
+			found = true
+		} else {
+			b.WriteString(s[:end])
+		}
+		s = s[end:]
+	}
+	b.WriteString(s)
+	return b.String(), found
+}
+
+func harWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// harJSONLeaves calls fn with the path, the key and the value of the strings and numbers of a JSON document
+func harJSONLeaves(v interface{}, path string, name string, fn func(path, name, value string)) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for _, k := range harSortedKeys(x) {
+			if strings.ContainsAny(k, ".[]") {
+				continue
+			}
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			harJSONLeaves(x[k], p, k, fn)
+		}
+	case []interface{}:
+		for i, e := range x {
+			harJSONLeaves(e, fmt.Sprintf("%s[%d]", path, i), name, fn)
+		}
+	case string:
+		fn(path, name, x)
+	case float64:
+		fn(path, name, strconv.FormatFloat(x, 'f', -1, 64))
+	}
+}
+
+// harMapStrings returns a JSON body with fn applied to its strings, the numbers are kept as recorded
+func harMapStrings(v interface{}, fn func(string) string) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = harMapStrings(e, fn)
+		}
+	case []interface{}:
+		for i, e := range x {
+			x[i] = harMapStrings(e, fn)
+		}
+	case string:
+		return fn(x)
+	}
+	return v
+}
+
+// harHtmlValue calls fn with the name, the value and an extraction regex of a hidden input or a meta tag,
+// e.g. <input type="hidden" name="csrf" value="..."> or <meta name="csrf-token" content="...">
+func harHtmlValue(tag string, fn func(name, value, expr string)) {
+	attrs := map[string]string{}
+	var order []string
+	for _, m := range harHtmlAttr.FindAllStringSubmatch(tag, -1) {
+		key := strings.ToLower(m[1])
+		attrs[key] = m[2]
+		order = append(order, key)
+	}
+	name := attrs["name"]
+	valueAttr := "value"
+	if strings.HasPrefix(strings.ToLower(tag), "<meta") {
+		valueAttr = "content"
+	}
+	value, ok := attrs[valueAttr]
+	if name == "" || !ok {
+		return
+	}
+	nameExpr := `name="` + regexp.QuoteMeta(name) + `"`
+	valueExpr := valueAttr + `="([^"]*)"`
+	for _, key := range order {
+		if key == "name" {
+			fn(name, value, nameExpr+`[^>]*`+valueExpr)
+			return
+		}
+		if key == valueAttr {
+			fn(name, value, valueExpr+`[^>]*`+nameExpr)
+			return
+		}
+	}
+}
+
+func harSortedKeys(m interface{}) []string {
+	var keys []string
+	switch x := m.(type) {
+	case map[string]string:
+		for k := range x {
+			keys = append(keys, k)
+		}
+	case map[string][]string:
+		for k := range x {
+			keys = append(keys, k)
+		}
+	case map[string]interface{}:
+		for k := range x {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func harJoinInts(values []int) string {
+	var parts []string
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func harShorten(s string) string {
+	if len(s) > 40 {
+		return s[:37] + "..."
+	}
+	return s
+}
+
+var harGoSource = template.Must(template.New("go").Parse(`// Code generated by lotgo har2scenario, edit the scenario as needed.
+
+package {{.Package}}
+
+import (
+	"github.com/huljas/lotgo"
+	"github.com/huljas/lotgo/scenario"
+)
+
+const {{.Ident}} = {{.Scenario}}
+
+func init() {
+	s, err := scenario.ParseScenario([]byte({{.Ident}}), true)
+	if err != nil {
+		lotgo.LOG().Fatalf("Invalid scenario %s, reason %v", {{printf "%q" .Name}}, err)
+	}
+	lotgo.AddTest({{printf "%q" .Name}}, &scenario.ScenarioTest{Scenario: s})
+}
+`))
+
+// formatScenario writes the scenario as YAML, JSON or Go source registering a ScenarioTest
+func formatScenario(s *Scenario, o *harOptions) ([]byte, error) {
+	if o.format == "json" {
+		b, err := json.MarshalIndent(s, "", "  ")
+		return append(b, '\n'), err
+	}
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	if o.format == "yaml" {
+		return buf.Bytes(), nil
+	}
+	literal := "`" + buf.String() + "`"
+	if strings.Contains(buf.String(), "`") {
+		literal = strconv.Quote(buf.String())
+	}
+	ident := "har"
+	for _, word := range harIdentWord.Split(o.name, -1) {
+		if word != "" {
+			ident += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	src := &bytes.Buffer{}
+	err := harGoSource.Execute(src, map[string]string{"Package": o.pkg, "Ident": ident + "Scenario",
+		"Scenario": literal, "Name": o.name})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(src.Bytes())
+}
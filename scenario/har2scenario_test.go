@@ -0,0 +1,155 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// shopSession is a recorded session of the shop server with csrf a login page and its assets
+func shopSession(base string) []*harEntry {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	entry := func(at int, method, url string, status int) *harEntry {
+		e := &harEntry{Started: start.Add(time.Duration(at) * time.Millisecond), Time: 50}
+		e.Request = harRequest{Method: method, URL: url, Headers: []harPair{{":authority", "shop"}, {"user-agent", "lotgo"},
+			{"Accept-Encoding", "gzip"}, {"Cookie", "session=old"}}}
+		e.Response.Status = status
+		return e
+	}
+	page := entry(0, "GET", base+"/login", 200)
+	page.Response.Content.MimeType = "text/html"
+	page.Response.Content.Text = `<form><input type="hidden" name="csrf" value="c5a1e6d0"><input name="user"></form>`
+	login := entry(2300, "POST", base+"/login", 200)
+	login.Request.PostData = &harPostData{MimeType: "application/x-www-form-urlencoded", Text: "user=bob&csrf=c5a1e6d0"}
+	login.Response.Cookies = []harPair{{"session", "s-bob"}}
+	login.Response.Content.MimeType = "application/json"
+	login.Response.Content.Text = `{"data": {"token": "t-bob", "user": "bob"}}`
+	order := entry(2500, "POST", base+"/orders", 201)
+	order.Request.Headers = append(order.Request.Headers, harPair{"Authorization", "Bearer t-bob"}, harPair{"Content-Type", "application/json"})
+	order.Request.PostData = &harPostData{MimeType: "application/json", Text: `{"user": "bob", "items": [1, 2]}`}
+	order.Response.Headers = []harPair{{"Location", "/orders/1734"}}
+	show := entry(2600, "GET", base+"/orders/1734?session=s-bob", 200)
+	show.Request.Headers = append(show.Request.Headers, harPair{"X-Request-Nonce", "9f86d081884c7d659a2feaa0c55ad015"})
+	return []*harEntry{show, page, entry(100, "GET", base+"/static/app.js?v=2", 200), entry(120, "GET", base+"/static/logo.PNG", 200),
+		entry(200, "GET", "data:image/png;base64,AAAA", 200), login, order}
+}
+
+func convertSession(t *testing.T, base string) (*Scenario, string) {
+	report := &bytes.Buffer{}
+	c, err := newHarConverter(&harOptions{name: "shop", exclude: HAR_EXCLUDE, minThink: 500 * time.Millisecond}, report)
+	require.NoError(t, err)
+	s, err := c.convert(shopSession(base))
+	require.NoError(t, err)
+	return s, report.String()
+}
+
+func TestHarConverter(t *testing.T) {
+	s, report := convertSession(t, "https://shop.test")
+	assert.Equal(t, "https://shop.test", s.BaseURL)
+	assert.Equal(t, map[string]string{"User-Agent": "lotgo"}, s.Headers)
+	require.Equal(t, 4, len(s.Steps))
+
+	page, login, order, show := s.Steps[0], s.Steps[1], s.Steps[2], s.Steps[3]
+	assert.Equal(t, "1 GET /login", page.Name)
+	assert.Equal(t, "/login", page.URL)
+	assert.Equal(t, "2.1s", page.Think)
+	assert.Equal(t, map[string]*ScenarioExtract{"csrf": {Regex: `name="csrf"[^>]*value="([^"]*)"`}}, page.Extract)
+	assert.Equal(t, []*ScenarioCheck{{Status: 200}}, page.Checks)
+	assert.Nil(t, page.Headers)
+
+	assert.Equal(t, "POST", login.Method)
+	assert.Equal(t, map[string]string{"user": "bob", "csrf": "{{csrf}}"}, login.Form)
+	assert.Equal(t, map[string]*ScenarioExtract{"token": {JSON: "data.token"}, "session": {Cookie: "session"}}, login.Extract)
+	assert.Equal(t, "", login.Think)
+
+	assert.Equal(t, map[string]string{"Authorization": "Bearer {{token}}"}, order.Headers)
+	assert.Equal(t, map[string]interface{}{"user": "bob", "items": []interface{}{1.0, 2.0}}, order.JSON)
+	assert.Equal(t, map[string]*ScenarioExtract{"location": {Header: "Location"}}, order.Extract)
+	assert.Equal(t, []*ScenarioCheck{{Status: 201}}, order.Checks)
+
+	assert.Equal(t, "{{location}}?session={{session}}", show.URL)
+	assert.Equal(t, "9f86d081884c7d659a2feaa0c55ad015", show.Headers["X-Request-Nonce"])
+
+	assert.Contains(t, report, "extract csrf from step 1 regex name=\"csrf\"[^>]*value=\"([^\"]*)\", used in steps 2\n")
+	assert.Contains(t, report, "extract session from step 2 cookie session, used in steps 4\n")
+	assert.Contains(t, report, "candidate step 4 header X-Request-Nonce: dynamic value 9f86d081884c7d659a2feaa0c55ad015 not found in earlier responses\n")
+	assert.Contains(t, report, "4 steps from 7 entries, 3 left out\n")
+}
+
+func TestHarConverter_Run(t *testing.T) {
+	srv, orders := startShopServer(true, "/orders/%d")
+	defer srv.Close()
+	s, _ := convertSession(t, srv.URL)
+	for _, step := range s.Steps {
+		step.Think, step.thinkMin, step.thinkMax = "", 0, 0
+	}
+
+	rec := runScenario(s, 2, 2, nil)
+	assert.Equal(t, 4, rec.count)
+	assert.Empty(t, rec.errors)
+	assert.Equal(t, int32(4), atomic.LoadInt32(orders))
+}
+
+func TestHar2scenario(t *testing.T) {
+	dir := t.TempDir()
+	har := harFile{}
+	har.Log.Entries = shopSession("https://shop.test")
+	data, err := json.Marshal(har)
+	require.NoError(t, err)
+	file := filepath.Join(dir, "shop-session.har")
+	require.NoError(t, ioutil.WriteFile(file, data, 0644))
+
+	out, report := &bytes.Buffer{}, &bytes.Buffer{}
+	require.NoError(t, har2scenario([]string{"-min-think", "5s", file}, out, report))
+	s, err := ParseScenario(out.Bytes(), true)
+	require.NoError(t, err)
+	assert.Equal(t, "shop-session", s.Name)
+	assert.Equal(t, 4, len(s.Steps))
+	assert.Equal(t, "", s.Steps[0].Think)
+
+	jsonFile := filepath.Join(dir, "shop.json")
+	require.NoError(t, har2scenario([]string{"-o", jsonFile, "-exclude", "", file}, out, report))
+	s, err = LoadScenario(jsonFile)
+	require.NoError(t, err)
+	assert.Equal(t, 6, len(s.Steps))
+
+	out.Reset()
+	require.NoError(t, har2scenario([]string{"-format", "go", "-name", "shop 2", file}, out, report))
+	src := out.String()
+	assert.True(t, strings.HasPrefix(src, "// Code generated by lotgo har2scenario"), src)
+	assert.Contains(t, src, "const harShop2Scenario = `name: shop 2\n")
+	assert.Contains(t, src, `lotgo.AddTest("shop 2", &scenario.ScenarioTest{Scenario: s})`)
+
+	assert.Error(t, har2scenario([]string{"-format", "xml", file}, out, report))
+	assert.Error(t, har2scenario([]string{"-include", "other.test", file}, out, report))
+	assert.Error(t, har2scenario([]string{filepath.Join(dir, "missing.har")}, out, report))
+}
+
+func TestHarConverter_Collect(t *testing.T) {
+	c, err := newHarConverter(&harOptions{name: "shop"}, &bytes.Buffer{})
+	require.NoError(t, err)
+	e := &harEntry{}
+	e.Response.Content.MimeType = "application/json"
+	e.Response.Content.Text = `{"id": 7, "orderId": 1734, "user_id": "u1", "count": 12345678, "plan": "basic", "status": "confirmed",
+		"valid": "yes", "sid": "a1"}`
+	c.collect(1, &ScenarioStep{}, e)
+	var names []string
+	for _, v := range c.values {
+		names = append(names, v.name)
+	}
+	assert.ElementsMatch(t, []string{"id", "orderId", "user_id", "status", "sid"}, names)
+
+	for _, name := range []string{"id", "ID", "uuid", "orderId", "orderID", "order_id", "user-id", "Location", "csrf", "session"} {
+		assert.True(t, harIdLike(name), name)
+	}
+	for _, name := range []string{"paid", "valid", "identity", "count", "user"} {
+		assert.False(t, harIdLike(name), name)
+	}
+}
@@ -0,0 +1,506 @@
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/huljas/lotgo"
+	client "github.com/huljas/lotgo/http"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SCENARIO_TEST = "http/scenario"
+
+// Register adds the http/scenario test and the har2scenario command to lotgo, call before lotgo.Run
+func Register() {
+	lotgo.AddTest(SCENARIO_TEST, &ScenarioTest{})
+	lotgo.AddCommand("har2scenario", func(args []string) int {
+		if err := har2scenario(args, os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	})
+}
+
+// Scenario is a sequence of HTTP requests run by the http/scenario test, loaded from a JSON or YAML file:
+//
+//	lotgo -test http/scenario -param file=shop.yaml -param user=alice
+//
+// The variables of the scenario can be overridden with the parameters.
+type Scenario struct {
+	Name      string            `json:"name,omitempty" yaml:"name,omitempty"`
+	BaseURL   string            `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	Timeout   string            `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Cookies   *bool             `json:"cookies,omitempty" yaml:"cookies,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Variables map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	Steps     []*ScenarioStep   `json:"steps" yaml:"steps"`
+	timeout   time.Duration
+}
+
+// ScenarioStep is one request of a scenario. The url, headers, query, form, json and body can refer to
+// variables as {{name}}, the built in variables are client (the index of the client from 0), iteration, timestamp and random.
+type ScenarioStep struct {
+	Name        string                      `json:"name,omitempty" yaml:"name,omitempty"`
+	Method      string                      `json:"method,omitempty" yaml:"method,omitempty"`
+	URL         string                      `json:"url" yaml:"url"`
+	Headers     map[string]string           `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Query       map[string]string           `json:"query,omitempty" yaml:"query,omitempty"`
+	Form        map[string]string           `json:"form,omitempty" yaml:"form,omitempty"`
+	JSON        interface{}                 `json:"json,omitempty" yaml:"json,omitempty"`
+	Body        string                      `json:"body,omitempty" yaml:"body,omitempty"`
+	ContentType string                      `json:"content_type,omitempty" yaml:"content_type,omitempty"`
+	Extract     map[string]*ScenarioExtract `json:"extract,omitempty" yaml:"extract,omitempty"`
+	Checks      []*ScenarioCheck            `json:"checks,omitempty" yaml:"checks,omitempty"`
+	Think       string                      `json:"think,omitempty" yaml:"think,omitempty"`
+	thinkMin    time.Duration
+	thinkMax    time.Duration
+}
+
+// ScenarioExtract sets a variable from a JSON path, the first group of a regex, a header or a cookie of the response
+type ScenarioExtract struct {
+	JSON   string `json:"json,omitempty" yaml:"json,omitempty"`
+	Regex  string `json:"regex,omitempty" yaml:"regex,omitempty"`
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	Cookie string `json:"cookie,omitempty" yaml:"cookie,omitempty"`
+}
+
+// ScenarioCheck is an assertion on the response, the pass rates are counted by the step and check names.
+// Equals is the expected value of a JSON path or a header, without it the path or the header must be present.
+// A failed soft check does not fail the step.
+type ScenarioCheck struct {
+	Name         string `json:"name,omitempty" yaml:"name,omitempty"`
+	Status       int    `json:"status,omitempty" yaml:"status,omitempty"`
+	BodyContains string `json:"body_contains,omitempty" yaml:"body_contains,omitempty"`
+	JSON         string `json:"json,omitempty" yaml:"json,omitempty"`
+	Header       string `json:"header,omitempty" yaml:"header,omitempty"`
+	Equals       string `json:"equals,omitempty" yaml:"equals,omitempty"`
+	MaxLatency   string `json:"max_latency,omitempty" yaml:"max_latency,omitempty"`
+	Soft         bool   `json:"soft,omitempty" yaml:"soft,omitempty"`
+}
+
+var scenarioVariable = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// ParseScenario parses a scenario in JSON, or in YAML if yml is true, and checks its steps
+func ParseScenario(data []byte, yml bool) (*Scenario, error) {
+	s := &Scenario{}
+	var err error
+	if yml {
+		err = yaml.Unmarshal(data, s)
+	} else {
+		err = json.Unmarshal(data, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, s.validate()
+}
+
+// LoadScenario reads a scenario file, files ending with .yaml or .yml are YAML and the rest JSON
+func LoadScenario(file string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ParseScenario(data, strings.HasSuffix(file, ".yaml") || strings.HasSuffix(file, ".yml"))
+	if err != nil {
+		return nil, fmt.Errorf("scenario %s: %v", file, err)
+	}
+	return s, nil
+}
+
+func (s *Scenario) validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("no steps")
+	}
+	s.timeout = 5 * time.Second
+	if s.Timeout != "" {
+		d, err := time.ParseDuration(s.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %v", err)
+		}
+		s.timeout = d
+	}
+	for i, step := range s.Steps {
+		if step.URL == "" {
+			return fmt.Errorf("step %d has no url", i+1)
+		}
+		if step.Method == "" {
+			step.Method = client.METHOD_GET
+			if step.JSON != nil || step.Form != nil || step.Body != "" {
+				step.Method = client.METHOD_POST
+			}
+		}
+		step.Method = strings.ToUpper(step.Method)
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("%d %s", i+1, step.Method)
+		}
+		if err := step.parseThink(); err != nil {
+			return fmt.Errorf("step %s: %v", step.Name, err)
+		}
+		for name, e := range step.Extract {
+			if countSet(e.JSON, e.Regex, e.Header, e.Cookie) != 1 {
+				return fmt.Errorf("step %s: extract %s needs one of json, regex, header or cookie", step.Name, name)
+			}
+			if e.Regex != "" {
+				if _, err := regexp.Compile(e.Regex); err != nil {
+					return fmt.Errorf("step %s: extract %s: %v", step.Name, name, err)
+				}
+			}
+		}
+		for _, c := range step.Checks {
+			status := ""
+			if c.Status != 0 {
+				status = strconv.Itoa(c.Status)
+			}
+			if countSet(status, c.BodyContains, c.JSON, c.Header, c.MaxLatency) != 1 {
+				return fmt.Errorf("step %s: a check needs one of status, body_contains, json, header or max_latency", step.Name)
+			}
+			if c.MaxLatency != "" {
+				if _, err := time.ParseDuration(c.MaxLatency); err != nil {
+					return fmt.Errorf("step %s: invalid max_latency: %v", step.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseThink parses the think time after the step as a duration or a random range, e.g. 1s or 1s-3s
+func (step *ScenarioStep) parseThink() error {
+	if step.Think == "" {
+		return nil
+	}
+	parts := strings.SplitN(step.Think, "-", 2)
+	min, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return fmt.Errorf("invalid think time: %v", err)
+	}
+	max := min
+	if len(parts) == 2 {
+		if max, err = time.ParseDuration(strings.TrimSpace(parts[1])); err != nil || max < min {
+			return fmt.Errorf("invalid think time '%s', expected duration or min-max", step.Think)
+		}
+	}
+	step.thinkMin, step.thinkMax = min, max
+	return nil
+}
+
+func (step *ScenarioStep) think() time.Duration {
+	if step.thinkMax > step.thinkMin {
+		return step.thinkMin + time.Duration(rand.Int63n(int64(step.thinkMax-step.thinkMin)))
+	}
+	return step.thinkMin
+}
+
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// scenarios caches the loaded scenario files, every client of the test loads the same file
+var scenarios = struct {
+	sync.Mutex
+	files map[string]*Scenario
+}{files: map[string]*Scenario{}}
+
+func cachedScenario(file string) (*Scenario, error) {
+	scenarios.Lock()
+	defer scenarios.Unlock()
+	if s, ok := scenarios.files[file]; ok {
+		return s, nil
+	}
+	s, err := LoadScenario(file)
+	if err != nil {
+		return nil, err
+	}
+	scenarios.files[file] = s
+	return s, nil
+}
+
+// ScenarioTest runs the steps of a scenario file given with -param file=... as named steps of a test.
+// Every client has a session of its own with its cookies and the variables extracted from the responses.
+type ScenarioTest struct {
+	Scenario  *Scenario
+	cli       client.SessionClient
+	vars      map[string]string
+	client    int
+	iteration int
+}
+
+var _ lotgo.LoadTest = &ScenarioTest{}
+
+func (t *ScenarioTest) SetUp(lt *lotgo.Runner) {
+	if t.Scenario == nil {
+		file := lt.Param("file")
+		if file == "" {
+			lotgo.LOG().Fatalf("%s needs the scenario file as -param file=scenario.yaml", SCENARIO_TEST)
+		}
+		s, err := cachedScenario(file)
+		if err != nil {
+			lotgo.LOG().Fatalf("Failed to load scenario, reason %v", err)
+		}
+		t.Scenario = s
+	}
+	if t.Scenario.Cookies == nil || *t.Scenario.Cookies {
+		t.cli = client.NewSessionClient(t.Scenario.timeout)
+	} else {
+		t.cli = client.NewHttpClient(t.Scenario.timeout).(client.SessionClient)
+	}
+	t.client = lt.Client()
+	t.vars = map[string]string{}
+	for k, v := range t.Scenario.Variables {
+		t.vars[k] = v
+	}
+	for k, v := range lt.Params() {
+		if k != "file" {
+			t.vars[k] = v
+		}
+	}
+}
+
+func (t *ScenarioTest) TearDown(lt *lotgo.Runner) {
+}
+
+func (t *ScenarioTest) Test(lt *lotgo.Runner) error {
+	t.iteration++
+	for _, step := range t.Scenario.Steps {
+		err := lt.Step(step.Name, func() error {
+			return t.runStep(lt, step)
+		})
+		if err != nil {
+			return err
+		}
+		if d := step.think(); d > 0 {
+			time.Sleep(d)
+		}
+	}
+	return nil
+}
+
+// runStep sends the request of the step, evaluates its checks and extracts the variables
+func (t *ScenarioTest) runStep(lt *lotgo.Runner, step *ScenarioStep) error {
+	url, err := t.expand(step.URL)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(url, "://") {
+		url = strings.TrimSuffix(t.Scenario.BaseURL, "/") + "/" + strings.TrimPrefix(url, "/")
+	}
+	req := t.cli.Request(step.Method, url)
+	for _, headers := range []map[string]string{t.Scenario.Headers, step.Headers} {
+		if err := t.expandEach(headers, func(k, v string) { req.Header(k, v) }); err != nil {
+			return err
+		}
+	}
+	if err := t.expandEach(step.Query, func(k, v string) { req.Query(k, v) }); err != nil {
+		return err
+	}
+	if err := t.expandEach(step.Form, func(k, v string) { req.Form(k, v) }); err != nil {
+		return err
+	}
+	if step.JSON != nil {
+		v, err := t.expandValue(step.JSON)
+		if err != nil {
+			return err
+		}
+		req.JSON(v)
+	} else if step.Body != "" {
+		body, err := t.expand(step.Body)
+		if err != nil {
+			return err
+		}
+		req.Body(step.ContentType, []byte(body))
+	}
+
+	resp, err := req.Do()
+	if resp == nil {
+		return err
+	}
+	checks, soft, statusChecked, cerr := t.checks(step)
+	if cerr != nil {
+		return cerr
+	}
+	if err != nil && !statusChecked {
+		return err
+	}
+	resp.Check(lt, soft...)
+	if err := resp.Check(lt, checks...); err != nil {
+		return err
+	}
+	for name, e := range step.Extract {
+		v, err := t.extract(resp, e)
+		if err != nil {
+			return fmt.Errorf("extract %s: %v", name, err)
+		}
+		t.vars[name] = v
+	}
+	return nil
+}
+
+// checks returns the checks and the soft checks of the step named after the step and the unexpanded config,
+// tells also if the status is checked
+func (t *ScenarioTest) checks(step *ScenarioStep) ([]client.Check, []client.Check, bool, error) {
+	var checks, soft []client.Check
+	statusChecked := false
+	for _, sc := range step.Checks {
+		var c client.Check
+		switch {
+		case sc.Status != 0:
+			c = client.ExpectStatus(sc.Status)
+			statusChecked = statusChecked || !sc.Soft
+		case sc.BodyContains != "":
+			v, err := t.expand(sc.BodyContains)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			c = client.ExpectBodyContains(v).Named("body contains " + sc.BodyContains)
+		case sc.JSON != "" && sc.Equals == "":
+			path := sc.JSON
+			c = client.Check{Name: "json " + path, Fn: func(resp *client.Response) error {
+				_, err := resp.JSONPath(path)
+				return err
+			}}
+		case sc.JSON != "":
+			v, err := t.expand(sc.Equals)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			c = client.ExpectJSON(sc.JSON, v)
+		case sc.Header != "" && sc.Equals == "":
+			key := sc.Header
+			c = client.Check{Name: "header " + key, Fn: func(resp *client.Response) error {
+				_, err := resp.HeaderValue(key)
+				return err
+			}}
+		case sc.Header != "":
+			v, err := t.expand(sc.Equals)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			c = client.ExpectHeader(sc.Header, v)
+		case sc.MaxLatency != "":
+			d, _ := time.ParseDuration(sc.MaxLatency)
+			c = client.ExpectLatency(d)
+		}
+		name := sc.Name
+		if name == "" {
+			name = c.Name
+		}
+		if sc.Soft {
+			soft = append(soft, c.Named(step.Name+": "+name))
+		} else {
+			checks = append(checks, c.Named(step.Name+": "+name))
+		}
+	}
+	return checks, soft, statusChecked, nil
+}
+
+func (t *ScenarioTest) extract(resp *client.Response, e *ScenarioExtract) (string, error) {
+	switch {
+	case e.JSON != "":
+		return resp.JSONString(e.JSON)
+	case e.Regex != "":
+		return resp.Regex(e.Regex)
+	case e.Header != "":
+		return resp.HeaderValue(e.Header)
+	}
+	jar := t.cli.CookieJar()
+	if jar == nil {
+		return "", errors.New("cookies are disabled")
+	}
+	if v, ok := jar.Get(e.Cookie); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("no cookie %s", e.Cookie)
+}
+
+// expand replaces the {{name}} references with the values of the variables
+func (t *ScenarioTest) expand(s string) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var err error
+	out := scenarioVariable.ReplaceAllStringFunc(s, func(ref string) string {
+		name := scenarioVariable.FindStringSubmatch(ref)[1]
+		if v, ok := t.variable(name); ok {
+			return v
+		}
+		if err == nil {
+			err = fmt.Errorf("undefined variable %s", name)
+		}
+		return ref
+	})
+	return out, err
+}
+
+func (t *ScenarioTest) variable(name string) (string, bool) {
+	if v, ok := t.vars[name]; ok {
+		return v, true
+	}
+	switch name {
+	case "client":
+		return strconv.Itoa(t.client), true
+	case "iteration":
+		return strconv.Itoa(t.iteration), true
+	case "timestamp":
+		return strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10), true
+	case "random":
+		return strconv.Itoa(rand.Intn(1000000)), true
+	}
+	return "", false
+}
+
+func (t *ScenarioTest) expandEach(m map[string]string, fn func(k, v string)) error {
+	for k, v := range m {
+		ev, err := t.expand(v)
+		if err != nil {
+			return err
+		}
+		fn(k, ev)
+	}
+	return nil
+}
+
+// expandValue expands the strings of a JSON body
+func (t *ScenarioTest) expandValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		return t.expand(x)
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range x {
+			ev, err := t.expandValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = ev
+		}
+		return m, nil
+	case []interface{}:
+		list := make([]interface{}, len(x))
+		for i, e := range x {
+			ev, err := t.expandValue(e)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = ev
+		}
+		return list, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,251 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/huljas/lotgo"
+	client "github.com/huljas/lotgo/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testScenario = `
+name: shop
+timeout: 2s
+headers:
+  User-Agent: lotgo
+variables:
+  user: bob
+steps:
+  - name: login
+    url: /login
+    form:
+      user: "{{user}}"
+    extract:
+      token:
+        json: data.token
+      session:
+        cookie: session
+    checks:
+      - status: 200
+  - name: order
+    url: /orders
+    headers:
+      Authorization: "Bearer {{token}}"
+    json:
+      user: "{{user}}"
+      items: [1, "{{iteration}}"]
+    extract:
+      order:
+        header: Location
+    checks:
+      - status: 201
+      - json: user
+        equals: "{{user}}"
+      - header: Location
+      - name: slow
+        max_latency: 1ns
+        soft: true
+  - url: "/orders/{{order}}?session={{session}}"
+    checks:
+      - body_contains: "order {{order}}"
+`
+
+// startShopServer returns a session cookie and a token on login and creates orders with the token. With csrf the
+// login needs the token issued by the login page. The Location of a created order is formatted with location, e.g. %d
+// for the order number or /orders/%d for its url.
+func startShopServer(csrf bool, location string) (*httptest.Server, *int32) {
+	var orders int32
+	var tokens sync.Map
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/login" && r.Method == "GET":
+			token := fmt.Sprintf("%x", rand.Int63())
+			tokens.Store(token, true)
+			fmt.Fprintf(w, `<form><input type="hidden" name="csrf" value="%s"><input name="user"></form>`, token)
+		case r.URL.Path == "/login" && r.Method == "POST":
+			r.ParseForm()
+			if _, ok := tokens.Load(r.Form.Get("csrf")); csrf && !ok {
+				w.WriteHeader(403)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s-" + r.Form.Get("user"), Path: "/"})
+			fmt.Fprintf(w, `{"data": {"token": "t-%s", "user": "%s"}}`, r.Form.Get("user"), r.Form.Get("user"))
+		case r.URL.Path == "/orders" && r.Method == "POST":
+			if r.Header.Get("Authorization") != "Bearer t-bob" || r.Header.Get("User-Agent") != "lotgo" {
+				w.WriteHeader(401)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Location", fmt.Sprintf(location, atomic.AddInt32(&orders, 1)))
+			w.WriteHeader(201)
+			w.Write(body)
+		case strings.HasPrefix(r.URL.Path, "/orders/"):
+			c, err := r.Cookie("session")
+			if err != nil || r.URL.Query().Get("session") != c.Value {
+				w.WriteHeader(403)
+				return
+			}
+			fmt.Fprintf(w, "order %s", strings.TrimPrefix(r.URL.Path, "/orders/"))
+		default:
+			w.WriteHeader(404)
+		}
+	})), &orders
+}
+
+// runRecorder records the errors of the iterations and the pass rates of the checks of a run
+type runRecorder struct {
+	sync.Mutex
+	count  int
+	errors []error
+	passed map[string]int
+	checks map[string]int
+}
+
+func (r *runRecorder) Started(runner *lotgo.Runner) {
+}
+
+func (r *runRecorder) Sample(s *lotgo.Sample) {
+	if s.Step != "" {
+		return
+	}
+	r.Lock()
+	r.count++
+	if s.Err != nil {
+		r.errors = append(r.errors, s.Err)
+	}
+	r.Unlock()
+}
+
+func (r *runRecorder) Metric(m *lotgo.MetricSample) {
+	if m.Kind != lotgo.METRIC_CHECK {
+		return
+	}
+	r.Lock()
+	r.checks[m.Name]++
+	r.passed[m.Name] += int(m.Value)
+	r.Unlock()
+}
+
+func (r *runRecorder) Finished() {
+}
+
+// passRates returns the percentages of passed checks by the check name
+func (r *runRecorder) passRates() map[string]float64 {
+	rates := map[string]float64{}
+	for name, n := range r.checks {
+		rates[name] = float64(r.passed[name]) * 100 / float64(n)
+	}
+	return rates
+}
+
+// runScenario runs the scenario with the clients and the runs per client
+func runScenario(s *Scenario, clients int, runs int, params lotgo.Params) *runRecorder {
+	runner := lotgo.New(clients, runs, 0, 0, time.Second, &ScenarioTest{Scenario: s}, ioutil.Discard, nil, 0, false)
+	runner.SetParams(params)
+	rec := &runRecorder{passed: map[string]int{}, checks: map[string]int{}}
+	runner.AddListener(rec)
+	runner.Run()
+	return rec
+}
+
+func TestParseScenario(t *testing.T) {
+	s, err := ParseScenario([]byte(testScenario), true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(s.Steps))
+	assert.Equal(t, "POST", s.Steps[0].Method)
+	assert.Equal(t, "3 GET", s.Steps[2].Name)
+	assert.Equal(t, 2*time.Second, s.timeout)
+
+	js, err := json.Marshal(s)
+	require.NoError(t, err)
+	s2, err := ParseScenario(js, false)
+	require.NoError(t, err)
+	assert.Equal(t, s.Steps[1].Checks, s2.Steps[1].Checks)
+
+	for _, bad := range []string{
+		`{"steps": []}`,
+		`{"steps": [{"name": "x"}]}`,
+		`{"steps": [{"url": "/", "think": "2s-1s"}]}`,
+		`{"steps": [{"url": "/", "checks": [{"status": 200, "json": "a"}]}]}`,
+		`{"steps": [{"url": "/", "extract": {"a": {}}}]}`,
+		`{"steps": [{"url": "/", "extract": {"a": {"regex": "("}}}]}`,
+	} {
+		_, err := ParseScenario([]byte(bad), false)
+		assert.Error(t, err, bad)
+	}
+
+	step := &ScenarioStep{Think: "10ms-20ms"}
+	require.NoError(t, step.parseThink())
+	for i := 0; i < 10; i++ {
+		d := step.think()
+		assert.True(t, d >= 10*time.Millisecond && d < 20*time.Millisecond)
+	}
+}
+
+func TestScenarioTest(t *testing.T) {
+	srv, orders := startShopServer(false, "%d")
+	defer srv.Close()
+	s, err := ParseScenario([]byte(testScenario), true)
+	require.NoError(t, err)
+	s.BaseURL = srv.URL
+
+	rec := runScenario(s, 2, 3, nil)
+	assert.Equal(t, 6, rec.count)
+	assert.Empty(t, rec.errors)
+	assert.Equal(t, int32(6), atomic.LoadInt32(orders))
+	assert.Equal(t, map[string]float64{"login: status 200": 100, "order: status 201": 100, "order: json user": 100,
+		"order: header Location": 100, "order: slow": 0, "3 GET: body contains order {{order}}": 100}, rec.passRates())
+}
+
+func TestScenarioTest_Failures(t *testing.T) {
+	srv, _ := startShopServer(false, "%d")
+	defer srv.Close()
+	s, err := ParseScenario([]byte(testScenario), true)
+	require.NoError(t, err)
+	s.BaseURL = srv.URL
+
+	rec := runScenario(s, 1, 1, lotgo.Params{"user": "alice"})
+	require.Equal(t, 1, len(rec.errors))
+	assert.Equal(t, "check order: status 201 failed: status 401", rec.errors[0].Error())
+	assert.Equal(t, "check order: status 201", lotgo.DefaultErrorClassifier.Classify(rec.errors[0]))
+	assert.Equal(t, 100.0, rec.passRates()["login: status 200"])
+
+	lt := lotgo.New(1, 1, 0, 0, time.Second, &ScenarioTest{}, ioutil.Discard, nil, 0, false)
+	lt.SetParams(lotgo.Params{"user": "alice", "file": "shop.yaml"})
+	test := &ScenarioTest{Scenario: s}
+	test.SetUp(lt)
+	assert.Equal(t, map[string]string{"user": "alice"}, test.vars)
+	test.client, test.iteration = 2, 1
+
+	_, err = test.expand("{{missing}}")
+	assert.EqualError(t, err, "undefined variable missing")
+	v, err := test.expand("{{ client }}-{{iteration}}")
+	require.NoError(t, err)
+	assert.Equal(t, "2-1", v)
+	checks, _, _, err := test.checks(&ScenarioStep{Name: "s", Checks: []*ScenarioCheck{{JSON: "data.id"}, {Header: "Location"}}})
+	require.NoError(t, err)
+	resp := &client.Response{Body: []byte(`{"data": {"id": 0}}`), Headers: http.Header{}}
+	assert.EqualError(t, resp.Check(nil, checks...), "check s: header Location failed: header Location: not found")
+	resp.Body = []byte(`{"data": {}}`)
+	assert.EqualError(t, resp.Check(nil, checks...), "check s: json data.id failed: json path data.id: no key id")
+
+	file := filepath.Join(t.TempDir(), "shop.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(testScenario), 0644))
+	loaded, err := cachedScenario(file)
+	require.NoError(t, err)
+	again, _ := cachedScenario(file)
+	assert.True(t, loaded == again)
+	_, err = LoadScenario(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
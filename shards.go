@@ -0,0 +1,146 @@
+package lotgo
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// statsShard holds the stats recorded by one client. Its lock is shared only by the client and the collector,
+// so recording a sample does not wait for the other clients.
+type statsShard struct {
+	sync.Mutex
+	total *stats
+	keys  map[stepKey]*stats
+	_     [64]byte
+}
+
+func newStatsShard(precision int) *statsShard {
+	return &statsShard{total: newStats(precision), keys: map[stepKey]*stats{}}
+}
+
+// statsFor returns the stats for a test or a step
+func (sh *statsShard) statsFor(key stepKey, precision int) *stats {
+	st, ok := sh.keys[key]
+	if !ok {
+		st = newStats(precision)
+		sh.keys[key] = st
+	}
+	return st
+}
+
+// shardedStats records the samples of each client into a shard of its own, the shards are merged when collected.
+// The steps and the checks go to the shard of the calling client, keyed also by the test and the step of the client. The shared custom metric handles,
+// the health metrics and the samples of unknown clients go to the shared last shard.
+type shardedStats struct {
+	precision int
+	keyed     bool
+	shards    atomic.Value
+}
+
+// newShardedStats creates the stats, keyed collects also the stats by test and step
+func newShardedStats(precision int, keyed bool) *shardedStats {
+	s := &shardedStats{precision: precision, keyed: keyed}
+	s.shards.Store([]*statsShard{newStatsShard(precision)})
+	return s
+}
+
+// resize adds a shard for every client, call before the clients start
+func (s *shardedStats) resize(clients int) {
+	old := s.shards.Load().([]*statsShard)
+	shards := make([]*statsShard, 0, clients+len(old))
+	for i := 0; i < clients; i++ {
+		shards = append(shards, newStatsShard(s.precision))
+	}
+	s.shards.Store(append(shards, old...))
+}
+
+func (s *shardedStats) shard(client int) *statsShard {
+	shards := s.shards.Load().([]*statsShard)
+	if client >= 0 && client < len(shards)-1 {
+		return shards[client]
+	}
+	return shards[len(shards)-1]
+}
+
+// sample records a test pass or a step into the shard of its client
+func (s *shardedStats) sample(smp *Sample) {
+	if smp.Err == nil {
+		s.success(&smp.Iteration)
+	} else {
+		s.error(smp.Err, &smp.Iteration)
+	}
+}
+
+func (s *shardedStats) success(it *Iteration) {
+	sh := s.shard(it.Client)
+	sh.Lock()
+	if it.Step == "" {
+		sh.total.success(it)
+	}
+	if s.keyed {
+		sh.statsFor(stepKey{test: it.Test, step: it.Step}, s.precision).success(it)
+	}
+	sh.Unlock()
+}
+
+func (s *shardedStats) error(err error, it *Iteration) {
+	sh := s.shard(it.Client)
+	sh.Lock()
+	if it.Step == "" {
+		sh.total.error(err, it)
+	}
+	if s.keyed {
+		sh.statsFor(stepKey{test: it.Test, step: it.Step}, s.precision).error(err, it)
+	}
+	sh.Unlock()
+}
+
+func (s *shardedStats) metric(m *MetricSample) {
+	sh := s.shard(m.Client)
+	sh.Lock()
+	sh.total.metric(m)
+	if s.keyed && m.Test != "" {
+		sh.statsFor(stepKey{test: m.Test}, s.precision).metric(m)
+		if m.Step != "" {
+			sh.statsFor(stepKey{test: m.Test, step: m.Step}, s.precision).metric(m)
+		}
+	}
+	sh.Unlock()
+}
+
+// collect merges the shards into the stats of all test iterations and the stats by test and step,
+// with reset the shards start over
+func (s *shardedStats) collect(reset bool) (*stats, map[stepKey]*stats) {
+	total := newStats(s.precision)
+	keys := map[stepKey]*stats{}
+	for _, sh := range s.shards.Load().([]*statsShard) {
+		sh.Lock()
+		shTotal, shKeys := sh.total, sh.keys
+		if reset {
+			sh.total = newStats(s.precision)
+			sh.keys = map[stepKey]*stats{}
+		} else {
+			total.merge(shTotal)
+			for key, st := range shKeys {
+				mergeStats(keys, key, st, s.precision)
+			}
+		}
+		sh.Unlock()
+		if reset {
+			total.merge(shTotal)
+			for key, st := range shKeys {
+				mergeStats(keys, key, st, s.precision)
+			}
+		}
+	}
+	return total, keys
+}
+
+func mergeStats(keys map[stepKey]*stats, key stepKey, st *stats, precision int) {
+	to, ok := keys[key]
+	if !ok {
+		to = newStats(precision)
+		keys[key] = to
+	}
+	to.merge(st)
+}
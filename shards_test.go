@@ -0,0 +1,95 @@
+package lotgo
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedStats_Collect(t *testing.T) {
+	s := newShardedStats(3, true)
+	s.resize(4)
+	wg := &sync.WaitGroup{}
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func(client int) {
+			defer wg.Done()
+			for i := 1; i <= 100; i++ {
+				s.success(&Iteration{Client: client, Test: "shop", Duration: time.Duration(i) * time.Millisecond})
+			}
+			s.error(errors.New("x"), &Iteration{Client: client, Test: "shop", Class: "x"})
+			s.success(&Iteration{Client: client, Test: "shop", Step: "login", Duration: time.Millisecond})
+		}(c)
+	}
+	wg.Wait()
+	s.metric(&MetricSample{Name: "items", Kind: METRIC_COUNTER, Value: 2, Client: -1})
+	s.metric(&MetricSample{Name: "login ok", Kind: METRIC_CHECK, Value: 1, Client: 2})
+	for c := 0; c < 4; c++ {
+		assert.Equal(t, int64(1), s.shard(c).keys[stepKey{test: "shop", step: "login"}].hist.Count())
+	}
+	assert.Equal(t, 0, len(s.shard(-1).keys))
+	assert.Equal(t, 1, len(s.shard(2).total.metrics))
+	assert.Equal(t, 1, len(s.shard(-1).total.metrics))
+
+	total, keys := s.collect(false)
+	assert.Equal(t, int64(400), total.hist.Count())
+	assert.Equal(t, int64(4), total.errors.count())
+	assert.Equal(t, int64(100000), total.hist.Max())
+	assert.Equal(t, int64(1), total.metrics[metricKey{name: "items", kind: METRIC_COUNTER}].count)
+	assert.Equal(t, int64(400), keys[stepKey{test: "shop"}].hist.Count())
+	assert.Equal(t, int64(4), keys[stepKey{test: "shop", step: "login"}].hist.Count())
+
+	total, _ = s.collect(true)
+	assert.Equal(t, int64(400), total.hist.Count())
+	total, keys = s.collect(false)
+	assert.Equal(t, int64(0), total.hist.Count())
+	assert.Equal(t, 0, len(keys))
+}
+
+// lockedStats records all clients into one stats behind a mutex, the way the loggers did before sharding
+type lockedStats struct {
+	sync.Mutex
+	st *stats
+}
+
+func (s *lockedStats) success(it *Iteration) {
+	s.Lock()
+	s.st.success(it)
+	s.Unlock()
+}
+
+func benchmarkClients(b *testing.B, record func(it *Iteration)) {
+	var clients int32 = -1
+	b.RunParallel(func(pb *testing.PB) {
+		it := &Iteration{Client: int(atomic.AddInt32(&clients, 1)), Test: "bench", Duration: 3 * time.Millisecond}
+		for pb.Next() {
+			record(it)
+		}
+	})
+}
+
+func BenchmarkStats_Locked(b *testing.B) {
+	s := &lockedStats{st: newStats(DefaultPrecision)}
+	benchmarkClients(b, s.success)
+}
+
+func BenchmarkStats_Sharded(b *testing.B) {
+	s := newShardedStats(DefaultPrecision, false)
+	s.resize(1024)
+	benchmarkClients(b, s.success)
+}
+
+func BenchmarkListeners(b *testing.B) {
+	runner := &Runner{clients: 1024, period: time.Hour}
+	plogger := NewPeriodLogger(time.Hour, nil, &CsvFormat{}, DefaultPercentiles, DefaultPrecision)
+	slogger := NewSummaryLogger(0, nil, &CsvFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	l := Listeners{plogger, slogger}
+	l.Started(runner)
+	defer plogger.Finished()
+	benchmarkClients(b, func(it *Iteration) {
+		l.Sample(&Sample{Iteration: *it})
+	})
+}
@@ -0,0 +1,60 @@
+package lotgo
+
+import (
+	"strings"
+	"time"
+)
+
+var _ SampleListener = &statsdListener{}
+
+/* Listener which sends every sample as StatsD metrics with DogStatsD style tags */
+type statsdListener struct {
+	batcher *batcher
+	tags    Tags
+}
+
+// NewStatsdListener creates a listener sending lotgo.requests, lotgo.errors and lotgo.latency metrics
+func NewStatsdListener(b *batcher, tags Tags) *statsdListener {
+	return &statsdListener{batcher: b, tags: tags}
+}
+
+func (l *statsdListener) Started(runner *Runner) {
+	l.batcher.start()
+}
+
+func (l *statsdListener) Finished() {
+	l.batcher.Close()
+}
+
+func (l *statsdListener) Sample(s *Sample) {
+	it := &s.Iteration
+	tags := l.lineTags(it)
+	if s.Err == nil {
+		l.batcher.Add("lotgo.requests:1|c"+tags, "lotgo.latency:"+formatFloat(float64(it.Duration)/float64(time.Millisecond))+"|ms"+tags)
+		return
+	}
+	l.batcher.Add("lotgo.requests:1|c"+tags, "lotgo.errors:1|c"+tags+",class:"+escapeStatsd(it.Class),
+		"lotgo.latency:"+formatFloat(float64(it.Duration)/float64(time.Millisecond))+"|ms"+tags)
+}
+
+func (l *statsdListener) lineTags(it *Iteration) string {
+	all := Tags{}
+	for k, v := range l.tags {
+		all[k] = v
+	}
+	all["test"] = it.Test
+	if it.Step != "" {
+		all["step"] = it.Step
+	}
+	var parts []string
+	for _, k := range all.keys() {
+		parts = append(parts, escapeStatsd(k)+":"+escapeStatsd(all[k]))
+	}
+	return "|#" + strings.Join(parts, ",")
+}
+
+var statsdEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", ":", "_", "\n", " ")
+
+func escapeStatsd(s string) string {
+	return statsdEscaper.Replace(s)
+}
@@ -0,0 +1,78 @@
+package lotgo
+
+import (
+	"os"
+	"runtime"
+	"time"
+)
+
+// SummaryFormat is implemented by formats which write the complete summary of a run as one document
+type SummaryFormat interface {
+	FormatSummary(s *summary) string
+}
+
+// summary is the complete result of a run
+type summary struct {
+	Config      *runConfig
+	Environment *environment
+	Total       *result
+	Tests       []*result
+	Steps       []*result
+	Errors      []*errorStat
+	Warnings    []string
+	Thresholds  []*thresholdResult
+	Histogram   *Histogram
+}
+
+// runConfig is the configuration of a run
+type runConfig struct {
+	Test        string
+	Clients     int
+	Runs        int
+	Duration    time.Duration
+	Sleep       time.Duration
+	Rampup      time.Duration
+	Period      time.Duration
+	Percentiles Percentiles
+	Precision   int
+	Start       time.Time
+	End         time.Time
+}
+
+func newRunConfig(runner *Runner, ps Percentiles, precision int, end time.Time) *runConfig {
+	return &runConfig{
+		Test:        runner.name,
+		Clients:     runner.clients,
+		Runs:        runner.runs,
+		Duration:    runner.duration,
+		Sleep:       runner.sleep,
+		Rampup:      runner.rampup,
+		Period:      runner.period,
+		Percentiles: percentilesOrDefault(ps),
+		Precision:   precision,
+		Start:       runner.startTime,
+		End:         end,
+	}
+}
+
+// environment describes the machine running the load test
+type environment struct {
+	Hostname   string `json:"hostname"`
+	GoVersion  string `json:"go_version"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	NumCPU     int    `json:"num_cpu"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+}
+
+func newEnvironment() *environment {
+	hostname, _ := os.Hostname()
+	return &environment{
+		Hostname:   hostname,
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+	}
+}
@@ -0,0 +1,168 @@
+package lotgo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	TEXT_WIDTH          = 80
+	TEXT_HISTOGRAM_BINS = 12
+	TEXT_HISTOGRAM_BAR  = 40
+)
+
+// TextFormat formats the summary as a human readable report for terminals and logs.
+// The period results are formatted as markdown table.
+type TextFormat struct {
+	MdFormat
+}
+
+var _ Format = &TextFormat{}
+var _ SummaryFormat = &TextFormat{}
+
+func (f *TextFormat) FormatSummary(s *summary) string {
+	b := &strings.Builder{}
+	c := s.Config
+	total := s.Total
+	ps := percentilesOrDefault(f.Percentiles)
+
+	line := strings.Repeat("=", TEXT_WIDTH) + "\n"
+	b.WriteString("\n" + line)
+	fmt.Fprintf(b, " lotgo report: %s\n", c.Test)
+	b.WriteString(line)
+
+	textSection(b, "Configuration")
+	textRow(b, "clients", fmt.Sprintf("%d", c.Clients))
+	if c.Duration > 0 {
+		textRow(b, "duration", c.Duration.String())
+	} else {
+		textRow(b, "runs", fmt.Sprintf("%d per client", c.Runs))
+	}
+	textRow(b, "rampup", c.Rampup.String())
+	textRow(b, "sleep", c.Sleep.String())
+	textRow(b, "period", c.Period.String())
+	if e := s.Environment; e != nil {
+		textRow(b, "host", fmt.Sprintf("%s, %s %s/%s, %d CPUs, GOMAXPROCS %d", e.Hostname, e.GoVersion, e.OS, e.Arch, e.NumCPU, e.GOMAXPROCS))
+	}
+
+	textSection(b, "Duration")
+	textRow(b, "started", c.Start.Format("2006-01-02 15:04:05"))
+	textRow(b, "finished", c.End.Format("2006-01-02 15:04:05"))
+	textRow(b, "elapsed", c.End.Sub(c.Start).Round(time.Millisecond).String())
+	textRow(b, "measured", total.Time.Round(time.Millisecond).String())
+
+	textSection(b, "Totals")
+	textRow(b, "iterations", fmt.Sprintf("%d", total.Count+total.ErrCount))
+	textRow(b, "successful", fmt.Sprintf("%d", total.Count))
+	textRow(b, "errors", fmt.Sprintf("%d (%.2f%%)", total.ErrCount, errorPercent(total)))
+	textRow(b, "throughput", fmt.Sprintf("%.2f r/s", total.Rate))
+
+	textSection(b, "Latency (ms)")
+	textRow(b, "min", fmt.Sprintf("%10.1f", total.Min))
+	textRow(b, "mean", fmt.Sprintf("%10.1f", total.Mean))
+	for i, name := range ps.Names() {
+		if i < len(total.Percentiles) {
+			textRow(b, name, fmt.Sprintf("%10.1f", total.Percentiles[i]))
+		}
+	}
+	textRow(b, "max", fmt.Sprintf("%10.1f", total.Max))
+
+	if s.Histogram != nil && s.Histogram.Count() > 0 {
+		textSection(b, "Latency histogram (ms)")
+		writeTextHistogram(b, s.Histogram)
+	}
+
+	if len(s.Errors) > 0 {
+		textSection(b, "Errors")
+		for _, l := range f.FormatErrors(s.Errors) {
+			b.WriteString(strings.TrimPrefix(l, "\n"))
+		}
+	}
+
+	if len(s.Tests) > 0 {
+		textSection(b, "Tests (latency in ms)")
+		for _, l := range formatMdResults(&f.MdFormat, s.Tests) {
+			b.WriteString(l)
+		}
+	}
+	if len(s.Steps) > 0 {
+		textSection(b, "Steps (latency in ms)")
+		for _, l := range formatMdResults(&f.MdFormat, s.Steps) {
+			b.WriteString(l)
+		}
+	}
+
+	var checks, metrics []*metricResult
+	for _, m := range total.Metrics {
+		if m.Kind == METRIC_CHECK {
+			checks = append(checks, m)
+		} else {
+			metrics = append(metrics, m)
+		}
+	}
+	if len(checks) > 0 {
+		textSection(b, "Checks")
+		for _, m := range checks {
+			fmt.Fprintf(b, "  %-30s %7.2f%% passed  %d of %d\n", m.Name, m.Value, checkPasses(m), m.Count)
+		}
+	}
+	if len(metrics) > 0 {
+		textSection(b, "Custom metrics")
+		b.WriteString("  " + strings.TrimPrefix(formatMetricsLine(metrics, f.Percentiles), "metrics: "))
+	}
+
+	if len(s.Thresholds) > 0 {
+		textSection(b, fmt.Sprintf("Thresholds: %d of %d passed", len(s.Thresholds)-failures(s.Thresholds), len(s.Thresholds)))
+		for _, r := range s.Thresholds {
+			verdict := "PASS"
+			if !r.Passed {
+				verdict = "FAIL"
+			}
+			actual := "no value"
+			if r.Found {
+				actual = fmt.Sprintf("actual %g", r.Actual)
+			}
+			fmt.Fprintf(b, "  %s  %-30s %s\n", verdict, r.Threshold.Expr, actual)
+		}
+	}
+
+	if len(s.Warnings) > 0 {
+		textSection(b, "Warnings")
+		for _, w := range s.Warnings {
+			fmt.Fprintf(b, "  %s\n", w)
+		}
+	}
+	b.WriteString("\n" + line)
+	return b.String()
+}
+
+func textSection(b *strings.Builder, title string) {
+	fmt.Fprintf(b, "\n%s\n", title)
+}
+
+func textRow(b *strings.Builder, name string, value string) {
+	fmt.Fprintf(b, "  %-14s %s\n", name, value)
+}
+
+// writeTextHistogram draws the latency distribution as horizontal bars of logarithmic bins
+func writeTextHistogram(b *strings.Builder, h *Histogram) {
+	labels, counts := latencyBins(h, TEXT_HISTOGRAM_BINS)
+	max := 0.0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+	total := float64(h.Count())
+	for i, c := range counts {
+		bar := 0
+		if max > 0 {
+			bar = int(c / max * TEXT_HISTOGRAM_BAR)
+		}
+		if bar == 0 && c > 0 {
+			bar = 1
+		}
+		fmt.Fprintf(b, "  <= %8s | %-*s %8.0f %5.1f%%\n", labels[i], TEXT_HISTOGRAM_BAR, strings.Repeat("#", bar), c, c/total*100)
+	}
+}
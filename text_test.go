@@ -0,0 +1,49 @@
+package lotgo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTextFormat_FormatSummary(t *testing.T) {
+	s := thresholdSummary()
+	s.Config.Duration = 10 * time.Second
+	s.Total.Time = 8 * time.Second
+	s.Environment = &environment{Hostname: "box", GoVersion: "go1.x", OS: "linux", Arch: "amd64", NumCPU: 4, GOMAXPROCS: 4}
+	s.Histogram = NewHistogram(DefaultPrecision)
+	for i := 1; i <= 100; i++ {
+		s.Histogram.RecordDuration(time.Duration(i) * time.Millisecond)
+	}
+	s.Errors = []*errorStat{{Class: "connection refused", Count: 1, First: s.Config.Start, Last: s.Config.Start, Example: "dial tcp: connection refused"}}
+	ts := Thresholds{}
+	require.Nil(t, ts.Set("p99<50"))
+	require.Nil(t, ts.Set("p75<10"))
+	s.Thresholds = ts.evaluate(s, DefaultPercentiles)
+	s.Warnings = []string{"Load generator was likely saturated"}
+
+	f, err := NewFormat("text", DefaultPercentiles)
+	require.Nil(t, err)
+	out := f.(SummaryFormat).FormatSummary(s)
+
+	assert.Contains(t, out, " lotgo report: shop\n")
+	assert.Contains(t, out, "  clients        2\n")
+	assert.Contains(t, out, "  duration       10s\n")
+	assert.Contains(t, out, "  host           box, go1.x linux/amd64, 4 CPUs, GOMAXPROCS 4\n")
+	assert.Contains(t, out, "  elapsed        10s\n")
+	assert.Contains(t, out, "  measured       8s\n")
+	assert.Contains(t, out, "  iterations     100\n")
+	assert.Contains(t, out, "  errors         1 (1.00%)\n")
+	assert.Contains(t, out, "  throughput     9.90 r/s\n")
+	assert.Contains(t, out, "  p99                  45.0\n")
+	assert.Contains(t, out, "\nLatency histogram (ms)\n")
+	assert.Equal(t, TEXT_HISTOGRAM_BINS, strings.Count(out, "  <= "))
+	assert.Contains(t, out, "| connection refused   |        1 |")
+	assert.Contains(t, out, "\nTests (latency in ms)\n| name                 | time     |")
+	assert.Contains(t, out, "\n| shop/login           |")
+	assert.Contains(t, out, "\nCustom metrics\n  orders 5 (0.5/s)\n")
+	assert.Contains(t, out, "\nThresholds: 1 of 2 passed\n  PASS  p99<50                         actual 45\n  FAIL  p75<10                         no value\n")
+	assert.Contains(t, out, "\nWarnings\n  Load generator was likely saturated\n")
+}
@@ -0,0 +1,244 @@
+package lotgo
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Threshold is a pass or fail criterion for a run, e.g. p99<500, error_rate<1 or shop/login:mean<=200.
+//
+// The optional scope before the colon selects a test or a test/step, the whole run is used without it.
+// Test, step and custom metric names can contain colons, e.g. "shop/step: pay:p99<500" or "metric.login: ok.value>=99".
+// Metrics are count, rate, errors, error_rate (percent), min, mean, max, percentiles like p99 and
+// custom metrics as metric.<name>.<field> where field is value, count, rate, min, mean, max or a percentile like p99.9.
+// The kind of the custom metric can be given as metric.<name>[<kind>].<field>, e.g. metric.login[check].value,
+// and it is needed if metrics of different kinds have the name. The metrics of a step are the checks made in the step.
+type Threshold struct {
+	Expr   string
+	Test   string
+	Step   string
+	Metric string
+	Op     string
+	Value  float64
+}
+
+var thresholdOps = []string{"<=", ">=", "<", ">"}
+
+// customMetricExpr splits metric.<name>[<kind>].<field> of a threshold, the name can contain dots
+var customMetricExpr = regexp.MustCompile(`^metric\.(.+?)(?:\[(counter|gauge|trend|check)\])?\.(value|count|rate|min|mean|max|p[0-9]+(?:\.[0-9]+)?)$`)
+
+// ParseThreshold parses a threshold expression
+func ParseThreshold(expr string) (*Threshold, error) {
+	t := &Threshold{Expr: strings.TrimSpace(expr)}
+	s := t.Expr
+	if i := scopeEnd(s); i >= 0 {
+		scope := strings.SplitN(s[:i], "/", 2)
+		t.Test = scope[0]
+		if len(scope) == 2 {
+			t.Step = scope[1]
+		}
+		s = s[i+1:]
+	}
+	for _, op := range thresholdOps {
+		if i := strings.Index(s, op); i > 0 {
+			t.Metric = strings.TrimSpace(s[:i])
+			t.Op = op
+			v, err := strconv.ParseFloat(strings.TrimSpace(s[i+len(op):]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid threshold '%s', expected a number after %s", expr, op)
+			}
+			t.Value = v
+			if strings.HasPrefix(t.Metric, "metric.") && !customMetricExpr.MatchString(t.Metric) {
+				return nil, fmt.Errorf("invalid threshold '%s', expected metric.<name>.<field> with field value, count, rate, min, mean, max or a percentile", expr)
+			}
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid threshold '%s', expected metric<value, metric<=value, metric>value or metric>=value", expr)
+}
+
+// scopeEnd returns the index of the colon ending the scope of a threshold expression, or -1 without a scope.
+// The names can contain colons, the scope ends at the colon before a custom metric or else at the last colon before the operator.
+func scopeEnd(s string) int {
+	head := s
+	if i := strings.IndexAny(s, "<>"); i >= 0 {
+		head = s[:i]
+	}
+	if strings.HasPrefix(head, "metric.") {
+		return -1
+	}
+	if i := strings.Index(head, ":metric."); i >= 0 {
+		return i
+	}
+	return strings.LastIndex(head, ":")
+}
+
+func (t *Threshold) check(v float64) bool {
+	switch t.Op {
+	case "<":
+		return v < t.Value
+	case "<=":
+		return v <= t.Value
+	case ">":
+		return v > t.Value
+	case ">=":
+		return v >= t.Value
+	}
+	return false
+}
+
+// Thresholds is a list of thresholds given with repeated -threshold flags
+type Thresholds []*Threshold
+
+var _ flag.Value = &Thresholds{}
+
+func (ts *Thresholds) String() string {
+	var exprs []string
+	for _, t := range *ts {
+		exprs = append(exprs, t.Expr)
+	}
+	return strings.Join(exprs, " ")
+}
+
+func (ts *Thresholds) Set(s string) error {
+	t, err := ParseThreshold(s)
+	if err != nil {
+		return err
+	}
+	*ts = append(*ts, t)
+	return nil
+}
+
+// thresholdResult is the verdict of a threshold, Found is false if the run had no value for the metric
+type thresholdResult struct {
+	Threshold *Threshold
+	Actual    float64
+	Found     bool
+	Passed    bool
+}
+
+func (r *thresholdResult) String() string {
+	if !r.Found {
+		return fmt.Sprintf("%s: FAIL, no value", r.Threshold.Expr)
+	}
+	if r.Passed {
+		return fmt.Sprintf("%s: PASS, actual %g", r.Threshold.Expr, r.Actual)
+	}
+	return fmt.Sprintf("%s: FAIL, actual %g", r.Threshold.Expr, r.Actual)
+}
+
+// evaluate checks the thresholds against the summary of a run
+func (ts Thresholds) evaluate(s *summary, ps Percentiles) []*thresholdResult {
+	var list []*thresholdResult
+	for _, t := range ts {
+		r := &thresholdResult{Threshold: t}
+		if res := summaryResult(s, t.Test, t.Step); res != nil {
+			r.Actual, r.Found = resultValue(res, t.Metric, ps)
+		}
+		r.Passed = r.Found && t.check(r.Actual)
+		list = append(list, r)
+	}
+	return list
+}
+
+// failures returns the number of failed thresholds
+func failures(results []*thresholdResult) int {
+	n := 0
+	for _, r := range results {
+		if !r.Passed {
+			n++
+		}
+	}
+	return n
+}
+
+func summaryResult(s *summary, test string, step string) *result {
+	if test == "" {
+		return s.Total
+	}
+	list := s.Tests
+	if step != "" {
+		list = s.Steps
+	}
+	for _, res := range list {
+		if res.Test == test && res.Step == step {
+			return res
+		}
+	}
+	return nil
+}
+
+// resultValue returns the value of a metric of the result, latencies in milliseconds
+func resultValue(res *result, metric string, ps Percentiles) (float64, bool) {
+	if strings.HasPrefix(metric, "metric.") {
+		parts := customMetricExpr.FindStringSubmatch(metric)
+		if parts == nil {
+			return 0, false
+		}
+		var found *metricResult
+		for _, m := range res.Metrics {
+			if m.Name == parts[1] && (parts[2] == "" || m.Kind == parts[2]) {
+				if found != nil {
+					// metrics of different kinds with the name, the kind must be given
+					return 0, false
+				}
+				found = m
+			}
+		}
+		if found == nil {
+			return 0, false
+		}
+		return customMetricValue(found, parts[3], ps)
+	}
+	switch metric {
+	case "count":
+		return float64(res.Count), true
+	case "rate":
+		return res.Rate, true
+	case "errors":
+		return float64(res.ErrCount), true
+	case "error_rate":
+		if res.Count+res.ErrCount == 0 {
+			return 0, true
+		}
+		return float64(res.ErrCount) / float64(res.Count+res.ErrCount) * 100, true
+	case "min":
+		return res.Min, true
+	case "mean":
+		return res.Mean, true
+	case "max":
+		return res.Max, true
+	}
+	return percentileValue(metric, ps, res.Percentiles)
+}
+
+func customMetricValue(m *metricResult, field string, ps Percentiles) (float64, bool) {
+	switch field {
+	case "value":
+		return m.Value, true
+	case "count":
+		return float64(m.Count), true
+	case "rate":
+		return m.Rate, true
+	case "min":
+		return m.Min, true
+	case "mean":
+		return m.Mean, true
+	case "max":
+		return m.Max, true
+	}
+	return percentileValue(field, ps, m.Percentiles)
+}
+
+// percentileValue returns the value of a percentile by name, e.g. p99, if it is one of the reported percentiles
+func percentileValue(name string, ps Percentiles, values []float64) (float64, bool) {
+	for i, n := range percentilesOrDefault(ps).Names() {
+		if n == name && i < len(values) {
+			return values[i], true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,151 @@
+package lotgo
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func thresholdSummary() *summary {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	total := &result{Count: 99, ErrCount: 1, Min: 1, Mean: 12, Percentiles: []float64{10, 20, 45, 80}, Max: 90, Rate: 9.9,
+		Metrics: []*metricResult{{Name: "orders", Kind: METRIC_COUNTER, Count: 5, Value: 5, Rate: 0.5}}}
+	shop := &result{Test: "shop", Count: 99, ErrCount: 1, Mean: 12, Percentiles: []float64{10, 20, 45, 80}, Rate: 9.9}
+	login := &result{Test: "shop", Step: "login", Count: 99, Mean: 250, Percentiles: []float64{200, 300, 400, 500}, Rate: 9.9}
+	return &summary{
+		Config: &runConfig{Test: "shop", Clients: 2, Start: start, End: start.Add(10 * time.Second)},
+		Total:  total,
+		Tests:  []*result{shop},
+		Steps:  []*result{login},
+	}
+}
+
+func TestParseThreshold(t *testing.T) {
+	th, err := ParseThreshold("p99<500")
+	require.Nil(t, err)
+	assert.Equal(t, Threshold{Expr: "p99<500", Metric: "p99", Op: "<", Value: 500}, *th)
+
+	th, err = ParseThreshold(" shop/login:mean <= 200.5")
+	require.Nil(t, err)
+	assert.Equal(t, "shop", th.Test)
+	assert.Equal(t, "login", th.Step)
+	assert.Equal(t, "mean", th.Metric)
+	assert.Equal(t, "<=", th.Op)
+	assert.Equal(t, 200.5, th.Value)
+
+	th, err = ParseThreshold("shop:rate>=10")
+	require.Nil(t, err)
+	assert.Equal(t, "shop", th.Test)
+	assert.Equal(t, "", th.Step)
+	assert.Equal(t, ">=", th.Op)
+
+	th, err = ParseThreshold("shop/step: pay:p99<500")
+	require.Nil(t, err)
+	assert.Equal(t, "shop", th.Test)
+	assert.Equal(t, "step: pay", th.Step)
+	assert.Equal(t, "p99", th.Metric)
+
+	th, err = ParseThreshold("metric.login: ok.value>=99")
+	require.Nil(t, err)
+	assert.Equal(t, "", th.Test)
+	assert.Equal(t, "metric.login: ok.value", th.Metric)
+
+	th, err = ParseThreshold("shop:metric.login: ok.value>=99")
+	require.Nil(t, err)
+	assert.Equal(t, "shop", th.Test)
+	assert.Equal(t, "metric.login: ok.value", th.Metric)
+
+	th, err = ParseThreshold("shop:metric.lat.ms[trend].p99.9<5")
+	require.Nil(t, err)
+	assert.Equal(t, "metric.lat.ms[trend].p99.9", th.Metric)
+
+	_, err = ParseThreshold("metric.orders<5")
+	assert.NotNil(t, err)
+	_, err = ParseThreshold("metric.orders.p99x<5")
+	assert.NotNil(t, err)
+	_, err = ParseThreshold("p99")
+	assert.NotNil(t, err)
+	_, err = ParseThreshold("p99<fast")
+	assert.NotNil(t, err)
+	_, err = ParseThreshold("<500")
+	assert.NotNil(t, err)
+}
+
+func TestThresholds_Evaluate(t *testing.T) {
+	ts := Thresholds{}
+	for _, expr := range []string{"p99<50", "error_rate<1", "shop/login:mean<=200", "shop:rate>5", "metric.orders.count>=5", "p75<10", "other:count>0"} {
+		require.Nil(t, ts.Set(expr))
+	}
+	assert.Equal(t, "p99<50 error_rate<1 shop/login:mean<=200 shop:rate>5 metric.orders.count>=5 p75<10 other:count>0", ts.String())
+
+	results := ts.evaluate(thresholdSummary(), DefaultPercentiles)
+	require.Equal(t, 7, len(results))
+	assert.True(t, results[0].Passed)
+	assert.Equal(t, 45.0, results[0].Actual)
+	assert.False(t, results[1].Passed)
+	assert.Equal(t, 1.0, results[1].Actual)
+	assert.False(t, results[2].Passed)
+	assert.Equal(t, "shop/login:mean<=200: FAIL, actual 250", results[2].String())
+	assert.True(t, results[3].Passed)
+	assert.True(t, results[4].Passed)
+	assert.False(t, results[5].Found)
+	assert.Equal(t, "p75<10: FAIL, no value", results[5].String())
+	assert.False(t, results[6].Found)
+	assert.Equal(t, 4, failures(results))
+}
+
+func TestThresholds_CustomMetrics(t *testing.T) {
+	ps := Percentiles{50, 99.9}
+	res := &result{Metrics: []*metricResult{
+		{Name: "lat.ms", Kind: METRIC_TREND, Count: 4, Min: 1, Mean: 2, Percentiles: []float64{2, 7}, Max: 8},
+		{Name: "orders", Kind: METRIC_COUNTER, Count: 5, Value: 5},
+		{Name: "orders", Kind: METRIC_GAUGE, Count: 2, Value: 3}}}
+	for metric, want := range map[string]float64{"metric.lat.ms.p99.9": 7, "metric.lat.ms.p50": 2, "metric.lat.ms.max": 8,
+		"metric.lat.ms[trend].count": 4, "metric.orders[counter].value": 5, "metric.orders[gauge].value": 3} {
+		v, found := resultValue(res, metric, ps)
+		assert.True(t, found, metric)
+		assert.Equal(t, want, v, metric)
+	}
+	for _, metric := range []string{"metric.orders.value", "metric.orders[trend].value", "metric.lat.ms.p99", "metric.lat.value"} {
+		_, found := resultValue(res, metric, ps)
+		assert.False(t, found, metric)
+	}
+}
+
+// checkStepTest makes a check in its login step and another after it
+type checkStepTest struct {
+	myTest
+}
+
+func (e *checkStepTest) Test(lt *Runner) error {
+	lt.Step("login", func() error {
+		return lt.Check("login ok", nil)
+	})
+	lt.Check("done", errors.New("not done"))
+	return nil
+}
+
+func TestThresholds_ScopedCustomMetrics(t *testing.T) {
+	runner := &Runner{clients: 2, runs: 3, name: "shop", test: &checkStepTest{}}
+	slogger := NewSummaryLogger(0, &bytes.Buffer{}, &CsvFormat{}, DefaultPercentiles, DefaultPrecision, 10)
+	for _, expr := range []string{"shop/login:metric.login ok.value>=100", "shop/login:metric.done.count>0", "shop:metric.done.count>=6",
+		"shop:metric.login ok[check].count>=6"} {
+		require.Nil(t, slogger.thresholds.Set(expr))
+	}
+	runner.allListeners = Listeners{slogger}
+	runner.Run()
+
+	s := slogger.summary()
+	require.Equal(t, 1, len(s.Steps))
+	require.Equal(t, 1, len(s.Steps[0].Metrics))
+	assert.Equal(t, "login ok", s.Steps[0].Metrics[0].Name)
+	assert.Equal(t, 2, len(s.Tests[0].Metrics))
+	require.Equal(t, 4, len(s.Thresholds))
+	assert.True(t, s.Thresholds[0].Passed)
+	assert.False(t, s.Thresholds[1].Found)
+	assert.True(t, s.Thresholds[2].Passed)
+	assert.True(t, s.Thresholds[3].Passed)
+}
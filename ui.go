@@ -1,37 +1,98 @@
 package lotgo
 
 import (
-	"github.com/gizak/termui"
-	"time"
-	"github.com/rcrowley/go-metrics"
 	"fmt"
+	"github.com/gizak/termui"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
 	THROUGHPUT_COUNT = 72
+	UI_WINDOW        = 10 * time.Second
+	UI_WIDE          = 100
+	UI_MIN_HEIGHT    = 6
+	UI_DETAIL_HEIGHT = 6
 )
 
+const (
+	UI_VIEW_TOTAL = iota
+	UI_VIEW_TESTS
+	UI_VIEW_STEPS
+)
+
+var uiViewNames = []string{"all iterations", "per test", "per step"}
+
+const uiHelp = `q            quit
+p            pause or resume the run
+v            switch view: all iterations, per test, per step
+up, down     select an error group for the example message
+home         select the first error group
+g            group the errors by class or by message
+s            sort the errors by count or by last seen
+h, ?         show or hide this help`
+
+// uiLayout is the size of the dashboard rows for a terminal size
+type uiLayout struct {
+	wide        bool
+	listHeight  int
+	chartHeight int
+}
+
+// newUiLayout divides the terminal height between the lists and the charts, on narrow terminals
+// the lists and the charts are stacked to rows of their own
+func newUiLayout(width int, height int) uiLayout {
+	l := uiLayout{wide: width >= UI_WIDE}
+	chartRows := 2
+	listRows := 1
+	if !l.wide {
+		chartRows = 3
+		listRows = 2
+	}
+	free := height - 3
+	l.listHeight = free * 2 / 5 / listRows
+	if l.listHeight < UI_MIN_HEIGHT {
+		l.listHeight = UI_MIN_HEIGHT
+	}
+	l.chartHeight = (free - l.listHeight*listRows) / chartRows
+	if l.chartHeight < UI_MIN_HEIGHT {
+		l.chartHeight = UI_MIN_HEIGHT
+	}
+	return l
+}
+
 type ui struct {
+	sync.Mutex
 	runner       *Runner
 	topText      *termui.Par
 	testProgress *termui.Gauge
 	summaryList  *termui.List
 	errorList    *termui.List
 	throughPut   *termui.LineChart
+	latency      []*termui.LineChart
+	errorRate    *termui.LineChart
+	clientsChart *termui.LineChart
+	errorDetail  *termui.Par
+	help         *termui.Par
 
-	totalTimer metrics.Timer
-	lastUpdate time.Time
-	lastCount  int64
-	lastX      float64
-	errors     metrics.Counter
-	lastErrors []string
+	*liveStats
+	health      *healthMonitor
+	percentiles Percentiles
+	errorGroup  int
+	errorSort   int
+	selected    int
+	view        int
+	showHelp    bool
 }
 
-var _ Listener = &ui{}
+var _ SampleListener = &ui{}
 
-func NewUi() *ui {
-	return &ui{totalTimer: metrics.NewTimer(), errors: metrics.NewCounter()}
+// NewUi creates the terminal dashboard, the summary values and charts are computed over the sliding window
+func NewUi(ps Percentiles, precision int, window time.Duration) *ui {
+	return &ui{liveStats: newLiveStats(precision, window), percentiles: ps}
 }
 
 func (ui *ui) Loop() {
@@ -44,63 +105,104 @@ func (ui *ui) Loop() {
 	}
 	defer termui.Close()
 
-	topText := termui.NewPar("PRESS q TO QUIT")
+	topText := termui.NewPar("")
 	topText.Height = 3
-	topText.Width = 40
 	topText.TextFgColor = termui.ColorWhite
 	topText.BorderFg = termui.ColorCyan
-	ui.topText = topText;
+	ui.topText = topText
 
 	testProgress := termui.NewGauge()
-	testProgress.Percent = 50
-	testProgress.Width = 40
 	testProgress.Height = 3
-	testProgress.Y = 0
 	testProgress.BorderLabel = "Test progress"
 	testProgress.BarColor = termui.ColorRed
 	testProgress.BorderFg = termui.ColorWhite
 	testProgress.BorderLabelFg = termui.ColorCyan
-	testProgress.X = 41
 	ui.testProgress = testProgress
 
-	strs := []string{"[0] gizak/termui", "[1] editbox.go", "[2] interrupt.go", "[3] keyboard.go", "[4] output.go", "[5] random_out.go", "[6] dashboard.go", "[7] nsf/termbox-go"}
 	errorList := termui.NewList()
-	errorList.Items = strs
 	errorList.ItemFgColor = termui.ColorYellow
 	errorList.BorderLabel = "Errors"
-	errorList.Height = 13
-	errorList.Width = 40
-	errorList.Y = 3
-	errorList.X = 41
 	ui.errorList = errorList
 
-	strs2 := []string{"Throughput: 100.3 r/s", "Response time, avg: 10 ms", "      95%: 128 ms", "      75%: 45 ms", "Count: 12312", "Active clients: 10"}
+	errorDetail := termui.NewPar("")
+	errorDetail.Height = UI_DETAIL_HEIGHT
+	errorDetail.BorderLabel = "Error example"
+	errorDetail.TextFgColor = termui.ColorYellow
+	ui.errorDetail = errorDetail
+
 	summaryList := termui.NewList()
-	summaryList.Items = strs2
+	summaryList.Items = []string{"Waiting for results ..."}
 	summaryList.ItemFgColor = termui.ColorWhite
 	summaryList.BorderLabel = "Summary"
-	summaryList.Height = 13
-	summaryList.Width = 40
-	summaryList.Y = 3
-	summaryList.X = 0
 	ui.summaryList = summaryList
 
-	throughPut := termui.NewLineChart()
-	throughPut.BorderLabel = "Throughput r/s"
-	throughPut.Width = 81
-	throughPut.Height = 11
-	throughPut.X = 0
-	throughPut.Y = 16
-	throughPut.AxesColor = termui.ColorWhite
-	throughPut.LineColor = termui.ColorRed | termui.AttrBold
-	throughPut.Mode = "dot"
-	throughPut.Data = []float64{0.0}
-	ui.throughPut = throughPut
+	ui.throughPut = newUiChart("Throughput r/s", termui.ColorRed)
+	ui.clientsChart = newUiChart("Active clients", termui.ColorCyan)
+	for _, name := range livePercentiles.Names() {
+		ui.latency = append(ui.latency, newUiChart("Latency "+name+" ms", termui.ColorGreen))
+	}
+	ui.errorRate = newUiChart("Error rate %", termui.ColorYellow)
+
+	help := termui.NewPar(uiHelp)
+	help.BorderLabel = "Help"
+	help.BorderFg = termui.ColorCyan
+	help.Width = 60
+	help.Height = strings.Count(uiHelp, "\n") + 3
+	ui.help = help
+
+	ui.Lock()
+	ui.layout(termui.TermWidth(), termui.TermHeight())
+	ui.updateWidgets()
+	ui.Unlock()
+	ui.render()
 
 	termui.Handle("/sys/kbd/q", func(termui.Event) {
 		termui.StopLoop()
 		ui.runner.Stop()
 	})
+	termui.Handle("/sys/kbd/p", func(termui.Event) {
+		ui.togglePause()
+	})
+	termui.Handle("/sys/kbd/v", func(termui.Event) {
+		ui.control(func() { ui.view = (ui.view + 1) % len(uiViewNames) })
+	})
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		ui.control(func() { ui.selectError(1) })
+	})
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		ui.control(func() { ui.selectError(-1) })
+	})
+	termui.Handle("/sys/kbd/<home>", func(termui.Event) {
+		ui.control(func() { ui.selected = 0 })
+	})
+	termui.Handle("/sys/kbd/g", func(termui.Event) {
+		ui.control(func() {
+			ui.errorGroup = (ui.errorGroup + 1) % len(uiGroupNames)
+			ui.selected = 0
+		})
+	})
+	termui.Handle("/sys/kbd/s", func(termui.Event) {
+		ui.control(func() {
+			ui.errorSort = (ui.errorSort + 1) % len(uiSortNames)
+			ui.selected = 0
+		})
+	})
+	for _, key := range []string{"/sys/kbd/h", "/sys/kbd/?"} {
+		termui.Handle(key, func(termui.Event) {
+			ui.control(func() { ui.showHelp = !ui.showHelp })
+		})
+	}
+	termui.Handle("/sys/kbd/<escape>", func(termui.Event) {
+		ui.control(func() { ui.showHelp = false })
+	})
+	termui.Handle("/sys/wnd/resize", func(e termui.Event) {
+		w := e.Data.(termui.EvtWnd)
+		ui.Lock()
+		ui.layout(w.Width, w.Height)
+		ui.Unlock()
+		termui.Clear()
+		ui.render()
+	})
 	termui.Handle("/timer/1s", func(e termui.Event) {
 		t := e.Data.(termui.EvtTimer)
 		ui.Redraw(int(t.Count))
@@ -110,8 +212,108 @@ func (ui *ui) Loop() {
 	LOG().Infof("termui closed")
 }
 
+func newUiChart(label string, color termui.Attribute) *termui.LineChart {
+	chart := termui.NewLineChart()
+	chart.BorderLabel = label
+	chart.AxesColor = termui.ColorWhite
+	chart.LineColor = color | termui.AttrBold
+	chart.Mode = "dot"
+	chart.Data = []float64{0.0}
+	return chart
+}
+
+// layout arranges the widgets on the grid for the terminal size
+func (ui *ui) layout(width int, height int) {
+	l := newUiLayout(width, height)
+	ui.summaryList.Height = l.listHeight
+	ui.errorList.Height = l.listHeight - UI_DETAIL_HEIGHT
+	if ui.errorList.Height < UI_MIN_HEIGHT-2 {
+		ui.errorList.Height = UI_MIN_HEIGHT - 2
+	}
+	charts := []*termui.LineChart{ui.throughPut, ui.clientsChart, ui.errorRate}
+	charts = append(charts, ui.latency...)
+	for _, chart := range charts {
+		chart.Height = l.chartHeight
+	}
+
+	termui.Body.Rows = nil
+	termui.Body.Width = width
+	termui.Body.X = 0
+	termui.Body.Y = 0
+	if l.wide {
+		termui.Body.AddRows(
+			termui.NewRow(termui.NewCol(6, 0, ui.topText), termui.NewCol(6, 0, ui.testProgress)),
+			termui.NewRow(termui.NewCol(6, 0, ui.summaryList), termui.NewCol(6, 0, ui.errorList, ui.errorDetail)),
+			termui.NewRow(termui.NewCol(4, 0, ui.throughPut), termui.NewCol(4, 0, ui.errorRate), termui.NewCol(4, 0, ui.clientsChart)),
+			termui.NewRow(termui.NewCol(4, 0, ui.latency[0]), termui.NewCol(4, 0, ui.latency[1]), termui.NewCol(4, 0, ui.latency[2])))
+	} else {
+		termui.Body.AddRows(
+			termui.NewRow(termui.NewCol(6, 0, ui.topText), termui.NewCol(6, 0, ui.testProgress)),
+			termui.NewRow(termui.NewCol(12, 0, ui.summaryList)),
+			termui.NewRow(termui.NewCol(12, 0, ui.errorList, ui.errorDetail)),
+			termui.NewRow(termui.NewCol(6, 0, ui.throughPut), termui.NewCol(6, 0, ui.errorRate)),
+			termui.NewRow(termui.NewCol(6, 0, ui.latency[0]), termui.NewCol(6, 0, ui.latency[1])),
+			termui.NewRow(termui.NewCol(6, 0, ui.latency[2]), termui.NewCol(6, 0, ui.clientsChart)))
+	}
+	termui.Body.Align()
+
+	ui.help.X = (width - ui.help.Width) / 2
+	if ui.help.X < 0 {
+		ui.help.X = 0
+	}
+	ui.help.Y = 3
+}
+
+// control applies a keyboard action and redraws without collecting new results
+func (ui *ui) control(fn func()) {
+	ui.Lock()
+	fn()
+	if ui.runner != nil {
+		ui.updateWidgets()
+	}
+	ui.Unlock()
+	ui.render()
+}
+
+func (ui *ui) togglePause() {
+	ui.control(func() {
+		if ui.runner == nil {
+			return
+		}
+		if ui.runner.IsPaused() {
+			LOG().Infof("Resumed by user")
+			ui.runner.Resume()
+		} else {
+			LOG().Infof("Paused by user")
+			ui.runner.Pause()
+		}
+	})
+}
+
+// selectError moves the selection in the error groups by n lines
+func (ui *ui) selectError(n int) {
+	ui.selected += n
+	if max := len(ui.errs.groups[ui.errorGroup]) - 1; ui.selected > max {
+		ui.selected = max
+	}
+	if ui.selected < 0 {
+		ui.selected = 0
+	}
+}
+
+func (ui *ui) render() {
+	if ui.showHelp {
+		termui.Render(termui.Body, ui.help)
+	} else {
+		termui.Render(termui.Body)
+	}
+}
+
 func (ui *ui) Started(runner *Runner) {
+	ui.Lock()
 	ui.runner = runner
+	ui.Unlock()
+	ui.started(runner.clients)
 }
 
 func (ui *ui) Finished() {
@@ -119,71 +321,153 @@ func (ui *ui) Finished() {
 	termui.StopLoop()
 }
 
-func (ui *ui) Success(d time.Duration) {
-	ui.totalTimer.Update(d)
-}
-
-func (ui *ui) Error(err error) {
-	list := ui.lastErrors
-	list = append(list, err.Error())
-	if len(list) > 10 {
-		list = list[len(list)-10:]
+func (ui *ui) Sample(s *Sample) {
+	if s.Err == nil {
+		ui.success(&s.Iteration)
+		return
 	}
-	ui.lastErrors = list
-	ui.errors.Inc(1)
+	ui.Lock()
+	ui.error(s.Err, &s.Iteration)
+	ui.Unlock()
 }
 
 func (ui *ui) Redraw(int) {
 	if ui.testProgress == nil {
 		return
 	}
-	ui.testProgress.Percent = int(ui.calculateProgress())
-	ui.errorList.Items = ui.lastErrors
-	ui.summaryList.Items = ui.summaryItems()
+	ui.Lock()
+	if ui.runner == nil {
+		ui.Unlock()
+		return
+	}
+	ui.update(time.Now())
+	ui.updateWidgets()
+	ui.Unlock()
+	ui.render()
+}
+
+// updateWidgets copies the current values to the widgets
+func (ui *ui) updateWidgets() {
+	status := "RUNNING"
+	if ui.runner.IsPaused() {
+		status = "PAUSED"
+	}
+	ui.topText.Text = fmt.Sprintf("%s  view: %s  [h] help", status, uiViewNames[ui.view])
+	ui.testProgress.Percent = int(ui.progress(ui.runner))
+	groups := ui.errs.sorted(ui.errorGroup, ui.errorSort)
+	ui.errorList.Items = ui.errorItems(groups, time.Now())
+	ui.errorList.BorderLabel = fmt.Sprintf("Errors by %s, sorted by %s", uiGroupNames[ui.errorGroup], uiSortNames[ui.errorSort])
+	var selected *uiErrorGroup
+	if ui.selected < len(groups) {
+		selected = groups[ui.selected]
+	}
+	ui.errorDetail.Text = formatUiErrorDetail(selected)
+	ui.summaryList.Items = ui.viewItems()
+	ui.summaryList.BorderLabel = fmt.Sprintf("Summary, %s", uiViewNames[ui.view])
+	ui.throughPut.Data = chartData(ui.rates)
+	ui.clientsChart.Data = chartData(ui.clients)
+	for i, chart := range ui.latency {
+		chart.Data = chartData(ui.latencies[i])
+	}
+	ui.errorRate.Data = chartData(ui.errorRates)
+}
 
-	xData := ui.throughPut.Data
-	xData = append(xData, ui.lastX)
-	if len(xData) > THROUGHPUT_COUNT {
-		xData = xData[len(xData) - THROUGHPUT_COUNT:]
+// errorItems returns the error groups as list items, scrolled to keep the selected group visible
+func (ui *ui) errorItems(groups []*uiErrorGroup, now time.Time) []string {
+	window := ui.windowDuration()
+	items := []string{fmt.Sprintf(" %7s %9s %10s  %s", "count", "rate", "last seen", uiGroupNames[ui.errorGroup])}
+	first := 0
+	if ui.errorList != nil {
+		if visible := ui.errorList.Height - 3; visible > 0 && ui.selected >= visible {
+			first = ui.selected - visible + 1
+		}
 	}
-	ui.throughPut.Data = xData
+	for i := first; i < len(groups); i++ {
+		items = append(items, formatUiErrorGroup(groups[i], window, now, i == ui.selected))
+	}
+	return items
+}
 
-	termui.Render(ui.topText, ui.testProgress, ui.summaryList, ui.errorList, ui.throughPut)
+// update collects the results since the previous update
+func (ui *ui) update(now time.Time) {
+	ui.liveStats.update(now, ui.runner)
 }
 
-func (ui *ui) calculateProgress() int64 {
-	if ui.runner.runs > 0 {
-		total := ui.runner.runs * ui.runner.clients
-		count := ui.totalTimer.Count()
-		return count * 100 / int64(total)
-	} else {
-		duration := ui.runner.duration
-		since := time.Since(ui.runner.startTime)
-		return int64(since * 100 / duration)
+// chartData returns the series for a line chart, which needs at least one point
+func chartData(series []float64) []float64 {
+	if len(series) == 0 {
+		return []float64{0.0}
+	}
+	return append([]float64{}, series...)
+}
+
+// viewItems returns the summary items of the selected view
+func (ui *ui) viewItems() []string {
+	switch ui.view {
+	case UI_VIEW_TESTS:
+		return ui.keyItems(false)
+	case UI_VIEW_STEPS:
+		return ui.keyItems(true)
+	}
+	return ui.summaryItems()
+}
+
+// keyItems returns a line for every test or step with its results in the window
+func (ui *ui) keyItems(steps bool) []string {
+	var keys []stepKey
+	for key := range ui.windowKeys {
+		if (key.step != "") == steps {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return stepKeyLess(keys[i], keys[j])
+	})
+	d := ui.windowDuration()
+	items := []string{fmt.Sprintf("Last %s, latency in ms:", ui.window)}
+	for _, key := range keys {
+		st := ui.windowKeys[key]
+		name := key.test
+		if steps {
+			name = key.step
+		}
+		rate := 0.0
+		if d > 0 {
+			rate = float64(st.hist.Count()) / d.Seconds()
+		}
+		items = append(items, fmt.Sprintf("%-16s %7.1f r/s  mean %6.1f  p99 %6.1f  err %5.2f%%", truncate(name, 16), rate,
+			st.hist.Mean()/1000, toMillis(st.hist.ValueAtPercentile(99)), errorRateOf(st)))
+	}
+	if len(keys) == 0 {
+		items = append(items, "No results")
 	}
+	return items
 }
 
+// summaryItems returns the totals since the start and the results of the sliding window
 func (ui *ui) summaryItems() []string {
-	totalCount := ui.totalTimer.Count()
-	if !ui.lastUpdate.IsZero() {
-		count := totalCount - ui.lastCount
-		duration := time.Since(ui.lastUpdate)
-		ui.lastX = float64(count) / float64(duration) * float64(time.Second)
-	}
-	ui.lastUpdate = time.Now()
-	ui.lastCount = totalCount
-	count := ui.totalTimer.Count()
+	count := ui.totalHist.Count()
 	since := time.Since(ui.runner.startTime)
-	return []string{
+	hist := ui.windowStats.hist
+	items := []string{
 		fmt.Sprintf("Test:                %s", testName),
 		fmt.Sprintf("Go max procs:        %d", runtime.GOMAXPROCS(0)),
 		fmt.Sprintf("Clients:             %d / %d", ui.runner.ActiveClients(), clients),
-		fmt.Sprintf("Errors:              %d", ui.errors.Count()),
+		fmt.Sprintf("Errors:              %d", ui.errors),
 		fmt.Sprintf("Successes:           %d", count),
-		fmt.Sprintf("Time:                %d ms", since / time.Millisecond),
-		fmt.Sprintf("Throughput:          %f r/s", ui.lastX),
-		fmt.Sprintf("Response time, mean: %f ms", ui.totalTimer.Mean()/1000/1000),
-		fmt.Sprintf("Response time, 75%%:  %f ms", ui.totalTimer.Percentile(0.75)/1000/1000),
-		fmt.Sprintf("Response time, 95%%:  %f ms", ui.totalTimer.Percentile(0.95)/1000/1000),
+		fmt.Sprintf("Time:                %d ms", since/time.Millisecond),
+		fmt.Sprintf("Last %s:", ui.window),
+		fmt.Sprintf("Throughput:          %.1f r/s", ui.windowRate),
+		fmt.Sprintf("Error rate:          %.2f %%", ui.windowErrorRate()),
+		fmt.Sprintf("Response time, min:  %.1f ms", toMillis(hist.Min())),
+		fmt.Sprintf("Response time, mean: %.1f ms", hist.Mean()/1000),
+	}
+	for i, p := range ui.percentiles {
+		items = append(items, fmt.Sprintf("Response time, %-6s%.1f ms", ui.percentiles.Names()[i]+":", toMillis(hist.ValueAtPercentile(p))))
 	}
-}
\ No newline at end of file
+	items = append(items, fmt.Sprintf("Response time, max:  %.1f ms", toMillis(hist.Max())))
+	if ui.health != nil {
+		items = append(items, ui.health.status()...)
+	}
+	return items
+}
@@ -0,0 +1,150 @@
+package lotgo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUi_UpdateWindow(t *testing.T) {
+	start := time.Now()
+	ui := NewUi(DefaultPercentiles, DefaultPrecision, 2*time.Second)
+	ui.Started(&Runner{clients: 1, startTime: start})
+
+	for i := 0; i < 10; i++ {
+		ui.Sample(&Sample{Iteration: Iteration{Duration: 100 * time.Millisecond}})
+	}
+	ui.update(start.Add(time.Second))
+	assert.Equal(t, 10.0, ui.windowRate)
+	assert.InDelta(t, 100, ui.latencies[2][0], 1)
+
+	for i := 0; i < 10; i++ {
+		ui.Sample(&Sample{Iteration: Iteration{Duration: 10 * time.Millisecond}})
+	}
+	ui.Sample(&Sample{Iteration: Iteration{Class: "failed"}, Err: errors.New("failed")})
+	ui.update(start.Add(2 * time.Second))
+	assert.Equal(t, 20, int(ui.windowStats.hist.Count()))
+	assert.InDelta(t, 100.0/21, ui.windowErrorRate(), 0.001)
+
+	// the first second slides out of the window
+	ui.Sample(&Sample{Iteration: Iteration{Duration: 10 * time.Millisecond}})
+	ui.update(start.Add(3 * time.Second))
+	assert.Equal(t, 11, int(ui.windowStats.hist.Count()))
+	assert.Equal(t, 21, int(ui.totalHist.Count()))
+	assert.Equal(t, 5.5, ui.windowRate)
+	assert.InDelta(t, 10, ui.latencies[2][2], 1)
+	assert.Equal(t, []float64{10, 10, 1}, ui.rates)
+	assert.Equal(t, 3, len(ui.errorRates))
+	assert.Equal(t, 3, len(ui.clients))
+}
+
+func TestAppendPoint(t *testing.T) {
+	var series []float64
+	for i := 0; i < THROUGHPUT_COUNT+5; i++ {
+		series = appendPoint(series, float64(i))
+	}
+	assert.Equal(t, THROUGHPUT_COUNT, len(series))
+	assert.Equal(t, 5.0, series[0])
+	assert.Equal(t, []float64{0.0}, chartData(nil))
+}
+
+func TestNewUiLayout(t *testing.T) {
+	l := newUiLayout(160, 53)
+	assert.True(t, l.wide)
+	assert.Equal(t, 20, l.listHeight)
+	assert.Equal(t, 15, l.chartHeight)
+
+	l = newUiLayout(80, 43)
+	assert.False(t, l.wide)
+	assert.Equal(t, 8, l.listHeight)
+	assert.Equal(t, 8, l.chartHeight)
+
+	l = newUiLayout(40, 10)
+	assert.Equal(t, UI_MIN_HEIGHT, l.listHeight)
+	assert.Equal(t, UI_MIN_HEIGHT, l.chartHeight)
+}
+
+func TestUi_ViewsAndErrors(t *testing.T) {
+	start := time.Now()
+	ui := NewUi(DefaultPercentiles, DefaultPrecision, time.Second)
+	ui.Started(&Runner{clients: 1, startTime: start})
+	ui.Sample(&Sample{Iteration: Iteration{Test: "shop", Duration: 10 * time.Millisecond}})
+	ui.Sample(&Sample{Iteration: Iteration{Test: "shop", Step: "login", Client: -1, Duration: 5 * time.Millisecond}})
+	ui.Sample(&Sample{Iteration: Iteration{Test: "shop", Step: "login", Client: -1, Class: "failed"}, Err: errors.New("step failed")})
+	for i := 0; i < 3; i++ {
+		ui.Sample(&Sample{Iteration: Iteration{Test: "shop", Number: i, Class: "failed"}, Err: errors.New("failed")})
+	}
+	ui.update(start.Add(time.Second))
+
+	assert.Equal(t, "Test:                "+testName, ui.viewItems()[0])
+	ui.view = UI_VIEW_TESTS
+	items := ui.viewItems()
+	assert.Equal(t, 2, len(items))
+	assert.True(t, strings.HasPrefix(items[1], "shop                 1.0 r/s  mean   10.0"))
+	assert.True(t, strings.HasSuffix(items[1], "err 75.00%"))
+	ui.view = UI_VIEW_STEPS
+	items = ui.viewItems()
+	assert.Equal(t, 2, len(items))
+	assert.True(t, strings.HasPrefix(items[1], "login"))
+	assert.True(t, strings.HasSuffix(items[1], "err 50.00%"))
+
+	assert.Equal(t, int64(3), ui.errors)
+}
+
+func TestUi_ErrorGroups(t *testing.T) {
+	start := time.Now()
+	ui := NewUi(DefaultPercentiles, DefaultPrecision, 2*time.Second)
+	ui.Started(&Runner{clients: 1, startTime: start})
+	for i := 0; i < 4; i++ {
+		ui.Sample(&Sample{Iteration: Iteration{Start: start, Class: "connection refused"}, Err: errors.New("connection refused")})
+	}
+	ui.Sample(&Sample{Iteration: Iteration{Start: start.Add(500 * time.Millisecond), Class: "http 500"}, Err: errors.New("HTTP 500 for /cart")})
+	ui.Sample(&Sample{Iteration: Iteration{Start: start.Add(600 * time.Millisecond), Class: "http 500"}, Err: errors.New("HTTP 500 for /order")})
+	ui.update(start.Add(time.Second))
+
+	groups := ui.errs.sorted(UI_GROUP_CLASS, UI_SORT_COUNT)
+	assert.Equal(t, 2, len(groups))
+	assert.Equal(t, "connection refused", groups[0].Key)
+	assert.Equal(t, int64(2), groups[1].Count)
+	assert.Equal(t, "HTTP 500 for /cart", groups[1].Example)
+
+	groups = ui.errs.sorted(UI_GROUP_CLASS, UI_SORT_RECENT)
+	assert.Equal(t, "http 500", groups[0].Key)
+
+	groups = ui.errs.sorted(UI_GROUP_MESSAGE, UI_SORT_COUNT)
+	assert.Equal(t, 3, len(groups))
+	assert.Equal(t, "HTTP 500 for /cart", groups[1].Key)
+
+	items := ui.errorItems(ui.errs.sorted(UI_GROUP_CLASS, UI_SORT_COUNT), start.Add(2*time.Second))
+	assert.Equal(t, "   count      rate  last seen  class", items[0])
+	assert.Equal(t, ">      4     4.0/s     2s ago  connection refused", items[1])
+	assert.Equal(t, "       2     2.0/s     1s ago  http 500", items[2])
+
+	// the counts slide out of the window but the totals remain
+	ui.update(start.Add(2 * time.Second))
+	ui.update(start.Add(3 * time.Second))
+	assert.Equal(t, int64(0), ui.errs.groups[UI_GROUP_CLASS]["connection refused"].windowCount())
+	assert.Equal(t, int64(4), ui.errs.sorted(UI_GROUP_CLASS, UI_SORT_COUNT)[0].Count)
+
+	ui.selectError(5)
+	assert.Equal(t, 1, ui.selected)
+	ui.selectError(-5)
+	assert.Equal(t, 0, ui.selected)
+	assert.Equal(t, "No errors", formatUiErrorDetail(nil))
+	assert.Contains(t, formatUiErrorDetail(ui.errs.groups[UI_GROUP_CLASS]["http 500"]), "class: http 500, count: 2")
+}
+
+func TestUiErrors_LimitMessages(t *testing.T) {
+	e := newUiErrors()
+	for i := 0; i < UI_ERROR_GROUPS+10; i++ {
+		e.add("other", fmt.Errorf("error %d", i), time.Now())
+	}
+	assert.Equal(t, UI_ERROR_GROUPS+1, len(e.groups[UI_GROUP_MESSAGE]))
+	assert.Equal(t, int64(10), e.groups[UI_GROUP_MESSAGE][UI_OTHER_ERRORS].Count)
+	assert.Equal(t, int64(UI_ERROR_GROUPS+10), e.groups[UI_GROUP_CLASS]["other"].Count)
+	assert.Equal(t, "12s", formatAgo(12500*time.Millisecond))
+	assert.Equal(t, "2h", formatAgo(150*time.Minute))
+}
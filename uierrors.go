@@ -0,0 +1,147 @@
+package lotgo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	UI_GROUP_CLASS = iota
+	UI_GROUP_MESSAGE
+)
+
+const (
+	UI_SORT_COUNT = iota
+	UI_SORT_RECENT
+)
+
+const (
+	UI_ERROR_GROUPS  = 200
+	UI_ERROR_MESSAGE = 200
+	UI_OTHER_ERRORS  = "(other messages)"
+)
+
+var uiGroupNames = []string{"class", "message"}
+var uiSortNames = []string{"count", "last seen"}
+
+// uiErrorGroup counts the errors of a class or a message
+type uiErrorGroup struct {
+	Key     string
+	Class   string
+	Count   int64
+	First   time.Time
+	Last    time.Time
+	Example string
+	current int64
+	ticks   []int64
+}
+
+// windowCount returns the number of errors in the sliding window
+func (g *uiErrorGroup) windowCount() int64 {
+	n := g.current
+	for _, c := range g.ticks {
+		n += c
+	}
+	return n
+}
+
+// uiErrors groups the errors shown in the terminal UI both by class and by message. At most
+// UI_ERROR_GROUPS messages are kept, the rest are counted in one group.
+type uiErrors struct {
+	groups [2]map[string]*uiErrorGroup
+}
+
+func newUiErrors() *uiErrors {
+	return &uiErrors{groups: [2]map[string]*uiErrorGroup{{}, {}}}
+}
+
+func (e *uiErrors) add(class string, err error, t time.Time) {
+	msg := err.Error()
+	key := truncate(msg, UI_ERROR_MESSAGE)
+	if _, ok := e.groups[UI_GROUP_MESSAGE][key]; !ok && len(e.groups[UI_GROUP_MESSAGE]) >= UI_ERROR_GROUPS {
+		key = UI_OTHER_ERRORS
+	}
+	e.group(UI_GROUP_CLASS, class, class, msg, t).current++
+	e.group(UI_GROUP_MESSAGE, key, class, msg, t).current++
+}
+
+func (e *uiErrors) group(mode int, key string, class string, msg string, t time.Time) *uiErrorGroup {
+	g, ok := e.groups[mode][key]
+	if !ok {
+		g = &uiErrorGroup{Key: key, Class: class, First: t, Example: msg}
+		e.groups[mode][key] = g
+	}
+	g.Count++
+	g.Last = t
+	return g
+}
+
+// tick closes the counts since the previous tick, keeping the counts of the latest n ticks
+func (e *uiErrors) tick(n int) {
+	for _, groups := range e.groups {
+		for _, g := range groups {
+			g.ticks = append(g.ticks, g.current)
+			g.current = 0
+			if len(g.ticks) > n {
+				g.ticks = g.ticks[len(g.ticks)-n:]
+			}
+		}
+	}
+}
+
+// sorted returns the groups by descending count or latest occurrence
+func (e *uiErrors) sorted(mode int, order int) []*uiErrorGroup {
+	list := make([]*uiErrorGroup, 0, len(e.groups[mode]))
+	for _, g := range e.groups[mode] {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if order == UI_SORT_RECENT && !a.Last.Equal(b.Last) {
+			return a.Last.After(b.Last)
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Key < b.Key
+	})
+	return list
+}
+
+// formatUiErrorGroup formats a group as a list item with the count, the rate in the window and the time since last seen
+func formatUiErrorGroup(g *uiErrorGroup, window time.Duration, now time.Time, selected bool) string {
+	mark := " "
+	if selected {
+		mark = ">"
+	}
+	rate := 0.0
+	if window > 0 {
+		rate = float64(g.windowCount()) / window.Seconds()
+	}
+	return fmt.Sprintf("%s%7d %7.1f/s %6s ago  %s", mark, g.Count, rate, formatAgo(now.Sub(g.Last)),
+		strings.Replace(g.Key, "\n", " ", -1))
+}
+
+// formatAgo formats a duration compactly, e.g. 850ms, 12s, 5m or 2h
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", d/time.Second)
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%dh", d/time.Hour)
+}
+
+// formatUiErrorDetail formats the selected group with its full example message
+func formatUiErrorDetail(g *uiErrorGroup) string {
+	if g == nil {
+		return "No errors"
+	}
+	return fmt.Sprintf("class: %s, count: %d, first: %s, last: %s\n%s", g.Class, g.Count,
+		g.First.Format(ERROR_TIME_FORMAT), g.Last.Format(ERROR_TIME_FORMAT), g.Example)
+}
@@ -0,0 +1,516 @@
+package lotgo
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WEB_INTERVAL = time.Second
+	WEB_ERRORS   = 10
+
+	WEB_SHUTDOWN_TIMEOUT = 2 * time.Second
+
+	// WEB_TOKEN_HEADER carries the token of the run in the control requests of the dashboard page
+	WEB_TOKEN_HEADER = "X-Lotgo-Token"
+)
+
+// webSnapshot is the state of the test sent to the browser on every update
+type webSnapshot struct {
+	Time        int64              `json:"time"`
+	Elapsed     float64            `json:"elapsed"`
+	Test        string             `json:"test"`
+	Progress    int64              `json:"progress"`
+	Clients     int32              `json:"clients"`
+	ClientLimit int                `json:"client_limit"`
+	MaxClients  int                `json:"max_clients"`
+	Paused      bool               `json:"paused"`
+	Done        bool               `json:"done"`
+	Count       int64              `json:"count"`
+	Errors      int64              `json:"errors"`
+	Rate        float64            `json:"rate"`
+	WindowRate  float64            `json:"window_rate"`
+	ErrorRate   float64            `json:"error_rate"`
+	Latency     map[string]float64 `json:"latency"`
+	ErrorGroups []*webErrorGroup   `json:"error_groups"`
+}
+
+// webErrorGroup is an error class with its rate in the window and seconds since last seen
+type webErrorGroup struct {
+	Class    string  `json:"class"`
+	Count    int64   `json:"count"`
+	Rate     float64 `json:"rate"`
+	LastSeen float64 `json:"last_seen"`
+	Example  string  `json:"example"`
+}
+
+var _ SampleListener = &webDashboard{}
+
+/* Listener which serves a live dashboard of the test for the browser with controls for the run */
+type webDashboard struct {
+	sync.Mutex
+	*liveStats
+	addr        string
+	token       string
+	server      *http.Server
+	listener    net.Listener
+	runner      *Runner
+	subscribers map[chan []byte]bool
+	history     [][]byte
+	last        []byte
+	done        chan bool
+	stopped     sync.WaitGroup
+}
+
+// NewWebDashboard creates a dashboard served at http://addr/, the values are computed over the sliding window.
+// The controls accept only requests with the random token of the run, which is given to anyone loading the
+// dashboard page, so an address without a host is bound to localhost.
+func NewWebDashboard(addr string, precision int, window time.Duration) *webDashboard {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		LOG().Fatalf("Failed to create the web dashboard token, reason %v", err)
+	}
+	addr = webBindAddr(addr)
+	return &webDashboard{addr: addr, token: hex.EncodeToString(token), liveStats: newLiveStats(precision, window), subscribers: map[chan []byte]bool{}}
+}
+
+// webBindAddr binds a bare port or an address without a host to localhost, and warns when the dashboard
+// is served on another host as everyone who can reach it can stop and change the run.
+func webBindAddr(addr string) string {
+	if _, err := strconv.Atoi(addr); err == nil {
+		addr = ":" + addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "" {
+		return net.JoinHostPort("localhost", port)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		LOG().Warnf("Web dashboard bound to %s, anyone who can open it can stop the run and change the load", addr)
+	}
+	return addr
+}
+
+func (w *webDashboard) Started(runner *Runner) {
+	w.runner = runner
+	w.started(runner.clients)
+	w.done = make(chan bool)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", w.serveIndex)
+	mux.HandleFunc("/events", w.serveEvents)
+	mux.HandleFunc("/api/status", w.serveStatus)
+	mux.HandleFunc("/api/stop", w.control(func(r *http.Request) error {
+		LOG().Infof("Stopped from the web dashboard")
+		runner.Stop()
+		return nil
+	}))
+	mux.HandleFunc("/api/pause", w.control(func(r *http.Request) error {
+		runner.Pause()
+		return nil
+	}))
+	mux.HandleFunc("/api/resume", w.control(func(r *http.Request) error {
+		runner.Resume()
+		return nil
+	}))
+	mux.HandleFunc("/api/clients", w.control(func(r *http.Request) error {
+		n, err := strconv.Atoi(r.FormValue("n"))
+		if err != nil {
+			return fmt.Errorf("invalid number of clients '%s'", r.FormValue("n"))
+		}
+		LOG().Infof("Client limit set to %d from the web dashboard", n)
+		runner.SetClientLimit(n)
+		return nil
+	}))
+	w.server = &http.Server{Handler: mux}
+	l, err := net.Listen("tcp", w.addr)
+	if err != nil {
+		LOG().Errorf("Web dashboard failed: %v", err)
+	} else {
+		w.listener = l
+		go func() {
+			err := w.server.Serve(l)
+			if err != nil && err != http.ErrServerClosed {
+				LOG().Errorf("Web dashboard failed: %v", err)
+			}
+		}()
+		LOG().Infof("Serving the dashboard at http://%s/", l.Addr())
+	}
+	w.publish(time.Now(), false)
+	w.stopped.Add(1)
+	go w.loop()
+}
+
+func (w *webDashboard) loop() {
+	defer w.stopped.Done()
+	ticker := time.NewTicker(WEB_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			w.publish(now, false)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *webDashboard) Sample(s *Sample) {
+	if s.Err == nil {
+		w.success(&s.Iteration)
+		return
+	}
+	w.Lock()
+	w.error(s.Err, &s.Iteration)
+	w.Unlock()
+}
+
+func (w *webDashboard) Finished() {
+	close(w.done)
+	w.stopped.Wait()
+	w.publish(time.Now(), true)
+	w.Lock()
+	for ch := range w.subscribers {
+		close(ch)
+		delete(w.subscribers, ch)
+	}
+	w.Unlock()
+	if w.listener != nil {
+		// let the event streams send the final snapshot before closing
+		ctx, cancel := context.WithTimeout(context.Background(), WEB_SHUTDOWN_TIMEOUT)
+		defer cancel()
+		if err := w.server.Shutdown(ctx); err != nil {
+			w.server.Close()
+		}
+	}
+}
+
+// publish updates the results and sends the snapshot to all subscribers
+func (w *webDashboard) publish(now time.Time, done bool) {
+	w.Lock()
+	defer w.Unlock()
+	w.update(now, w.runner)
+	data := bytes.TrimSpace([]byte(marshalJson(w.snapshot(now, done), false)))
+	w.last = data
+	w.history = append(w.history, data)
+	if len(w.history) > THROUGHPUT_COUNT {
+		w.history = w.history[len(w.history)-THROUGHPUT_COUNT:]
+	}
+	for ch := range w.subscribers {
+		select {
+		case ch <- data:
+		default:
+			// a slow browser misses updates instead of blocking the test
+		}
+	}
+}
+
+func (w *webDashboard) snapshot(now time.Time, done bool) *webSnapshot {
+	runner := w.runner
+	s := &webSnapshot{
+		Time:        now.UnixNano() / int64(time.Millisecond),
+		Elapsed:     now.Sub(runner.startTime).Seconds(),
+		Test:        runner.name,
+		Progress:    w.progress(runner),
+		Clients:     runner.ActiveClients(),
+		ClientLimit: runner.ClientLimit(),
+		MaxClients:  runner.clients,
+		Paused:      runner.IsPaused(),
+		Done:        done,
+		Count:       w.totalHist.Count(),
+		Errors:      w.errors,
+		Rate:        w.lastX,
+		WindowRate:  w.windowRate,
+		ErrorRate:   w.windowErrorRate(),
+		Latency:     map[string]float64{},
+		ErrorGroups: []*webErrorGroup{},
+	}
+	names := livePercentiles.Names()
+	for i, p := range livePercentiles {
+		s.Latency[names[i]] = toMillis(w.windowStats.hist.ValueAtPercentile(p))
+	}
+	s.Latency["mean"] = w.windowStats.hist.Mean() / 1000
+	window := w.windowDuration()
+	for i, g := range w.errs.sorted(UI_GROUP_CLASS, UI_SORT_COUNT) {
+		if i == WEB_ERRORS {
+			break
+		}
+		rate := 0.0
+		if window > 0 {
+			rate = float64(g.windowCount()) / window.Seconds()
+		}
+		s.ErrorGroups = append(s.ErrorGroups, &webErrorGroup{Class: g.Key, Count: g.Count, Rate: rate,
+			LastSeen: now.Sub(g.Last).Seconds(), Example: g.Example})
+	}
+	return s
+}
+
+func (w *webDashboard) serveIndex(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(rw, r)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Write([]byte(strings.Replace(webPage, "{{token}}", w.token, 1)))
+}
+
+func (w *webDashboard) serveStatus(rw http.ResponseWriter, r *http.Request) {
+	w.Lock()
+	data := w.last
+	w.Unlock()
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(data)
+}
+
+// serveEvents streams the snapshots as Server-Sent Events, starting with the recent history
+func (w *webDashboard) serveEvents(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan []byte, 16)
+	w.Lock()
+	history := w.history
+	select {
+	case <-w.done:
+		close(ch)
+	default:
+		w.subscribers[ch] = true
+	}
+	w.Unlock()
+	defer func() {
+		w.Lock()
+		delete(w.subscribers, ch)
+		w.Unlock()
+	}()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	for _, data := range history {
+		fmt.Fprintf(rw, "data: %s\n\n", data)
+	}
+	flusher.Flush()
+	for {
+		select {
+		case data, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(rw, "data: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// control wraps an action of the dashboard as a POST handler responding with the current client limit
+func (w *webDashboard) control(fn func(r *http.Request) error) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(rw, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if !w.authorized(r) {
+			http.Error(rw, "forbidden", http.StatusForbidden)
+			return
+		}
+		if err := fn(r); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]interface{}{"client_limit": w.runner.ClientLimit(), "paused": w.runner.IsPaused()})
+	}
+}
+
+// authorized tells if a control request comes from the dashboard page, other pages open in the browser can
+// post to the dashboard but they do not know the token and their origin is not the dashboard
+func (w *webDashboard) authorized(r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return false
+		}
+	}
+	return r.Header.Get(WEB_TOKEN_HEADER) == w.token
+}
+
+const webPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>lotgo</title>
+<style>
+body { font-family: sans-serif; margin: 0; background: #f4f5f7; color: #222; }
+header { background: #263238; color: #fff; padding: 10px 20px; display: flex; flex-wrap: wrap; align-items: center; gap: 16px; }
+header h1 { font-size: 18px; margin: 0; }
+header .status { font-weight: bold; }
+header button, header input { font-size: 14px; }
+header input { width: 60px; }
+.stats { display: flex; flex-wrap: wrap; gap: 12px; padding: 12px 20px; }
+.stat { background: #fff; border-radius: 4px; padding: 8px 14px; min-width: 110px; }
+.stat .v { font-size: 20px; font-weight: bold; }
+.stat .l { font-size: 12px; color: #666; }
+.charts { display: grid; grid-template-columns: repeat(auto-fit, minmax(420px, 1fr)); gap: 12px; padding: 0 20px; }
+.chart { background: #fff; border-radius: 4px; padding: 8px; }
+.chart h3 { font-size: 14px; margin: 0 0 4px 0; }
+.chart canvas { width: 100%; height: 200px; }
+table { border-collapse: collapse; margin: 12px 20px; background: #fff; width: calc(100% - 40px); }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #eee; font-size: 13px; }
+td.ex { font-family: monospace; word-break: break-all; }
+</style>
+</head>
+<body>
+<header>
+<h1>lotgo: <span id="test"></span></h1>
+<span class="status" id="status">connecting</span>
+<progress id="progress" max="100" value="0"></progress>
+<button id="pause">Pause</button>
+<button id="stop">Stop</button>
+<label>Clients <input id="clients" type="number" min="0"> of <span id="max"></span></label>
+<button id="apply">Apply</button>
+</header>
+<div class="stats">
+<div class="stat"><div class="v" id="rate">-</div><div class="l">r/s, window</div></div>
+<div class="stat"><div class="v" id="p50">-</div><div class="l">p50 ms</div></div>
+<div class="stat"><div class="v" id="p95">-</div><div class="l">p95 ms</div></div>
+<div class="stat"><div class="v" id="p99">-</div><div class="l">p99 ms</div></div>
+<div class="stat"><div class="v" id="errrate">-</div><div class="l">error %, window</div></div>
+<div class="stat"><div class="v" id="count">-</div><div class="l">successes</div></div>
+<div class="stat"><div class="v" id="errors">-</div><div class="l">errors</div></div>
+<div class="stat"><div class="v" id="active">-</div><div class="l">active clients</div></div>
+</div>
+<div class="charts">
+<div class="chart"><h3>Throughput r/s</h3><canvas id="c-rate"></canvas></div>
+<div class="chart"><h3>Latency ms: <span style="color:#2e7d32">p50</span> <span style="color:#f9a825">p95</span> <span style="color:#c62828">p99</span></h3><canvas id="c-latency"></canvas></div>
+<div class="chart"><h3>Active clients</h3><canvas id="c-clients"></canvas></div>
+<div class="chart"><h3>Error rate %</h3><canvas id="c-errors"></canvas></div>
+</div>
+<table>
+<thead><tr><th>error class</th><th>count</th><th>rate /s</th><th>last seen</th><th>example</th></tr></thead>
+<tbody id="errortable"></tbody>
+</table>
+<script>
+var MAX_POINTS = 300;
+var TOKEN = "{{token}}";
+var series = { t: [], rate: [], p50: [], p95: [], p99: [], clients: [], errors: [] };
+var last = null;
+
+function $(id) { return document.getElementById(id); }
+
+function post(path) {
+  return fetch(path, { method: "POST", headers: { "X-Lotgo-Token": TOKEN } }).then(function (r) {
+    if (!r.ok) { r.text().then(function (t) { alert(t); }); }
+  });
+}
+
+function push(name, v) {
+  series[name].push(v);
+  if (series[name].length > MAX_POINTS) { series[name].shift(); }
+}
+
+function draw(id, lines, colors) {
+  var c = $(id);
+  var w = c.clientWidth, h = c.clientHeight;
+  c.width = w; c.height = h;
+  var g = c.getContext("2d");
+  var left = 44, bottom = 18, top = 6;
+  var max = 0;
+  lines.forEach(function (l) { l.forEach(function (v) { max = Math.max(max, v); }); });
+  max = max > 0 ? max * 1.1 : 1;
+  g.font = "11px sans-serif";
+  g.fillStyle = "#666";
+  g.strokeStyle = "#ddd";
+  for (var i = 0; i <= 4; i++) {
+    var y = top + (h - top - bottom) * i / 4;
+    g.beginPath(); g.moveTo(left, y); g.lineTo(w, y); g.stroke();
+    g.fillText((max * (4 - i) / 4).toPrecision(3), 2, y + 4);
+  }
+  var ts = series.t;
+  if (ts.length > 1) {
+    g.fillText(ts[0].toFixed(0) + "s", left, h - 4);
+    g.fillText(ts[ts.length - 1].toFixed(0) + "s", w - 30, h - 4);
+  }
+  lines.forEach(function (l, j) {
+    g.strokeStyle = colors[j];
+    g.lineWidth = 1.5;
+    g.beginPath();
+    l.forEach(function (v, i) {
+      var x = left + (w - left) * i / Math.max(l.length - 1, 1);
+      var y = top + (h - top - bottom) * (1 - v / max);
+      if (i === 0) { g.moveTo(x, y); } else { g.lineTo(x, y); }
+    });
+    g.stroke();
+  });
+}
+
+function render() {
+  draw("c-rate", [series.rate], ["#1565c0"]);
+  draw("c-latency", [series.p50, series.p95, series.p99], ["#2e7d32", "#f9a825", "#c62828"]);
+  draw("c-clients", [series.clients], ["#00838f"]);
+  draw("c-errors", [series.errors], ["#c62828"]);
+}
+
+function text(s) {
+  var d = document.createElement("div");
+  d.textContent = s;
+  return d.innerHTML;
+}
+
+function update(s) {
+  last = s;
+  push("t", s.elapsed); push("rate", s.rate); push("clients", s.clients); push("errors", s.error_rate);
+  push("p50", s.latency.p50); push("p95", s.latency.p95); push("p99", s.latency.p99);
+  $("test").textContent = s.test;
+  $("status").textContent = s.done ? "finished" : (s.paused ? "paused" : "running");
+  $("progress").value = s.progress;
+  $("pause").textContent = s.paused ? "Resume" : "Pause";
+  $("max").textContent = s.max_clients;
+  if (document.activeElement !== $("clients")) { $("clients").value = s.client_limit; }
+  $("rate").textContent = s.window_rate.toFixed(1);
+  $("p50").textContent = s.latency.p50.toFixed(1);
+  $("p95").textContent = s.latency.p95.toFixed(1);
+  $("p99").textContent = s.latency.p99.toFixed(1);
+  $("errrate").textContent = s.error_rate.toFixed(2);
+  $("count").textContent = s.count;
+  $("errors").textContent = s.errors;
+  $("active").textContent = s.clients;
+  $("errortable").innerHTML = s.error_groups.map(function (e) {
+    return "<tr><td>" + text(e.class) + "</td><td>" + e.count + "</td><td>" + e.rate.toFixed(1) + "</td><td>" +
+      e.last_seen.toFixed(0) + "s ago</td><td class=\"ex\">" + text(e.example) + "</td></tr>";
+  }).join("");
+  ["pause", "stop", "apply"].forEach(function (id) { $(id).disabled = s.done; });
+}
+
+var pending = false;
+var events = new EventSource("/events");
+events.onmessage = function (e) {
+  update(JSON.parse(e.data));
+  if (!pending) { pending = true; requestAnimationFrame(function () { pending = false; render(); }); }
+};
+events.onerror = function () {
+  if (last && last.done) { events.close(); return; }
+  $("status").textContent = "disconnected";
+};
+window.onresize = render;
+$("pause").onclick = function () { post(last && last.paused ? "/api/resume" : "/api/pause"); };
+$("stop").onclick = function () { if (confirm("Stop the test?")) { post("/api/stop"); } };
+$("apply").onclick = function () { post("/api/clients?n=" + encodeURIComponent($("clients").value)); };
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,109 @@
+package lotgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebDashboard(t *testing.T) {
+	runner := &Runner{clients: 4, name: "shop", duration: time.Minute, startTime: time.Now()}
+	w := NewWebDashboard("127.0.0.1:0", DefaultPrecision, time.Second)
+	w.Started(runner)
+	require.NotNil(t, w.listener)
+	base := "http://" + w.listener.Addr().String()
+
+	for i := 0; i < 10; i++ {
+		w.Sample(&Sample{Iteration: Iteration{Test: "shop", Duration: 20 * time.Millisecond}})
+	}
+	w.Sample(&Sample{Iteration: Iteration{Test: "shop", Class: "connection refused", Start: time.Now()}, Err: errors.New("connection refused")})
+	w.publish(time.Now(), false)
+
+	resp, err := http.Get(base + "/api/status")
+	require.Nil(t, err)
+	var s webSnapshot
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&s))
+	resp.Body.Close()
+	assert.Equal(t, "shop", s.Test)
+	assert.Equal(t, int64(10), s.Count)
+	assert.Equal(t, int64(1), s.Errors)
+	assert.Equal(t, 4, s.ClientLimit)
+	assert.InDelta(t, 20, s.Latency["p99"], 1)
+	assert.InDelta(t, 100.0/11, s.ErrorRate, 0.001)
+	require.Equal(t, 1, len(s.ErrorGroups))
+	assert.Equal(t, "connection refused", s.ErrorGroups[0].Class)
+
+	resp, err = http.Get(base + "/")
+	require.Nil(t, err)
+	page, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(page), `new EventSource("/events")`)
+	assert.Contains(t, string(page), `var TOKEN = "`+w.token+`";`)
+
+	post := func(path string, header ...string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, base+path, nil)
+		require.Nil(t, err)
+		req.Header.Set(WEB_TOKEN_HEADER, w.token)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	assert.Equal(t, http.StatusForbidden, post("/api/stop", WEB_TOKEN_HEADER, "").StatusCode)
+	assert.Equal(t, http.StatusForbidden, post("/api/stop", WEB_TOKEN_HEADER, "guess").StatusCode)
+	assert.Equal(t, http.StatusForbidden, post("/api/stop", "Origin", "http://evil.test").StatusCode)
+	assert.False(t, runner.isStopped())
+
+	assert.Equal(t, http.StatusOK, post("/api/clients?n=1", "Origin", base).StatusCode)
+	assert.Equal(t, 1, runner.ClientLimit())
+	assert.Equal(t, http.StatusBadRequest, post("/api/clients?n=many").StatusCode)
+	resp, err = http.Get(base + "/api/pause")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	post("/api/pause")
+	assert.True(t, runner.IsPaused())
+	post("/api/stop")
+	assert.True(t, runner.isStopped())
+
+	// the event stream starts with the history and ends when the test finishes
+	resp, err = http.Get(base + "/events")
+	require.Nil(t, err)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	r := bufio.NewReader(resp.Body)
+	var events []string
+	readEvent := func() string {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return ""
+		}
+		r.ReadString('\n')
+		return strings.TrimSpace(line)
+	}
+	events = append(events, readEvent(), readEvent())
+	go w.Finished()
+	events = append(events, readEvent())
+	resp.Body.Close()
+	for _, e := range events {
+		assert.True(t, strings.HasPrefix(e, "data: {"), e)
+	}
+	assert.Contains(t, events[2], `"done":true`)
+}
+
+func TestWebBindAddr(t *testing.T) {
+	assert.Equal(t, "localhost:8089", webBindAddr("8089"))
+	assert.Equal(t, "localhost:8089", webBindAddr(":8089"))
+	assert.Equal(t, "127.0.0.1:8089", webBindAddr("127.0.0.1:8089"))
+	assert.Equal(t, "0.0.0.0:8089", webBindAddr("0.0.0.0:8089"))
+	assert.Equal(t, "[::1]:8089", webBindAddr("[::1]:8089"))
+}